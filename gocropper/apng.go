@@ -0,0 +1,881 @@
+package gocropper
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+)
+
+// APNG frame dispose operations, applied to the frame region after the frame is displayed.
+const (
+	APNGDisposeNone       = 0
+	APNGDisposeBackground = 1
+	APNGDisposePrevious   = 2
+)
+
+// APNG frame blend operations.
+const (
+	APNGBlendSource = 0
+	APNGBlendOver   = 1
+)
+
+// APNG is an animated PNG image.
+type APNG struct {
+	Width, Height int
+	Frames        []*APNGFrame
+	// LoopCount is the number of times the animation is played, 0 plays it forever.
+	LoopCount int
+	// Default is the image shown by decoders without APNG support if it is not the first frame of the animation, otherwise nil.
+	Default CroppableImage
+}
+
+// APNGFrame is a frame of an animated PNG, the bounds of its image are the region of the frame on the canvas.
+type APNGFrame struct {
+	Image CroppableImage
+	// DelayNum and DelayDen are the numerator and denominator of the frame delay in seconds, denominator 0 means 100.
+	DelayNum, DelayDen uint16
+	DisposeOp          byte
+	BlendOp            byte
+}
+
+// loadAPNG decodes a PNG image, animated images also keep all their frames in c.APNG.
+// The image of the croppable is the default image of the PNG.
+func (c *Croppable) loadAPNG(r io.Reader) error {
+	head := &bytes.Buffer{}
+	br := bufio.NewReader(r)
+
+	animated, err := pngAnimated(io.TeeReader(br, head))
+	if err != nil {
+		return err
+	}
+
+	src := io.MultiReader(head, br)
+
+	if !animated {
+		img, err := png.Decode(src)
+		if err != nil {
+			return err
+		}
+
+		croppableImg, ok := img.(CroppableImage)
+		if !ok {
+			return ErrImageUncroppable
+		}
+
+		c.Image = croppableImg
+
+		return nil
+	}
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+
+	a, err := decodeAPNG(data)
+	if err != nil {
+		return err
+	}
+
+	c.Image = a.image()
+	c.APNG = a
+
+	return nil
+}
+
+// pngAnimated reads the chunks preceding the image data and reports whether the PNG is animated.
+func pngAnimated(r io.Reader) (bool, error) {
+	sig := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, sig); err != nil || !bytes.Equal(sig, pngSignature) {
+		return false, ErrInvalidPNG
+	}
+
+	header := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return false, fmt.Errorf("%s: %w", err.Error(), ErrInvalidPNG)
+		}
+
+		switch string(header[4:]) {
+		case "acTL":
+			return true, nil
+		case "IDAT", "IEND":
+			return false, nil
+		}
+
+		if _, err := io.CopyN(io.Discard, r, int64(binary.BigEndian.Uint32(header))+4); err != nil {
+			return false, fmt.Errorf("%s: %w", err.Error(), ErrInvalidPNG)
+		}
+	}
+}
+
+// apngFramePixels reads the chunks of a PNG image and returns the total number of pixels of the frames of an animated PNG
+// and of its default image if it is not a frame, without decoding them. It returns 0 for PNG images which are not animated.
+func apngFramePixels(r io.Reader) (int64, error) {
+	sig := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, sig); err != nil || !bytes.Equal(sig, pngSignature) {
+		return 0, ErrInvalidPNG
+	}
+
+	var (
+		canvas   image.Rectangle
+		animated bool
+		isFrame  bool
+		seenIDAT bool
+		pixels   int64
+	)
+
+	header := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return 0, fmt.Errorf("%s: %w", err.Error(), ErrInvalidPNG)
+		}
+
+		length := int64(binary.BigEndian.Uint32(header))
+
+		switch typ := string(header[4:]); typ {
+		case "IHDR", "fcTL":
+			if (typ == "IHDR" && length != 13) || (typ == "fcTL" && length != 26) {
+				return 0, fmt.Errorf("%s: %w", typ, ErrInvalidPNG)
+			}
+
+			data := make([]byte, length+4)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return 0, fmt.Errorf("%s: %w", err.Error(), ErrInvalidPNG)
+			}
+
+			if typ == "IHDR" {
+				canvas = image.Rect(0, 0, int(binary.BigEndian.Uint32(data)), int(binary.BigEndian.Uint32(data[4:])))
+				continue
+			}
+
+			frame, err := parseFCTL(data[:length], canvas)
+			if err != nil {
+				return 0, err
+			}
+
+			// the default image is the first frame if its fcTL precedes the image data
+			isFrame = isFrame || !seenIDAT
+			pixels += int64(area(frame.rect))
+
+			continue
+		case "acTL":
+			animated = true
+		case "IDAT":
+			seenIDAT = true
+		case "IEND":
+			if !animated {
+				return 0, nil
+			}
+
+			if !isFrame {
+				pixels += int64(area(canvas))
+			}
+
+			return pixels, nil
+		}
+
+		if _, err := io.CopyN(io.Discard, r, length+4); err != nil {
+			return 0, fmt.Errorf("%s: %w", err.Error(), ErrInvalidPNG)
+		}
+	}
+}
+
+// image returns the image shown by decoders without APNG support.
+func (a *APNG) image() CroppableImage {
+	if a.Default != nil {
+		return a.Default
+	}
+
+	return a.Frames[0].Image
+}
+
+func (a *APNG) canvas() image.Rectangle {
+	return image.Rect(0, 0, a.Width, a.Height)
+}
+
+// cropAPNGRect crops all frames of an animated PNG to the rectangle extended by padding, usually the union of the content rectangles
+// of its composited frames, see APNGRect.
+// Frames are translated so the cropped animation starts at the origin. Frames entirely outside the rectangle are dropped,
+// extending the delay of the previous frame, unless the previous frame is disposed, then they are replaced with a transparent pixel.
+func (i *Cropper) cropAPNGRect(c *Croppable, rect image.Rectangle) (*Croppable, bool) {
+	padded := rect.Inset(-i.padding)
+
+	if padded.Eq(c.APNG.canvas()) {
+		return c, false
+	}
+
+	cropped := &APNG{Width: padded.Dx(), Height: padded.Dy(), LoopCount: c.APNG.LoopCount}
+
+	if c.APNG.Default != nil {
+		cropped.Default = cropCanvasImage(c.APNG.Default, padded)
+	}
+
+	for f, frame := range c.APNG.Frames {
+		visible := frame.Image.Bounds().Intersect(padded)
+
+		if f == 0 && c.APNG.Default == nil {
+			// the first frame is the default image and has to cover the whole canvas
+			cropped.Frames = append(cropped.Frames, &APNGFrame{
+				Image:     cropCanvasImage(frame.Image, padded),
+				DelayNum:  frame.DelayNum,
+				DelayDen:  frame.DelayDen,
+				DisposeOp: frame.DisposeOp,
+				BlendOp:   frame.BlendOp,
+			})
+
+			continue
+		}
+
+		if visible.Empty() {
+			if len(cropped.Frames) > 0 {
+				prev := cropped.Frames[len(cropped.Frames)-1]
+
+				if prev.DisposeOp == APNGDisposeNone {
+					if num, den, ok := addDelays(prev.DelayNum, prev.DelayDen, frame.DelayNum, frame.DelayDen); ok {
+						prev.DelayNum, prev.DelayDen = num, den
+						continue
+					}
+				}
+			}
+
+			cropped.Frames = append(cropped.Frames, &APNGFrame{
+				Image:    image.NewNRGBA(image.Rect(0, 0, 1, 1)),
+				DelayNum: frame.DelayNum,
+				DelayDen: frame.DelayDen,
+				BlendOp:  APNGBlendOver,
+			})
+
+			continue
+		}
+
+		cropped.Frames = append(cropped.Frames, &APNGFrame{
+			Image:     translateImage(frame.Image.SubImage(visible).(CroppableImage), padded.Min.Mul(-1)),
+			DelayNum:  frame.DelayNum,
+			DelayDen:  frame.DelayDen,
+			DisposeOp: frame.DisposeOp,
+			BlendOp:   frame.BlendOp,
+		})
+	}
+
+	return c.withAPNG(cropped), true
+}
+
+// cropCanvasImage returns the part of an image covering the canvas inside rect, translated to the origin.
+// Parts of rect outside the image are transparent.
+func cropCanvasImage(img CroppableImage, rect image.Rectangle) CroppableImage {
+	visible := img.Bounds().Intersect(rect)
+
+	var cropped CroppableImage
+	if visible.Eq(rect) {
+		cropped = img.SubImage(rect).(CroppableImage)
+	} else {
+		cropped = padImage(img, visible, rect, color.Transparent).(CroppableImage)
+	}
+
+	return translateImage(cropped, rect.Min.Mul(-1))
+}
+
+// APNGRect returns the cropping rectangle of an animated PNG, does not include padding.
+// It is the union of the rectangles of all frames composited according to their dispose and blend operations,
+// the bounds of the canvas are returned if no frame has content.
+func (i *Cropper) APNGRect(a *APNG) image.Rectangle {
+	return i.apngRect(a)
+}
+
+func (i *Cropper) apngRect(a *APNG) image.Rectangle {
+	if rect, ok := i.apngContent(a); ok {
+		return rect
+	}
+
+	return a.canvas()
+}
+
+// apngContent returns the union of the content rectangles of all composited frames, false if no frame has content.
+func (i *Cropper) apngContent(a *APNG) (image.Rectangle, bool) {
+	screen := a.canvas()
+	canvas := image.NewRGBA(screen)
+
+	var (
+		m        pixelMatcher
+		union    image.Rectangle
+		previous *image.RGBA
+	)
+
+	for f, frame := range a.Frames {
+		r := frame.Image.Bounds()
+
+		dispose := frame.DisposeOp
+		if f == 0 && dispose == APNGDisposePrevious {
+			// there is no previous canvas for the first frame
+			dispose = APNGDisposeBackground
+		}
+
+		if dispose == APNGDisposePrevious {
+			previous = image.NewRGBA(screen)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		op := draw.Over
+		if frame.BlendOp == APNGBlendSource {
+			op = draw.Src
+		}
+
+		draw.Draw(canvas, r, frame.Image, r.Min, op)
+
+		// the background is detected on the first composited frame only
+		if f == 0 {
+			m = i.matcher(canvas)
+		}
+
+		if rect, ok := i.content(canvas, m); ok {
+			union = union.Union(rect)
+		}
+
+		switch dispose {
+		case APNGDisposeBackground:
+			draw.Draw(canvas, r, image.Transparent, image.Point{}, draw.Src)
+		case APNGDisposePrevious:
+			canvas = previous
+		}
+	}
+
+	return union, !union.Empty()
+}
+
+// addDelays returns the sum of two frame delays, false if it can't be represented.
+func addDelays(aNum, aDen, bNum, bDen uint16) (uint16, uint16, bool) {
+	if aDen == 0 {
+		aDen = 100
+	}
+
+	if bDen == 0 {
+		bDen = 100
+	}
+
+	num := uint64(aNum)*uint64(bDen) + uint64(bNum)*uint64(aDen)
+	den := uint64(aDen) * uint64(bDen)
+
+	d := gcd(num, den)
+	num, den = num/d, den/d
+
+	if num > 0xffff || den > 0xffff {
+		return 0, 0, false
+	}
+
+	return uint16(num), uint16(den), true
+}
+
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
+
+// translateImage returns the image with its bounds moved by delta, sharing the pixels of the image.
+func translateImage(img CroppableImage, delta image.Point) CroppableImage {
+	switch m := img.(type) {
+	case *image.Paletted:
+		t := *m
+		t.Rect = t.Rect.Add(delta)
+		return &t
+	case *image.Gray:
+		t := *m
+		t.Rect = t.Rect.Add(delta)
+		return &t
+	case *image.Gray16:
+		t := *m
+		t.Rect = t.Rect.Add(delta)
+		return &t
+	case *image.RGBA:
+		t := *m
+		t.Rect = t.Rect.Add(delta)
+		return &t
+	case *image.RGBA64:
+		t := *m
+		t.Rect = t.Rect.Add(delta)
+		return &t
+	case *image.NRGBA:
+		t := *m
+		t.Rect = t.Rect.Add(delta)
+		return &t
+	case *image.NRGBA64:
+		t := *m
+		t.Rect = t.Rect.Add(delta)
+		return &t
+	default:
+		r := img.Bounds().Add(delta)
+		t := image.NewNRGBA64(r)
+		draw.Draw(t, r, img, img.Bounds().Min, draw.Src)
+
+		return t
+	}
+}
+
+type pngChunk struct {
+	typ  string
+	data []byte
+}
+
+// readPNGChunks returns the chunks of a PNG image up to IEND, verifying their checksums.
+func readPNGChunks(data []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, ErrInvalidPNG
+	}
+
+	var chunks []pngChunk
+
+	for data = data[len(pngSignature):]; ; {
+		if len(data) < 12 {
+			return nil, fmt.Errorf("truncated chunk: %w", ErrInvalidPNG)
+		}
+
+		length := binary.BigEndian.Uint32(data)
+		if uint64(length) > uint64(len(data)-12) {
+			return nil, fmt.Errorf("truncated chunk: %w", ErrInvalidPNG)
+		}
+
+		body := data[4 : 8+length]
+		if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[8+length:]) {
+			return nil, fmt.Errorf("%s chunk checksum: %w", body[:4], ErrInvalidPNG)
+		}
+
+		chunk := pngChunk{typ: string(body[:4]), data: body[4:]}
+		if chunk.typ == "IEND" {
+			return chunks, nil
+		}
+
+		chunks = append(chunks, chunk)
+		data = data[12+length:]
+	}
+}
+
+// apngFrameData is an undecoded frame, the image data of the frame is a PNG datastream of its own.
+type apngFrameData struct {
+	frame  *APNGFrame
+	rect   image.Rectangle
+	stream []byte
+}
+
+// decodeAPNG decodes all frames of an animated PNG.
+func decodeAPNG(data []byte) (*APNG, error) {
+	chunks, err := readPNGChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(chunks) == 0 || chunks[0].typ != "IHDR" || len(chunks[0].data) != 13 {
+		return nil, fmt.Errorf("missing IHDR: %w", ErrInvalidPNG)
+	}
+
+	ihdr := chunks[0].data
+	a := &APNG{
+		Width:  int(binary.BigEndian.Uint32(ihdr)),
+		Height: int(binary.BigEndian.Uint32(ihdr[4:])),
+	}
+
+	var (
+		shared      []pngChunk
+		frames      []*apngFrameData
+		defaultData []byte
+		seenIDAT    bool
+		numFrames   int
+		// the default image is the first frame if its fcTL precedes the image data
+		defaultIsFrame bool
+	)
+
+	for _, c := range chunks[1:] {
+		switch c.typ {
+		case "acTL":
+			if len(c.data) != 8 {
+				return nil, fmt.Errorf("acTL: %w", ErrInvalidPNG)
+			}
+
+			numFrames = int(binary.BigEndian.Uint32(c.data))
+			a.LoopCount = int(binary.BigEndian.Uint32(c.data[4:]))
+		case "fcTL":
+			fd, err := parseFCTL(c.data, a.canvas())
+			if err != nil {
+				return nil, err
+			}
+
+			frames = append(frames, fd)
+		case "IDAT":
+			if !seenIDAT {
+				defaultIsFrame = len(frames) == 1
+			}
+
+			seenIDAT = true
+			defaultData = append(defaultData, c.data...)
+		case "fdAT":
+			if len(frames) == 0 || len(c.data) < 4 {
+				return nil, fmt.Errorf("fdAT: %w", ErrInvalidPNG)
+			}
+
+			fd := frames[len(frames)-1]
+			fd.stream = append(fd.stream, c.data[4:]...)
+		default:
+			if !seenIDAT {
+				shared = append(shared, c)
+			}
+		}
+	}
+
+	if len(frames) == 0 || (numFrames != 0 && numFrames != len(frames)) {
+		return nil, fmt.Errorf("%d frames, %d in acTL: %w", len(frames), numFrames, ErrInvalidPNG)
+	}
+
+	if defaultIsFrame {
+		frames[0].stream = defaultData
+	} else {
+		img, err := decodePNGStream(ihdr, a.canvas(), shared, defaultData)
+		if err != nil {
+			return nil, err
+		}
+
+		a.Default = img
+	}
+
+	for _, fd := range frames {
+		img, err := decodePNGStream(ihdr, fd.rect, shared, fd.stream)
+		if err != nil {
+			return nil, err
+		}
+
+		fd.frame.Image = img
+		a.Frames = append(a.Frames, fd.frame)
+	}
+
+	return a, nil
+}
+
+func parseFCTL(data []byte, canvas image.Rectangle) (*apngFrameData, error) {
+	if len(data) != 26 {
+		return nil, fmt.Errorf("fcTL: %w", ErrInvalidPNG)
+	}
+
+	width := int(binary.BigEndian.Uint32(data[4:]))
+	height := int(binary.BigEndian.Uint32(data[8:]))
+	x := int(binary.BigEndian.Uint32(data[12:]))
+	y := int(binary.BigEndian.Uint32(data[16:]))
+	rect := image.Rect(x, y, x+width, y+height)
+
+	if width <= 0 || height <= 0 || !rect.In(canvas) {
+		return nil, fmt.Errorf("frame %v outside of canvas: %w", rect, ErrInvalidPNG)
+	}
+
+	if data[24] > APNGDisposePrevious || data[25] > APNGBlendOver {
+		return nil, fmt.Errorf("fcTL operations: %w", ErrInvalidPNG)
+	}
+
+	return &apngFrameData{
+		rect: rect,
+		frame: &APNGFrame{
+			DelayNum:  binary.BigEndian.Uint16(data[20:]),
+			DelayDen:  binary.BigEndian.Uint16(data[22:]),
+			DisposeOp: data[24],
+			BlendOp:   data[25],
+		},
+	}, nil
+}
+
+// decodePNGStream decodes image data of the size of rect as a PNG image with the header and ancillary chunks of the APNG,
+// the decoded image has the bounds of rect.
+func decodePNGStream(ihdr []byte, rect image.Rectangle, shared []pngChunk, data []byte) (CroppableImage, error) {
+	header := append([]byte{}, ihdr...)
+	binary.BigEndian.PutUint32(header, uint32(rect.Dx()))
+	binary.BigEndian.PutUint32(header[4:], uint32(rect.Dy()))
+
+	buf := &bytes.Buffer{}
+	buf.Write(pngSignature)
+	writePNGChunk(buf, "IHDR", header)
+
+	for _, c := range shared {
+		writePNGChunk(buf, c.typ, c.data)
+	}
+
+	writePNGChunk(buf, "IDAT", data)
+	writePNGChunk(buf, "IEND", nil)
+
+	img, err := png.Decode(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	croppableImg, ok := img.(CroppableImage)
+	if !ok {
+		return nil, ErrImageUncroppable
+	}
+
+	return translateImage(croppableImg, rect.Min), nil
+}
+
+func writePNGChunk(w io.Writer, typ string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], typ)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	footer := binary.BigEndian.AppendUint32(nil, crc.Sum32())
+
+	for _, b := range [][]byte{header, data, footer} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func encodeAPNG(a *APNG) func(w io.Writer, m image.Image) error {
+	return func(w io.Writer, m image.Image) error {
+		return writeAPNG(w, a)
+	}
+}
+
+// writeAPNG encodes the animation. All frames share the color type of the image header, frames sharing the same palette
+// are encoded as paletted images, otherwise frames are encoded as 8-bit RGBA images, or 16-bit if any frame has 16-bit samples.
+func writeAPNG(w io.Writer, a *APNG) error {
+	if len(a.Frames) == 0 {
+		return errors.New("apng has no frames")
+	}
+
+	images := []image.Image{}
+	if a.Default != nil {
+		images = append(images, a.Default)
+	}
+
+	for _, f := range a.Frames {
+		images = append(images, f.Image)
+	}
+
+	enc := newAPNGEncoder(images)
+
+	bw := bufio.NewWriter(w)
+	bw.Write(pngSignature)
+
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr, uint32(a.Width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(a.Height))
+	ihdr[8], ihdr[9] = enc.depth, enc.colorType
+	writePNGChunk(bw, "IHDR", ihdr)
+
+	actl := binary.BigEndian.AppendUint32(nil, uint32(len(a.Frames)))
+	actl = binary.BigEndian.AppendUint32(actl, uint32(a.LoopCount))
+	writePNGChunk(bw, "acTL", actl)
+
+	if enc.palette != nil {
+		plte, trns := make([]byte, 0, 3*len(enc.palette)), make([]byte, 0, len(enc.palette))
+		transparent := false
+
+		for _, c := range enc.palette {
+			nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+			plte = append(plte, nc.R, nc.G, nc.B)
+			trns = append(trns, nc.A)
+			transparent = transparent || nc.A != 0xff
+		}
+
+		writePNGChunk(bw, "PLTE", plte)
+
+		if transparent {
+			writePNGChunk(bw, "tRNS", trns)
+		}
+	}
+
+	seq := uint32(0)
+
+	if a.Default != nil {
+		data, err := enc.imageData(a.Default)
+		if err != nil {
+			return err
+		}
+
+		writePNGChunk(bw, "IDAT", data)
+	}
+
+	for f, frame := range a.Frames {
+		r := frame.Image.Bounds()
+
+		fctl := binary.BigEndian.AppendUint32(nil, seq)
+		for _, v := range []int{r.Dx(), r.Dy(), r.Min.X, r.Min.Y} {
+			fctl = binary.BigEndian.AppendUint32(fctl, uint32(v))
+		}
+
+		fctl = binary.BigEndian.AppendUint16(fctl, frame.DelayNum)
+		fctl = binary.BigEndian.AppendUint16(fctl, frame.DelayDen)
+		fctl = append(fctl, frame.DisposeOp, frame.BlendOp)
+		writePNGChunk(bw, "fcTL", fctl)
+		seq++
+
+		data, err := enc.imageData(frame.Image)
+		if err != nil {
+			return err
+		}
+
+		if f == 0 && a.Default == nil {
+			writePNGChunk(bw, "IDAT", data)
+			continue
+		}
+
+		writePNGChunk(bw, "fdAT", append(binary.BigEndian.AppendUint32(nil, seq), data...))
+		seq++
+	}
+
+	writePNGChunk(bw, "IEND", nil)
+
+	return bw.Flush()
+}
+
+type apngEncoder struct {
+	colorType, depth byte
+	palette          color.Palette
+}
+
+func newAPNGEncoder(images []image.Image) *apngEncoder {
+	var palette color.Palette
+
+	for n, img := range images {
+		p, ok := img.(*image.Paletted)
+		if !ok || len(p.Palette) > 256 || (n > 0 && !samePalette(palette, p.Palette)) {
+			palette = nil
+			break
+		}
+
+		palette = p.Palette
+	}
+
+	if palette != nil {
+		return &apngEncoder{colorType: pngPaletted, depth: 8, palette: palette}
+	}
+
+	for _, img := range images {
+		if is16Bit(img) {
+			return &apngEncoder{colorType: pngRGBA, depth: 16}
+		}
+	}
+
+	return &apngEncoder{colorType: pngRGBA, depth: 8}
+}
+
+func samePalette(a, b color.Palette) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// imageData returns the filtered and compressed scanlines of the image.
+func (e *apngEncoder) imageData(img image.Image) ([]byte, error) {
+	b := img.Bounds()
+
+	bpp := 4
+	switch {
+	case e.palette != nil:
+		bpp = 1
+	case e.depth == 16:
+		bpp = 8
+	}
+
+	buf := &bytes.Buffer{}
+	zw := zlib.NewWriter(buf)
+
+	prev := make([]byte, b.Dx()*bpp)
+	row := make([]byte, b.Dx()*bpp)
+	filtered := make([]byte, 1+len(row))
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			p := row[(x-b.Min.X)*bpp:]
+
+			switch {
+			case bpp == 1:
+				p[0] = img.(*image.Paletted).ColorIndexAt(x, y)
+			case bpp == 8:
+				c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+				binary.BigEndian.PutUint16(p, c.R)
+				binary.BigEndian.PutUint16(p[2:], c.G)
+				binary.BigEndian.PutUint16(p[4:], c.B)
+				binary.BigEndian.PutUint16(p[6:], c.A)
+			default:
+				c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				p[0], p[1], p[2], p[3] = c.R, c.G, c.B, c.A
+			}
+		}
+
+		filterRow(filtered, row, prev, bpp)
+
+		if _, err := zw.Write(filtered); err != nil {
+			return nil, err
+		}
+
+		prev, row = row, prev
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// filterRow writes the filter type and the filtered row to dst, choosing the filter with the smallest sum of absolute differences.
+func filterRow(dst, row, prev []byte, bpp int) {
+	best := -1
+	candidate := make([]byte, len(row))
+
+	for filter := byte(0); filter < 5; filter++ {
+		sum := 0
+
+		for i, v := range row {
+			var a, b, c byte
+			if i >= bpp {
+				a, c = row[i-bpp], prev[i-bpp]
+			}
+
+			b = prev[i]
+
+			switch filter {
+			case 1:
+				v -= a
+			case 2:
+				v -= b
+			case 3:
+				v -= byte((int(a) + int(b)) / 2)
+			case 4:
+				v -= paeth(a, b, c)
+			}
+
+			candidate[i] = v
+			sum += absInt(int(int8(v)))
+		}
+
+		if best == -1 || sum < best {
+			best = sum
+			dst[0] = filter
+			copy(dst[1:], candidate)
+		}
+	}
+}
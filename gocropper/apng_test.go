@@ -0,0 +1,139 @@
+package gocropper_test
+
+import (
+	"image"
+	"image/color"
+	"path"
+	"testing"
+
+	"github.com/H3Cki/gocrop/gocropper"
+	"github.com/stretchr/testify/assert"
+)
+
+// blinkAPNG returns a 60x40 animation of four frames. The first one covers the canvas, the second one is disposed to background
+// and the last two are empty and lie outside the content.
+func blinkAPNG() *gocropper.APNG {
+	palette := color.Palette{color.Transparent, color.Black, color.RGBA{0xff, 0, 0, 0xff}}
+
+	f0 := image.NewPaletted(image.Rect(0, 0, 60, 40), palette)
+	f0.SetColorIndex(10, 5, 1)
+
+	f1 := image.NewPaletted(image.Rect(20, 10, 30, 20), palette)
+	f1.SetColorIndex(25, 15, 2)
+
+	return &gocropper.APNG{
+		Width:     60,
+		Height:    40,
+		LoopCount: 3,
+		Frames: []*gocropper.APNGFrame{
+			{Image: f0, DelayNum: 10, DelayDen: 100, BlendOp: gocropper.APNGBlendSource},
+			{Image: f1, DelayNum: 5, DelayDen: 0, DisposeOp: gocropper.APNGDisposeBackground, BlendOp: gocropper.APNGBlendOver},
+			{Image: image.NewPaletted(image.Rect(50, 30, 60, 40), palette), DelayNum: 20, DelayDen: 1000, BlendOp: gocropper.APNGBlendOver},
+			{Image: image.NewPaletted(image.Rect(40, 30, 45, 35), palette), DelayNum: 30, DelayDen: 1000, BlendOp: gocropper.APNGBlendOver},
+		},
+	}
+}
+
+// saveAPNG saves the animation in dir under the name and loads it back.
+func saveAPNG(t *testing.T, dir, fn string, a *gocropper.APNG) *gocropper.Croppable {
+	cropper, err := gocropper.NewCropper(gocropper.WithOutDir(dir))
+	assert.NoError(t, err)
+	assert.NoError(t, cropper.Save(&gocropper.Croppable{Path: fn, Format: "png", Image: a.Frames[0].Image, APNG: a}))
+
+	c, err := gocropper.Load(path.Join(dir, fn))
+	assert.NoError(t, err)
+
+	return c
+}
+
+func TestLoad_APNG(t *testing.T) {
+	dir := t.TempDir()
+	src := blinkAPNG()
+
+	hidden := blinkAPNG()
+	hidden.Default = image.NewGray(image.Rect(0, 0, 60, 40))
+
+	for _, a := range []*gocropper.APNG{src, hidden} {
+		c := saveAPNG(t, dir, "blink.png", a)
+
+		if !assert.NotNil(t, c.APNG) {
+			return
+		}
+
+		assert.Equal(t, 3, c.APNG.LoopCount)
+		assert.Len(t, c.APNG.Frames, 4)
+		assert.Equal(t, a.Default != nil, c.APNG.Default != nil)
+		assert.Equal(t, image.Rect(0, 0, 60, 40), c.Image.Bounds())
+
+		for f, frame := range c.APNG.Frames {
+			exFrame := a.Frames[f]
+			assert.Equal(t, exFrame.Image.Bounds(), frame.Image.Bounds())
+			assert.Equal(t, []any{exFrame.DelayNum, exFrame.DelayDen, exFrame.DisposeOp, exFrame.BlendOp},
+				[]any{frame.DelayNum, frame.DelayDen, frame.DisposeOp, frame.BlendOp})
+
+			// frames sharing a palette stay paletted
+			if a.Default == nil {
+				assert.IsType(t, &image.Paletted{}, frame.Image)
+			}
+		}
+
+		assert.Equal(t, color.RGBA{0xff, 0, 0, 0xff}, color.RGBAModel.Convert(c.APNG.Frames[1].Image.At(25, 15)))
+	}
+
+	// still images are not animated
+	c, err := gocropper.Load("testdata/described/circle-25-25-75-75.png")
+	assert.NoError(t, err)
+	assert.Nil(t, c.APNG)
+}
+
+func TestCropper_CropAndSaveAPNG(t *testing.T) {
+	src := saveAPNG(t, t.TempDir(), "blink.png", blinkAPNG())
+
+	cropper, err := gocropper.NewCropper()
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(10, 5, 26, 16), cropper.APNGRect(src.APNG))
+
+	for _, stream := range []bool{false, true} {
+		outDir := t.TempDir()
+
+		cropper, err := gocropper.NewCropper(gocropper.WithOutDir(outDir), gocropper.WithStreaming(stream))
+		assert.NoError(t, err)
+
+		processor, err := gocropper.NewProcessor(cropper)
+		assert.NoError(t, err)
+
+		c, err := gocropper.NewCroppable(src.Path)
+		assert.NoError(t, err)
+
+		processor.Process([]*gocropper.Croppable{c}, func(r gocropper.Result) {
+			assert.NoError(t, r.Err)
+			assert.True(t, r.Cropped)
+		})
+
+		saved, err := gocropper.Load(path.Join(outDir, "blink.png"))
+		assert.NoError(t, err)
+
+		if !assert.NotNil(t, saved.APNG) {
+			continue
+		}
+
+		assert.Equal(t, image.Pt(16, 11), image.Pt(saved.APNG.Width, saved.APNG.Height))
+		assert.Equal(t, 3, saved.APNG.LoopCount)
+
+		frames := saved.APNG.Frames
+		if !assert.Len(t, frames, 3) {
+			continue
+		}
+
+		assert.Equal(t, image.Rect(0, 0, 16, 11), frames[0].Image.Bounds())
+		assert.Equal(t, image.Rect(10, 5, 16, 11), frames[1].Image.Bounds())
+		assert.Equal(t, byte(gocropper.APNGDisposeBackground), frames[1].DisposeOp)
+
+		// the empty frame following a disposed frame is kept as a transparent pixel, the next one extends its delay
+		assert.Equal(t, image.Rect(0, 0, 1, 1), frames[2].Image.Bounds())
+		assert.Equal(t, []uint16{1, 20}, []uint16{frames[2].DelayNum, frames[2].DelayDen})
+
+		assert.Equal(t, color.RGBA{0, 0, 0, 0xff}, color.RGBAModel.Convert(frames[0].Image.At(0, 0)))
+		assert.Equal(t, color.RGBA{0xff, 0, 0, 0xff}, color.RGBAModel.Convert(frames[1].Image.At(15, 10)))
+	}
+}
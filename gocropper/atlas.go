@@ -0,0 +1,205 @@
+package gocropper
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var ErrUnknownAtlasFormat = errors.New("unknown atlas format")
+
+// AtlasFormat is the format of the data describing the sprites of saved atlas pages.
+type AtlasFormat int
+
+const (
+	// AtlasJSONHash is the TexturePacker JSON format with frames keyed by sprite names, one file per page.
+	AtlasJSONHash AtlasFormat = iota
+	// AtlasJSONArray is the TexturePacker JSON format with a list of frames, one file per page.
+	AtlasJSONArray
+	// AtlasCSV is a single CSV file with a row for every sprite of all pages.
+	AtlasCSV
+)
+
+var atlasFormatNames = map[AtlasFormat]string{
+	AtlasJSONHash:  "json-hash",
+	AtlasJSONArray: "json-array",
+	AtlasCSV:       "csv",
+}
+
+// ParseAtlasFormat returns the AtlasFormat with the given name: json-hash, json-array or csv.
+func ParseAtlasFormat(name string) (AtlasFormat, error) {
+	for f, n := range atlasFormatNames {
+		if strings.EqualFold(n, name) {
+			return f, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%s: %w", name, ErrUnknownAtlasFormat)
+}
+
+func (f AtlasFormat) String() string {
+	if name, ok := atlasFormatNames[f]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("AtlasFormat(%d)", int(f))
+}
+
+// Save saves the pages as PNG images named after the file name of fp, the extension of fp is ignored, along with their data in the format
+// of the packer, see WithAtlasFormat. With multiple pages the index of the page is appended to the names of images and JSON files,
+// e.g. atlas_0.png and atlas_0.json. The directory of fp is created if it doesn't exist.
+func (p *Packer) Save(pages []*AtlasPage, fp string) error {
+	dir, name, _ := dirFileExt(fp)
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	var rows [][]string
+
+	for n, page := range pages {
+		pageName := name
+		if len(pages) > 1 {
+			pageName = fmt.Sprintf("%s_%d", name, n)
+		}
+
+		if err := saveImage(path.Join(dir, pageName+".png"), page.Image, png.Encode); err != nil {
+			return err
+		}
+
+		if p.format == AtlasCSV {
+			rows = append(rows, csvRows(page, pageName+".png")...)
+			continue
+		}
+
+		if err := saveTexturePacker(path.Join(dir, pageName+".json"), page, pageName+".png", p.format); err != nil {
+			return err
+		}
+	}
+
+	if p.format != AtlasCSV {
+		return nil
+	}
+
+	fd, err := os.Create(path.Join(dir, name+".csv"))
+	if err != nil {
+		return err
+	}
+
+	defer fd.Close()
+
+	w := csv.NewWriter(fd)
+	header := []string{"name", "image", "x", "y", "w", "h", "rotated", "offset_x", "offset_y", "source_w", "source_h"}
+
+	return w.WriteAll(append([][]string{header}, rows...))
+}
+
+// csvRows returns a row for every sprite of the page, the size is the size of the sprite before rotation.
+func csvRows(page *AtlasPage, image string) [][]string {
+	var rows [][]string
+
+	for _, s := range page.Sprites {
+		size := s.Source.Size()
+		rows = append(rows, []string{
+			s.Name,
+			image,
+			strconv.Itoa(s.Frame.Min.X),
+			strconv.Itoa(s.Frame.Min.Y),
+			strconv.Itoa(size.X),
+			strconv.Itoa(size.Y),
+			strconv.FormatBool(s.Rotated),
+			strconv.Itoa(s.Source.Min.X),
+			strconv.Itoa(s.Source.Min.Y),
+			strconv.Itoa(s.SourceSize.X),
+			strconv.Itoa(s.SourceSize.Y),
+		})
+	}
+
+	return rows
+}
+
+type tpRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type tpSize struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type tpFrame struct {
+	Filename         string `json:"filename,omitempty"`
+	Frame            tpRect `json:"frame"`
+	Rotated          bool   `json:"rotated"`
+	Trimmed          bool   `json:"trimmed"`
+	SpriteSourceSize tpRect `json:"spriteSourceSize"`
+	SourceSize       tpSize `json:"sourceSize"`
+}
+
+type tpMeta struct {
+	App     string `json:"app"`
+	Version string `json:"version"`
+	Image   string `json:"image"`
+	Format  string `json:"format"`
+	Size    tpSize `json:"size"`
+	Scale   string `json:"scale"`
+}
+
+// saveTexturePacker saves the sprites of the page in the TexturePacker JSON hash or array format.
+// Frames have the size of sprites before rotation, like TexturePacker writes them.
+func saveTexturePacker(fp string, page *AtlasPage, image string, format AtlasFormat) error {
+	frames := make([]tpFrame, len(page.Sprites))
+
+	for n, s := range page.Sprites {
+		size := s.Source.Size()
+		frames[n] = tpFrame{
+			Frame:            tpRect{s.Frame.Min.X, s.Frame.Min.Y, size.X, size.Y},
+			Rotated:          s.Rotated,
+			Trimmed:          s.Trimmed(),
+			SpriteSourceSize: tpRect{s.Source.Min.X, s.Source.Min.Y, size.X, size.Y},
+			SourceSize:       tpSize{s.SourceSize.X, s.SourceSize.Y},
+		}
+	}
+
+	size := page.Image.Bounds().Size()
+	meta := tpMeta{App: "gocrop", Version: "1.0", Image: image, Format: "RGBA8888", Size: tpSize{size.X, size.Y}, Scale: "1"}
+
+	var v any
+
+	if format == AtlasJSONArray {
+		for n, s := range page.Sprites {
+			frames[n].Filename = s.Name
+		}
+
+		v = struct {
+			Frames []tpFrame `json:"frames"`
+			Meta   tpMeta    `json:"meta"`
+		}{frames, meta}
+	} else {
+		hash := map[string]tpFrame{}
+		for n, s := range page.Sprites {
+			hash[s.Name] = frames[n]
+		}
+
+		v = struct {
+			Frames map[string]tpFrame `json:"frames"`
+			Meta   tpMeta             `json:"meta"`
+		}{hash, meta}
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fp, data, 0o644)
+}
@@ -0,0 +1,175 @@
+package gocropper
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidColor = errors.New("invalid color")
+
+// pixelMatcher decides which pixels of an image belong to its content.
+type pixelMatcher struct {
+	threshold     uint32
+	background    color.RGBA64
+	hasBackground bool
+	tolerance     float64
+	metric        Metric
+	ref           metricRef
+}
+
+func (i *Cropper) matcher(img image.Image) pixelMatcher {
+	m := pixelMatcher{threshold: i.threshold, tolerance: i.tolerance, metric: i.metric}
+
+	bg := i.background
+	if i.autoBackground {
+		bg = DetectBackground(img)
+	}
+
+	if bg != nil {
+		m.background = toRGBA64(bg)
+		// transparent background is what the alpha threshold already handles
+		m.hasBackground = !i.autoBackground || m.background.A != 0
+		m.ref = m.metric.reference(m.background)
+	}
+
+	return m
+}
+
+// filled reports whether an alpha-premultiplied pixel, as returned by color.Color.RGBA, is part of the content.
+func (m *pixelMatcher) filled(r, g, b, a uint32) bool {
+	if a <= m.threshold {
+		return false
+	}
+
+	if !m.hasBackground {
+		return true
+	}
+
+	return m.metric.distanceFrom(m.ref, color.RGBA64{uint16(r), uint16(g), uint16(b), uint16(a)}) > m.tolerance
+}
+
+// maxEdgeSamples limits the number of pixels sampled along a single edge by DetectBackground.
+const maxEdgeSamples = 1024
+
+// DetectBackground guesses the background color of an image by sampling its corners and border rows and columns.
+// Samples are grouped into buckets of similar colors, the average color of the most populated bucket is returned.
+// Fully transparent samples are grouped together, if they dominate color.Transparent is returned.
+func DetectBackground(img image.Image) color.Color {
+	type bucket struct {
+		count      int
+		r, g, b, a uint64
+	}
+
+	b := img.Bounds()
+	if b.Empty() {
+		return color.Transparent
+	}
+
+	buckets := map[uint32]*bucket{}
+	best := &bucket{}
+
+	sample := func(x, y int) {
+		r, g, bl, a := img.At(x, y).RGBA()
+
+		// 4 most significant bits of each channel, all transparent pixels share bucket 0
+		key := uint32(0)
+		if a != 0 {
+			key = (r>>12)<<12 | (g>>12)<<8 | (bl>>12)<<4 | a>>12 | 1<<16
+		}
+
+		bk, ok := buckets[key]
+		if !ok {
+			bk = &bucket{}
+			buckets[key] = bk
+		}
+
+		bk.count++
+		bk.r += uint64(r)
+		bk.g += uint64(g)
+		bk.b += uint64(bl)
+		bk.a += uint64(a)
+
+		if bk.count > best.count {
+			best = bk
+		}
+	}
+
+	// corners are weighted more heavily than the rest of the border
+	for _, p := range []image.Point{b.Min, {b.Max.X - 1, b.Min.Y}, {b.Min.X, b.Max.Y - 1}, {b.Max.X - 1, b.Max.Y - 1}} {
+		for n := 0; n < 4; n++ {
+			sample(p.X, p.Y)
+		}
+	}
+
+	xStep := edgeStep(b.Dx())
+	for x := b.Min.X; x < b.Max.X; x += xStep {
+		sample(x, b.Min.Y)
+		sample(x, b.Max.Y-1)
+	}
+
+	yStep := edgeStep(b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y += yStep {
+		sample(b.Min.X, y)
+		sample(b.Max.X-1, y)
+	}
+
+	if best.a == 0 {
+		return color.Transparent
+	}
+
+	n := uint64(best.count)
+
+	return color.RGBA64{uint16(best.r / n), uint16(best.g / n), uint16(best.b / n), uint16(best.a / n)}
+}
+
+func edgeStep(length int) int {
+	if length <= maxEdgeSamples {
+		return 1
+	}
+
+	return length / maxEdgeSamples
+}
+
+var namedColors = map[string]color.Color{
+	"white":       color.White,
+	"black":       color.Black,
+	"transparent": color.Transparent,
+}
+
+// ParseColor parses a color name (white, black, transparent) or a hex color in one of
+// the forms rgb, rgba, rrggbb or rrggbbaa, optionally prefixed with "#".
+func ParseColor(s string) (color.Color, error) {
+	if c, ok := namedColors[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+
+	if len(hex) == 3 || len(hex) == 4 {
+		expanded := make([]byte, 0, len(hex)*2)
+		for i := 0; i < len(hex); i++ {
+			expanded = append(expanded, hex[i], hex[i])
+		}
+
+		hex = string(expanded)
+	}
+
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+
+	if len(hex) != 8 {
+		return nil, fmt.Errorf("%s: %w", s, ErrInvalidColor)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s, ErrInvalidColor)
+	}
+
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
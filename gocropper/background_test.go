@@ -0,0 +1,168 @@
+package gocropper_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"path"
+	"testing"
+
+	"github.com/H3Cki/gocrop/gocropper"
+	"github.com/stretchr/testify/assert"
+)
+
+func opaqueImage(bg, fg color.Color, content image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	draw.Draw(img, content, image.NewUniform(fg), image.Point{}, draw.Src)
+
+	return img
+}
+
+func TestCropper_RectBackground(t *testing.T) {
+	tests := []struct {
+		name      string
+		bg        color.Color
+		fg        color.Color
+		tolerance float64
+		content   image.Rectangle
+		exRect    image.Rectangle
+	}{
+		{"white", color.White, color.Black, 0, image.Rect(25, 30, 75, 70), image.Rect(25, 30, 75, 70)},
+		{"black", color.Black, color.White, 0, image.Rect(10, 0, 20, 100), image.Rect(10, 0, 20, 100)},
+		{"within tolerance", color.White, color.RGBA{250, 250, 250, 255}, 10, image.Rect(5, 5, 6, 6), image.Rect(0, 0, 100, 100)},
+		{"outside tolerance", color.White, color.RGBA{240, 240, 240, 255}, 10, image.Rect(5, 5, 6, 6), image.Rect(5, 5, 6, 6)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cropper, err := gocropper.NewCropper(gocropper.WithBackgroundColor(tt.bg, tt.tolerance))
+			assert.NoError(t, err)
+
+			img := opaqueImage(tt.bg, tt.fg, tt.content)
+			assert.Equal(t, tt.exRect, cropper.Rect(img))
+		})
+	}
+}
+
+func TestCropper_CropBackgroundPadding(t *testing.T) {
+	cropper, err := gocropper.NewCropper(
+		gocropper.WithBackgroundColor(color.White, 0),
+		gocropper.WithPadding(5),
+	)
+	assert.NoError(t, err)
+
+	img := opaqueImage(color.White, color.Black, image.Rect(0, 0, 10, 10))
+
+	cropped, ok := cropper.Crop(&gocropper.Croppable{Image: img})
+	assert.True(t, ok)
+	assert.Equal(t, image.Rect(-5, -5, 15, 15), cropped.Image.Bounds())
+
+	r, g, b, a := cropped.Image.At(-5, -5).RGBA()
+	assert.Equal(t, [4]uint32{0xffff, 0xffff, 0xffff, 0xffff}, [4]uint32{r, g, b, a})
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		s       string
+		exColor color.Color
+		exErr   error
+	}{
+		{"white", color.White, nil},
+		{"Transparent", color.Transparent, nil},
+		{"#fff", color.NRGBA{255, 255, 255, 255}, nil},
+		{"f008", color.NRGBA{255, 0, 0, 136}, nil},
+		{"#10ff20", color.NRGBA{16, 255, 32, 255}, nil},
+		{"10ff2080", color.NRGBA{16, 255, 32, 128}, nil},
+		{"#12345", nil, gocropper.ErrInvalidColor},
+		{"#gggggg", nil, gocropper.ErrInvalidColor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			c, err := gocropper.ParseColor(tt.s)
+			assert.ErrorIs(t, err, tt.exErr)
+			assert.Equal(t, tt.exColor, c)
+		})
+	}
+}
+
+func TestDetectBackground(t *testing.T) {
+	withBorder := opaqueImage(color.White, color.Black, image.Rect(10, 10, 90, 90))
+	draw.Draw(withBorder, image.Rect(0, 0, 100, 3), image.NewUniform(color.RGBA{255, 0, 0, 255}), image.Point{}, draw.Src)
+
+	tests := []struct {
+		name    string
+		img     image.Image
+		exColor color.Color
+	}{
+		{"white", opaqueImage(color.White, color.Black, image.Rect(25, 30, 75, 70)), color.RGBA64{0xffff, 0xffff, 0xffff, 0xffff}},
+		{"black", opaqueImage(color.Black, color.White, image.Rect(0, 0, 10, 100)), color.RGBA64{0, 0, 0, 0xffff}},
+		{"dominant", withBorder, color.RGBA64{0xffff, 0xffff, 0xffff, 0xffff}},
+		{"transparent", image.NewNRGBA(image.Rect(0, 0, 10, 10)), color.Transparent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.exColor, gocropper.DetectBackground(tt.img))
+		})
+	}
+}
+
+func TestCropper_RectAutoBackground(t *testing.T) {
+	cropper, err := gocropper.NewCropper(gocropper.WithAutoBackground(0))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		fn     string
+		exRect image.Rectangle
+	}{
+		{"circle-25-25-75-75.png", image.Rect(25, 25, 75, 75)},
+		{"rect-25-30-75-70.png", image.Rect(25, 30, 75, 70)},
+		{"line1px-49-0-50-100.gif", image.Rect(49, 0, 50, 100)},
+		// opaque formats, the shapes are composited over a background color
+		{"circle-25-25-75-75.ppm", image.Rect(25, 25, 75, 75)},
+		{"rect-25-30-75-70.pgm", image.Rect(25, 30, 75, 70)},
+		{"rect-25-30-75-70.pbm", image.Rect(25, 30, 75, 70)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fn, func(t *testing.T) {
+			croppable, err := gocropper.Load(path.Join("testdata/described", tt.fn))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.exRect, cropper.Rect(croppable.Image))
+		})
+	}
+
+	img := opaqueImage(color.Black, color.White, image.Rect(40, 20, 60, 80))
+	assert.Equal(t, image.Rect(40, 20, 60, 80), cropper.Rect(img))
+}
+
+func TestCropper_RectMetric(t *testing.T) {
+	img := opaqueImage(color.White, color.RGBA{250, 250, 250, 255}, image.Rect(5, 5, 6, 6))
+	draw.Draw(img, image.Rect(40, 40, 60, 60), image.NewUniform(color.RGBA{255, 240, 240, 255}), image.Point{}, draw.Src)
+
+	tests := []struct {
+		metric    gocropper.Metric
+		tolerance float64
+		exRect    image.Rectangle
+	}{
+		{gocropper.MetricRGB, 5, image.Rect(5, 5, 60, 60)},
+		{gocropper.MetricRGB, 25, image.Rect(0, 0, 100, 100)},
+		{gocropper.MetricMaxChannel, 5, image.Rect(40, 40, 60, 60)},
+		{gocropper.MetricCIE76, 2, image.Rect(40, 40, 60, 60)},
+		{gocropper.MetricCIEDE2000, 2, image.Rect(40, 40, 60, 60)},
+		{gocropper.MetricCIEDE2000, 10, image.Rect(0, 0, 100, 100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.metric.String(), func(t *testing.T) {
+			cropper, err := gocropper.NewCropper(
+				gocropper.WithBackgroundColor(color.White, tt.tolerance),
+				gocropper.WithMetric(tt.metric),
+			)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.exRect, cropper.Rect(img))
+		})
+	}
+}
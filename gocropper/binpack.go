@@ -0,0 +1,219 @@
+package gocropper
+
+import "image"
+
+// bin places rectangles in an area of fixed size.
+type bin interface {
+	// insert places a rectangle of size w x h, rotated by 90 degrees if rotate is true and it fits better,
+	// returns the occupied rectangle and whether it was rotated, false if it doesn't fit.
+	insert(w, h int, rotate bool) (image.Rectangle, bool, bool)
+}
+
+// maxRectsBin places rectangles with the MaxRects algorithm using the best short side fit heuristic.
+// It keeps the list of maximal free rectangles, which may overlap, and places every rectangle in the free rectangle
+// where the shorter leftover side is the smallest.
+type maxRectsBin struct {
+	free []image.Rectangle
+}
+
+func newMaxRectsBin(w, h int) *maxRectsBin {
+	return &maxRectsBin{free: []image.Rectangle{image.Rect(0, 0, w, h)}}
+}
+
+func (b *maxRectsBin) insert(w, h int, rotate bool) (image.Rectangle, bool, bool) {
+	var (
+		best                image.Rectangle
+		bestRotated, found  bool
+		bestShort, bestLong int
+	)
+
+	try := func(f image.Rectangle, w, h int, rotated bool) {
+		if w > f.Dx() || h > f.Dy() {
+			return
+		}
+
+		dx, dy := f.Dx()-w, f.Dy()-h
+		short, long := dx, dy
+		if short > long {
+			short, long = long, short
+		}
+
+		if !found || short < bestShort || (short == bestShort && long < bestLong) {
+			best = image.Rect(f.Min.X, f.Min.Y, f.Min.X+w, f.Min.Y+h)
+			bestRotated, bestShort, bestLong, found = rotated, short, long, true
+		}
+	}
+
+	for _, f := range b.free {
+		try(f, w, h, false)
+
+		if rotate && w != h {
+			try(f, h, w, true)
+		}
+	}
+
+	if !found {
+		return image.Rectangle{}, false, false
+	}
+
+	b.place(best)
+
+	return best, bestRotated, true
+}
+
+// place splits the free rectangles overlapping r into the maximal rectangles around it and drops the ones contained in others.
+func (b *maxRectsBin) place(r image.Rectangle) {
+	var free []image.Rectangle
+
+	for _, f := range b.free {
+		if !f.Overlaps(r) {
+			free = append(free, f)
+			continue
+		}
+
+		if r.Min.X > f.Min.X {
+			free = append(free, image.Rect(f.Min.X, f.Min.Y, r.Min.X, f.Max.Y))
+		}
+
+		if r.Max.X < f.Max.X {
+			free = append(free, image.Rect(r.Max.X, f.Min.Y, f.Max.X, f.Max.Y))
+		}
+
+		if r.Min.Y > f.Min.Y {
+			free = append(free, image.Rect(f.Min.X, f.Min.Y, f.Max.X, r.Min.Y))
+		}
+
+		if r.Max.Y < f.Max.Y {
+			free = append(free, image.Rect(f.Min.X, r.Max.Y, f.Max.X, f.Max.Y))
+		}
+	}
+
+	b.free = b.free[:0]
+
+	for n, f := range free {
+		contained := false
+
+		for m, g := range free {
+			// of two equal rectangles only the first one is kept
+			if n != m && f.In(g) && (!f.Eq(g) || m < n) {
+				contained = true
+				break
+			}
+		}
+
+		if !contained {
+			b.free = append(b.free, f)
+		}
+	}
+}
+
+// skylineBin places rectangles with the skyline bottom-left algorithm. It keeps the top edge of the placed rectangles
+// as a list of horizontal segments and places every rectangle where its top edge ends up the lowest.
+type skylineBin struct {
+	width, height int
+	skyline       []skylineSegment
+}
+
+type skylineSegment struct {
+	x, y, w int
+}
+
+func newSkylineBin(w, h int) *skylineBin {
+	return &skylineBin{width: w, height: h, skyline: []skylineSegment{{0, 0, w}}}
+}
+
+func (b *skylineBin) insert(w, h int, rotate bool) (image.Rectangle, bool, bool) {
+	var (
+		best               image.Rectangle
+		bestIdx            int
+		bestRotated, found bool
+	)
+
+	try := func(idx, w, h int, rotated bool) {
+		y, ok := b.fit(idx, w, h)
+		if !ok {
+			return
+		}
+
+		r := image.Rect(b.skyline[idx].x, y, b.skyline[idx].x+w, y+h)
+		if !found || r.Max.Y < best.Max.Y || (r.Max.Y == best.Max.Y && r.Min.X < best.Min.X) {
+			best, bestIdx, bestRotated, found = r, idx, rotated, true
+		}
+	}
+
+	for idx := range b.skyline {
+		try(idx, w, h, false)
+
+		if rotate && w != h {
+			try(idx, h, w, true)
+		}
+	}
+
+	if !found {
+		return image.Rectangle{}, false, false
+	}
+
+	b.place(bestIdx, best)
+
+	return best, bestRotated, true
+}
+
+// fit returns the lowest y at which a rectangle starting at the segment at idx rests on the skyline, false if it doesn't fit.
+func (b *skylineBin) fit(idx, w, h int) (int, bool) {
+	if b.skyline[idx].x+w > b.width {
+		return 0, false
+	}
+
+	y := 0
+
+	for left := w; left > 0; idx++ {
+		if b.skyline[idx].y > y {
+			y = b.skyline[idx].y
+		}
+
+		if y+h > b.height {
+			return 0, false
+		}
+
+		left -= b.skyline[idx].w
+	}
+
+	return y, true
+}
+
+// place raises the skyline under r, which starts at the segment at idx.
+func (b *skylineBin) place(idx int, r image.Rectangle) {
+	segment := skylineSegment{r.Min.X, r.Max.Y, r.Dx()}
+	b.skyline = append(b.skyline[:idx], append([]skylineSegment{segment}, b.skyline[idx:]...)...)
+
+	// shrink or drop the segments covered by the new one
+	for n := idx + 1; n < len(b.skyline); {
+		prev, s := b.skyline[n-1], &b.skyline[n]
+		overlap := prev.x + prev.w - s.x
+
+		if overlap <= 0 {
+			break
+		}
+
+		if overlap < s.w {
+			s.x += overlap
+			s.w -= overlap
+
+			break
+		}
+
+		b.skyline = append(b.skyline[:n], b.skyline[n+1:]...)
+	}
+
+	// merge neighbouring segments of the same height
+	for n := 1; n < len(b.skyline); {
+		if b.skyline[n-1].y == b.skyline[n].y {
+			b.skyline[n-1].w += b.skyline[n].w
+			b.skyline = append(b.skyline[:n], b.skyline[n+1:]...)
+
+			continue
+		}
+
+		n++
+	}
+}
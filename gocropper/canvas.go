@@ -0,0 +1,147 @@
+package gocropper
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"reflect"
+)
+
+// padImage returns an image with bounds of padded containing the part of the image inside rect,
+// the rest of the image is filled with the fill color. See newCanvas for the type of the returned image.
+func padImage(img image.Image, rect, padded image.Rectangle, fill color.Color) draw.Image {
+	canvas := newCanvas(img, padded, fill)
+
+	if !copyPixels(canvas, img, rect) {
+		draw.Draw(canvas, rect, img, rect.Min, draw.Src)
+	}
+
+	return canvas
+}
+
+// newCanvas returns an image with bounds r filled with the fill color. If the fill color can be represented by the color model
+// of the image, the returned image has the same concrete type, bit depth and palette, with the fill color appended to the palette if missing.
+// Otherwise it has a type able to hold both the pixels of the image and the fill color without loss of precision,
+// e.g. *image.Gray16 padded with transparency becomes *image.NRGBA64.
+func newCanvas(img image.Image, r image.Rectangle, fill color.Color) draw.Image {
+	_, _, _, a := fill.RGBA()
+	opaque := a == 0xffff
+
+	var canvas draw.Image
+
+	switch src := img.(type) {
+	case *image.Paletted:
+		if palette, idx, ok := paletteIndex(src.Palette, fill); ok {
+			p := image.NewPaletted(r, palette)
+			for i := range p.Pix {
+				p.Pix[i] = idx
+			}
+
+			return p
+		}
+
+		canvas = image.NewRGBA64(r)
+	case *image.Gray:
+		if opaque && isGray(fill) {
+			canvas = image.NewGray(r)
+		} else {
+			canvas = image.NewNRGBA(r)
+		}
+	case *image.Gray16:
+		if opaque && isGray(fill) {
+			canvas = image.NewGray16(r)
+		} else {
+			canvas = image.NewNRGBA64(r)
+		}
+	case *image.Alpha:
+		if a == 0 {
+			canvas = image.NewAlpha(r)
+		} else {
+			canvas = image.NewNRGBA(r)
+		}
+	case *image.Alpha16:
+		if a == 0 {
+			canvas = image.NewAlpha16(r)
+		} else {
+			canvas = image.NewNRGBA64(r)
+		}
+	case *image.CMYK:
+		if opaque {
+			canvas = image.NewCMYK(r)
+		} else {
+			canvas = image.NewRGBA64(r)
+		}
+	case *image.RGBA, *image.YCbCr:
+		canvas = image.NewRGBA(r)
+	case *image.NRGBA:
+		canvas = image.NewNRGBA(r)
+	case *image.NRGBA64:
+		canvas = image.NewNRGBA64(r)
+	default:
+		canvas = image.NewRGBA64(r)
+	}
+
+	// new images are transparent
+	if a != 0 {
+		draw.Draw(canvas, r, image.NewUniform(fill), image.Point{}, draw.Src)
+	}
+
+	return canvas
+}
+
+func isGray(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r == g && g == b
+}
+
+// copyPixels copies the pixels inside r from src to dst without conversion, returns false if the images have different types.
+func copyPixels(dst, src image.Image, r image.Rectangle) bool {
+	if reflect.TypeOf(dst) != reflect.TypeOf(src) {
+		return false
+	}
+
+	dstPix, dstStride, bpp, ok := pixBuffer(dst)
+	if !ok {
+		return false
+	}
+
+	srcPix, srcStride, _, _ := pixBuffer(src)
+	db, sb := dst.Bounds(), src.Bounds()
+	n := r.Dx() * bpp
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		d := (y-db.Min.Y)*dstStride + (r.Min.X-db.Min.X)*bpp
+		s := (y-sb.Min.Y)*srcStride + (r.Min.X-sb.Min.X)*bpp
+		copy(dstPix[d:d+n], srcPix[s:s+n])
+	}
+
+	return true
+}
+
+// pixBuffer returns the pixel buffer of images storing pixels in a single slice, along with its stride and bytes per pixel.
+func pixBuffer(img image.Image) (pix []byte, stride, bpp int, ok bool) {
+	switch p := img.(type) {
+	case *image.Paletted:
+		return p.Pix, p.Stride, 1, true
+	case *image.Gray:
+		return p.Pix, p.Stride, 1, true
+	case *image.Alpha:
+		return p.Pix, p.Stride, 1, true
+	case *image.Gray16:
+		return p.Pix, p.Stride, 2, true
+	case *image.Alpha16:
+		return p.Pix, p.Stride, 2, true
+	case *image.RGBA:
+		return p.Pix, p.Stride, 4, true
+	case *image.NRGBA:
+		return p.Pix, p.Stride, 4, true
+	case *image.CMYK:
+		return p.Pix, p.Stride, 4, true
+	case *image.RGBA64:
+		return p.Pix, p.Stride, 8, true
+	case *image.NRGBA64:
+		return p.Pix, p.Stride, 8, true
+	default:
+		return nil, 0, 0, false
+	}
+}
@@ -0,0 +1,54 @@
+package gocropper
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPadImage(t *testing.T) {
+	b := image.Rect(0, 0, 4, 4)
+	rect := image.Rect(1, 1, 3, 3)
+	padded := image.Rect(-1, -1, 5, 5)
+	white := color.RGBA64{0xffff, 0xffff, 0xffff, 0xffff}
+	red := color.RGBA64{0xffff, 0, 0, 0xffff}
+
+	tests := []struct {
+		name   string
+		img    image.Image
+		pixel  color.Color
+		fill   color.Color
+		exType image.Image
+	}{
+		{"gray16 white", image.NewGray16(b), color.Gray16{0x1234}, white, &image.Gray16{}},
+		{"gray16 red", image.NewGray16(b), color.Gray16{0x1234}, red, &image.NRGBA64{}},
+		{"gray16 transparent", image.NewGray16(b), color.Gray16{0x1234}, color.Transparent, &image.NRGBA64{}},
+		{"gray transparent", image.NewGray(b), color.Gray{0x12}, color.Transparent, &image.NRGBA{}},
+		{"nrgba64", image.NewNRGBA64(b), color.NRGBA64{0x1234, 0x5678, 0x9abc, 0x0101}, color.Transparent, &image.NRGBA64{}},
+		{"rgba64", image.NewRGBA64(b), color.RGBA64{0x0100, 0x0080, 0x0001, 0x0101}, white, &image.RGBA64{}},
+		{"nrgba", image.NewNRGBA(b), color.NRGBA{0x12, 0x34, 0x56, 0x01}, red, &image.NRGBA{}},
+		{"cmyk", image.NewCMYK(b), color.CMYK{1, 2, 3, 4}, white, &image.CMYK{}},
+		{"cmyk transparent", image.NewCMYK(b), color.CMYK{1, 2, 3, 4}, color.Transparent, &image.RGBA64{}},
+		{"paletted", image.NewPaletted(b, palette.WebSafe), palette.WebSafe[7], color.Transparent, &image.Paletted{}},
+		{"paletted full", image.NewPaletted(b, palette.Plan9), palette.Plan9[7], color.Transparent, &image.RGBA64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.img.(interface{ Set(x, y int, c color.Color) }).Set(2, 2, tt.pixel)
+
+			out := padImage(tt.img, rect, padded, tt.fill)
+
+			assert.IsType(t, tt.exType, out)
+			assert.Equal(t, padded, out.Bounds())
+			assert.Equal(t, color.RGBA64Model.Convert(tt.fill), color.RGBA64Model.Convert(out.At(-1, -1)))
+			assert.Equal(t, color.NRGBA64Model.Convert(tt.img.At(2, 2)), color.NRGBA64Model.Convert(out.At(2, 2)))
+
+			// pixels outside rect are padding
+			assert.Equal(t, color.RGBA64Model.Convert(tt.fill), color.RGBA64Model.Convert(out.At(0, 0)))
+		})
+	}
+}
@@ -1,27 +1,44 @@
 package gocropper
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
-	"image/draw"
+	"image/color"
+	"image/gif"
 	"io"
 	"os"
 	"path"
-	"path/filepath"
 	"sync"
 )
 
 // Cropper crops and saves images.
 type Cropper struct {
-	threshold     uint32
-	outPrefix     string
-	outSuffix     string
-	outDir        string
-	skipUnchanged bool
-	padding       int
-	enumerate     bool
-	num           int
-	numMu         sync.Mutex
+	threshold      uint32
+	background     color.Color
+	autoBackground bool
+	tolerance      float64
+	metric         Metric
+	workers        int
+	streaming      bool
+	outPrefix      string
+	outSuffix      string
+	outDir         string
+	skipUnchanged  bool
+	padding        int
+	jpegQuality    int
+	gifColors      int
+	fallback       string
+	dither         Dither
+	sharedIconRect bool
+	enumerate      bool
+	num            int
+	numMu          sync.Mutex
+	sidecar        MetadataFormat
+	collect        bool
+	metadata       []Metadata
+	metadataMu     sync.Mutex
 }
 
 // NewCropper creates an instance of *Cropped with provided options,
@@ -47,37 +64,64 @@ func NewCropper(options ...CropperOption) (*Cropper, error) {
 
 // Crop takes a *Croppable and returns a cropped version of it and a bool flag indicating if cropping was done.
 // If cropping would make no changes to given *Croppable, the provided *Croppable is returned back with false flag.
+// The cropped image keeps the coordinate space of the source image, its bounds are the cropping rectangle extended by padding.
+// If the padding reaches beyond the source image, the cropped image is a new image of the same type, e.g. 16-bit images stay 16-bit
+// and paletted images keep their palette. Types which can't hold the padding color are converted to a type which can, without loss of precision.
+// All frames of animated GIFs and PNGs are cropped, see GIFRect and APNGRect, and all entries of icons and cursors, see IconRects.
 func (i *Cropper) Crop(croppable *Croppable) (*Croppable, bool) {
-	rect := i.Rect(croppable.Image)
+	cropped, _, ok := i.crop(croppable)
+	return cropped, ok
+}
 
-	if i.padding == 0 {
-		if rect.Size().Eq(croppable.Image.Bounds().Size()) {
-			return croppable, false
-		}
+// crop crops the croppable like Crop and also returns the cropping rectangle, the rectangle of the largest entry for icons.
+func (i *Cropper) crop(croppable *Croppable) (*Croppable, image.Rectangle, bool) {
+	switch {
+	case croppable.GIF != nil:
+		rect := i.gifRect(croppable.GIF)
+		cropped, ok := i.cropGIFRect(croppable, rect)
+
+		return cropped, rect, ok
+	case croppable.APNG != nil:
+		rect := i.apngRect(croppable.APNG)
+		cropped, ok := i.cropAPNGRect(croppable, rect)
+
+		return cropped, rect, ok
+	case croppable.Icon != nil:
+		return i.cropIcon(croppable)
+	}
+
+	m := i.matcher(croppable.Image)
+	rect := i.rect(croppable.Image, m)
 
-		return croppable.With(croppable.Image.SubImage(rect).(CroppableImage)), true
+	cropped, ok := i.cropRect(croppable.Image, rect, m)
+	if !ok {
+		return croppable, rect, false
 	}
 
-	// if rect cuts deep enough it's possible to extend it and crop the image with padded rect
-	if rect.Min.X >= i.padding &&
-		rect.Min.Y >= i.padding &&
-		croppable.Image.Bounds().Dx()-rect.Max.X >= i.padding &&
-		croppable.Image.Bounds().Dy()-rect.Max.X >= i.padding {
-		rect.Min.X -= i.padding
-		rect.Min.Y -= i.padding
-		rect.Max.X += i.padding
-		rect.Max.Y += i.padding
+	return croppable.With(cropped), rect, true
+}
+
+// cropRect crops the image to given rectangle extended by padding.
+func (i *Cropper) cropRect(img CroppableImage, rect image.Rectangle, m pixelMatcher) (CroppableImage, bool) {
+	padded := rect.Inset(-i.padding)
 
-		return croppable.With(croppable.Image.SubImage(rect).(CroppableImage)), true
+	if padded.Eq(img.Bounds()) {
+		return img, false
 	}
 
-	// if rect is too small create new empty image with proper size and draw the cropped image onto it
-	bg := image.NewRGBA(image.Rect(0, 0, rect.Dx()+(2*i.padding), rect.Dy()+(2*i.padding)))
-	croppedRect := image.Rect(i.padding, i.padding, i.padding+rect.Dx(), i.padding+rect.Dy())
+	// if rect cuts deep enough it's possible to crop the image with padded rect
+	if padded.In(img.Bounds()) {
+		return img.SubImage(padded).(CroppableImage), true
+	}
 
-	draw.Draw(bg, croppedRect, croppable.Image.SubImage(rect), image.Point{rect.Min.X, rect.Min.Y}, draw.Src)
+	// otherwise create new image of the same type with proper size and copy the cropped image onto it
+	var fill color.Color = color.Transparent
+	if m.hasBackground {
+		fill = m.background
+	}
 
-	return croppable.With(bg), true
+	// the rectangle may reach beyond the image when it is shared by a group of images, see GroupRect
+	return padImage(img, rect.Intersect(img.Bounds()), padded, fill).(CroppableImage), true
 }
 
 // Save saves the croppable, creates a directory if it doesn't exist.
@@ -92,8 +136,32 @@ func (i *Cropper) Save(c *Croppable) error {
 }
 
 func (i *Cropper) save(c *Croppable) error {
+	_, err := i.saveAs(c, "")
+	return err
+}
+
+// saveAs saves the croppable with the index appended to its name, before the enumeration and the suffix, returns the output path.
+func (i *Cropper) saveAs(c *Croppable, index string) (string, error) {
 	dir, name, ext := dirFileExt(c.Path)
 
+	// images of formats without an encoder are saved in the fallback format, its extension is appended to the original one
+	// so the saved image never replaces a sibling image with the same name, e.g. photo.png next to photo.webp
+	if c.Encode == nil && i.fallback != "" {
+		coder, ok := coderByName(i.fallback)
+		if !ok || coder.encode == nil {
+			return "", fmt.Errorf("fallback %s has no encoder: %w", i.fallback, ErrUnsupportedFormat)
+		}
+
+		c = c.With(c.Image)
+		c.Format, c.Encode = coder.name, coder.encode
+		name, ext = name+ext, coder.exts[0]
+	}
+
+	encode := i.encoder(c)
+	if encode == nil {
+		return "", fmt.Errorf("%s has no encoder: %w", c.Format, ErrUnsupportedFormat)
+	}
+
 	if i.outDir != "" {
 		dir = i.outDir
 	}
@@ -103,14 +171,60 @@ func (i *Cropper) save(c *Croppable) error {
 		num = fmt.Sprintf("_%d", i.enum())
 	}
 
-	name = i.outPrefix + name + num + i.outSuffix + "." + ext
+	name = i.outPrefix + name + index + num + i.outSuffix + ext
 	outPath := path.Join(dir, name)
 
-	if err := saveImage(outPath, c.Image, c.Encode); err != nil {
+	if err := saveImage(outPath, c.Image, encode); err != nil {
+		return "", err
+	}
+
+	return outPath, nil
+}
+
+// saveManifest saves v as JSON next to the images saved from the source image, named after it with a ".json" extension.
+func (i *Cropper) saveManifest(source string, v any) error {
+	dir, name, _ := dirFileExt(source)
+	if i.outDir != "" {
+		dir = i.outDir
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return os.WriteFile(path.Join(dir, i.outPrefix+name+i.outSuffix+".json"), data, 0o644)
+}
+
+// encoder returns the encode function of the croppable, animated GIFs and PNGs are encoded with all their frames,
+// icons and cursors with all their entries, still GIFs with the palette settings and JPEG images are encoded with the quality of the cropper if it is set.
+func (i *Cropper) encoder(c *Croppable) func(w io.Writer, m image.Image) error {
+	if c.GIF != nil {
+		return encodeGIF(c.GIF)
+	}
+
+	if c.APNG != nil {
+		return encodeAPNG(c.APNG)
+	}
+
+	if c.Icon != nil {
+		return encodeIcon(c.Icon)
+	}
+
+	if c.is(gifCoder) {
+		colors := i.gifColors
+		if colors == 0 {
+			colors = 256
+		}
+
+		return gifEncoder(colors, i.dither)
+	}
+
+	if c.is(jpegCoder) && i.jpegQuality != 0 {
+		return jpegEncoder(i.jpegQuality)
+	}
+
+	return c.Encode
 }
 
 func (i *Cropper) enum() int {
@@ -125,119 +239,163 @@ func (i *Cropper) enum() int {
 // CropAndSave crops an image and saves it, output directory will be created if it does not exist.
 // Error will be returned if the image was not saved successfully.
 func (i *Cropper) CropAndSave(croppable *Croppable) error {
+	_, err := i.cropAndSave(croppable)
+	return err
+}
+
+func (i *Cropper) cropAndSave(croppable *Croppable) (bool, error) {
+	cropped, rect, ok := i.crop(croppable)
+	return i.saveCropped(croppable, cropped, rect, ok)
+}
+
+// saveCropped saves the result of cropping the source to rect, unchanged images are skipped if WithSkipUnchanged is enabled.
+// Metadata of the saved image is saved or collected if enabled, see WithSidecar and WithCollectMetadata.
+func (i *Cropper) saveCropped(source, cropped *Croppable, rect image.Rectangle, ok bool) (bool, error) {
 	if i.outDir != "" {
 		if err := os.MkdirAll(i.outDir, os.ModePerm); err != nil {
-			return err
+			return false, err
 		}
 	}
 
-	cropped, ok := i.Crop(croppable)
 	if !ok && i.skipUnchanged {
-		return nil
+		return false, nil
 	}
 
-	if err := i.save(cropped); err != nil {
-		return fmt.Errorf("error saving image: %w", err)
+	outPath, err := i.saveAs(cropped, "")
+	if err != nil {
+		return ok, fmt.Errorf("error saving image: %w", err)
 	}
 
-	return nil
+	if err := i.saveMetadata(source, outPath, rect); err != nil {
+		return ok, fmt.Errorf("error saving metadata: %w", err)
+	}
+
+	return ok, nil
 }
 
 // Rect returns the cropping rectangle of the image, does not include padding.
+// The rectangle is in the coordinate space of the image, which does not have to start at the origin.
+// If the image has no content its bounds are returned.
 func (i *Cropper) Rect(img image.Image) image.Rectangle {
-	rect := img.Bounds()
-
-	min := image.Point{-1, -1}
-	max := image.Point{-1, -1}
-
-	wg := &sync.WaitGroup{}
-	wg.Add(2)
-
-	go func() {
-		defer wg.Done()
-
-		for y := 0; y < rect.Dy(); y++ {
-			for x := 0; x < rect.Dx(); x++ {
-				pixel := img.At(x, y)
+	return i.rect(img, i.matcher(img))
+}
 
-				_, _, _, alpha := pixel.RGBA()
+func (i *Cropper) rect(img image.Image, m pixelMatcher) image.Rectangle {
+	if rect, ok := i.content(img, m); ok {
+		return rect
+	}
 
-				if alpha > i.threshold {
-					if min.X == -1 || x < min.X {
-						min.X = x
-					}
+	return img.Bounds()
+}
 
-					if min.Y == -1 || y < min.Y {
-						min.Y = y
-					}
+// content returns the rectangle of the image content, false if the image has no content.
+func (i *Cropper) content(img image.Image, m pixelMatcher) (image.Rectangle, bool) {
+	rect := img.Bounds()
+	s := newScanner(img, m)
+	workers := i.workersFor(rect)
 
-					break
-				}
+	rowFilled := func(y int) bool {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if s.filled(x, y) {
+				return true
 			}
 		}
-	}()
 
-	go func() {
-		defer wg.Done()
-		for y := rect.Dy() - 1; y >= 0; y-- {
-			for x := rect.Dx() - 1; x >= 0; x-- {
-				pixel := img.At(x, y)
+		return false
+	}
+
+	top := firstLine(rect.Dy(), rect.Dx(), workers, func(n int) bool {
+		return rowFilled(rect.Min.Y + n)
+	})
 
-				_, _, _, alpha := pixel.RGBA()
+	if top == -1 {
+		return image.Rectangle{}, false
+	}
 
-				if alpha > i.threshold {
-					if x > max.X {
-						max.X = x + 1
-					}
+	top += rect.Min.Y
 
-					if y > max.Y {
-						max.Y = y + 1
-					}
+	// the row at top is filled, so the search from the bottom always succeeds
+	bottom := rect.Max.Y - firstLine(rect.Max.Y-top, rect.Dx(), workers, func(n int) bool {
+		return rowFilled(rect.Max.Y - 1 - n)
+	})
 
-					break
-				}
+	// columns only need to be scanned within the vertical span of the content
+	colFilled := func(x int) bool {
+		for y := top; y < bottom; y++ {
+			if s.filled(x, y) {
+				return true
 			}
 		}
-	}()
 
-	wg.Wait()
-
-	if min.Eq(image.Point{-1, -1}) {
-		min = image.Point{}
+		return false
 	}
 
-	if max.Eq(image.Point{-1, -1}) {
-		max = image.Point{rect.Dx(), rect.Dy()}
-	}
+	left := rect.Min.X + firstLine(rect.Dx(), bottom-top, workers, func(n int) bool {
+		return colFilled(rect.Min.X + n)
+	})
+
+	right := rect.Max.X - firstLine(rect.Max.X-left, bottom-top, workers, func(n int) bool {
+		return colFilled(rect.Max.X - 1 - n)
+	})
+
+	return image.Rect(left, top, right, bottom), true
+}
 
-	return image.Rectangle{
-		Min: min,
-		Max: max,
+// workersFor returns the number of goroutines used for finding the cropping rectangle of an image with given bounds.
+func (i *Cropper) workersFor(bounds image.Rectangle) int {
+	if i.workers <= 1 || bounds.Dx()*bounds.Dy() < parallelMinPixels {
+		return 1
 	}
+
+	return i.workers
 }
 
 // Croppable holds the path of the image, the image itself and a proper encoder function for encoding the image.
 type Croppable struct {
-	Path   string
+	Path string
+	// Format is the name of the image format, e.g. "png".
+	Format string
 	Image  CroppableImage
-	Decode func(r io.Reader) (image.Image, error)
-	Encode func(w io.Writer, m image.Image) error
+	// GIF holds all frames of an animated GIF image, Image is its first frame. It is nil for other images.
+	GIF *gif.GIF
+	// APNG holds all frames of an animated PNG image, Image is its default image. It is nil for other images.
+	APNG *APNG
+	// Icon holds all entries of an icon or a cursor, Image is its largest entry. It is nil for other images.
+	Icon         *Icon
+	Decode       func(r io.Reader) (image.Image, error)
+	DecodeConfig func(r io.Reader) (image.Config, error)
+	Encode       func(w io.Writer, m image.Image) error
+	// Mismatch is set if the content of the file does not match the format of its extension, Format is the format of the content.
+	Mismatch *FormatMismatchError
+	// registered is true if the croppable uses a format registered with RegisterFormat.
+	registered bool
 }
 
-// Load validates if given image format is supported, if so
-// creates a *Croppable and calls it's Load() method.
-func Load(path string) (*Croppable, error) {
-	ext := filepath.Ext(path)
+// is reports whether the croppable uses the builtin format of the coder, not a registered format with the same name.
+func (c *Croppable) is(ic imageCoder) bool {
+	return !c.registered && c.Format == ic.name
+}
 
-	coder, ok := imageCoders[ext]
-	if !ok {
-		return nil, ErrUnsupportedFormat
+// NewCroppable detects the format of the image from its extension and content, see RegisterFormat.
+// If the format is supported creates a *Croppable ready to be loaded, does not load the image.
+// If the content does not match the extension, the croppable uses the format of the content and Mismatch is set.
+func NewCroppable(path string) (*Croppable, error) {
+	coder, mismatch, err := detectFormat(path)
+	if err != nil {
+		return nil, err
 	}
 
-	c := &Croppable{
-		Path:   path,
-		Decode: coder.decode,
-		Encode: coder.encode,
+	c := coder.croppable(path)
+	c.Mismatch = mismatch
+
+	return c, nil
+}
+
+// Load creates a *Croppable using NewCroppable and calls it's Load() method.
+func Load(path string) (*Croppable, error) {
+	c, err := NewCroppable(path)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := c.Load(); err != nil {
@@ -257,6 +415,30 @@ func (c *Croppable) Load() error {
 
 	defer file.Close()
 
+	if c.is(gifCoder) {
+		if err := c.loadGIF(file); err != nil {
+			return fmt.Errorf("%s: %w", err.Error(), ErrImageLoadFailed)
+		}
+
+		return nil
+	}
+
+	if c.is(pngCoder) {
+		if err := c.loadAPNG(file); err != nil {
+			return fmt.Errorf("%s: %w", err.Error(), ErrImageLoadFailed)
+		}
+
+		return nil
+	}
+
+	if c.is(icoCoder) || c.is(curCoder) {
+		if err := c.loadIcon(file); err != nil {
+			return fmt.Errorf("%s: %w", err.Error(), ErrImageLoadFailed)
+		}
+
+		return nil
+	}
+
 	img, err := c.Decode(file)
 	if err != nil {
 		return fmt.Errorf("%s: %w", err.Error(), ErrImageLoadFailed)
@@ -272,16 +454,85 @@ func (c *Croppable) Load() error {
 	return nil
 }
 
+// Config decodes the color model and dimensions of the image without decoding the entire image.
+func (c *Croppable) Config() (image.Config, error) {
+	if c.DecodeConfig == nil {
+		return image.Config{}, ErrUnsupportedFormat
+	}
+
+	file, err := os.Open(c.Path)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	defer file.Close()
+
+	cfg, err := c.DecodeConfig(file)
+	if err != nil {
+		return image.Config{}, fmt.Errorf("%s: %w", err.Error(), ErrImageLoadFailed)
+	}
+
+	return cfg, nil
+}
+
 // With returns a copy of current croppable with Image set to provided image.
+// Frames of animated GIFs and PNGs and entries of icons are not copied, the copy holds a single image.
 func (c *Croppable) With(ci CroppableImage) *Croppable {
 	return &Croppable{
-		Path:   c.Path,
-		Image:  ci,
-		Decode: c.Decode,
-		Encode: c.Encode,
+		Path:         c.Path,
+		Format:       c.Format,
+		Image:        ci,
+		Mismatch:     c.Mismatch,
+		Decode:       c.Decode,
+		DecodeConfig: c.DecodeConfig,
+		Encode:       c.Encode,
+		registered:   c.registered,
 	}
 }
 
+// withGIF returns a copy of current croppable holding the animated GIF.
+func (c *Croppable) withGIF(g *gif.GIF) *Croppable {
+	cc := c.With(g.Image[0])
+	cc.GIF = g
+
+	return cc
+}
+
+// withAPNG returns a copy of current croppable holding the animated PNG.
+func (c *Croppable) withAPNG(a *APNG) *Croppable {
+	cc := c.With(a.image())
+	cc.APNG = a
+
+	return cc
+}
+
+// withIcon returns a copy of current croppable holding the icon.
+func (c *Croppable) withIcon(ic *Icon) *Croppable {
+	cc := c.With(ic.largest())
+	cc.Icon = ic
+
+	return cc
+}
+
+// withLoaded loads the croppable, calls fn and unloads it after fn returns.
+func withLoaded(c *Croppable, fn func(c *Croppable) error) error {
+	if err := c.Load(); err != nil {
+		return err
+	}
+
+	defer c.unload()
+
+	return fn(c)
+}
+
+// unload releases the decoded image of the croppable.
+func (c *Croppable) unload() {
+	c.Image = nil
+	c.GIF = nil
+	c.APNG = nil
+	c.Icon = nil
+}
+
 // Croppable image is an extension of image.Image interface to ensure the image is croppable.
 type CroppableImage interface {
 	image.Image
@@ -299,8 +550,82 @@ func WithThreshold(threshold uint32) CropperOption {
 	}
 }
 
+// WithBackgroundColor treats pixels similar to the given color as empty, allowing opaque images to be cropped.
+// A pixel is considered empty if its distance from the background color does not exceed the tolerance,
+// the distance is measured with the Metric set by WithMetric, MetricRGB by default. Padding added around the cropped image is filled with the background color.
+func WithBackgroundColor(background color.Color, tolerance float64) CropperOption {
+	return func(c *Cropper) error {
+		if tolerance < 0 {
+			return errors.New("tolerance cannot be negative")
+		}
+
+		c.background = background
+		c.autoBackground = false
+		c.tolerance = tolerance
+
+		return nil
+	}
+}
+
+// WithAutoBackground detects the background color of every cropped image using DetectBackground
+// and treats pixels similar to it as empty, like WithBackgroundColor does.
+// If the detected background is transparent only the alpha threshold is used.
+func WithAutoBackground(tolerance float64) CropperOption {
+	return func(c *Cropper) error {
+		if tolerance < 0 {
+			return errors.New("tolerance cannot be negative")
+		}
+
+		c.background = nil
+		c.autoBackground = true
+		c.tolerance = tolerance
+
+		return nil
+	}
+}
+
+// WithMetric sets the Metric used to measure the distance between pixels and the background color,
+// the unit of tolerance passed to WithBackgroundColor and WithAutoBackground depends on it.
+func WithMetric(metric Metric) CropperOption {
+	return func(c *Cropper) error {
+		if _, ok := metricNames[metric]; !ok {
+			return fmt.Errorf("%s: %w", metric, ErrUnknownMetric)
+		}
+
+		c.metric = metric
+
+		return nil
+	}
+}
+
+// WithWorkers sets the maximum number of goroutines used for finding the cropping rectangle of a single image.
+// Images are scanned inward from every edge and the scan stops at the first line containing content,
+// with more than one worker large images are split into bands of lines scanned concurrently.
+// Small images are always scanned by a single goroutine.
+func WithWorkers(workers int) CropperOption {
+	return func(c *Cropper) error {
+		if workers < 1 {
+			return errors.New("number of workers must be positive")
+		}
+
+		c.workers = workers
+
+		return nil
+	}
+}
+
+// WithStreaming enables streaming mode for PNG images processed by a Processor.
+// Instead of being loaded, they are cropped and saved with StreamCropAndSave.
+func WithStreaming(enable bool) CropperOption {
+	return func(c *Cropper) error {
+		c.streaming = enable
+		return nil
+	}
+}
+
 // WithPadding sets the number of pixels to add in each direction around the cropped image.
 // If the cropped output is the size 25x25px, with 5px of padding it will be 35x35px with the cropped element centered.
+// Padded images keep the color model and bit depth of the source image when possible, see Crop.
 func WithPadding(padding int) CropperOption {
 	return func(c *Cropper) error {
 		c.padding = padding
@@ -308,6 +633,73 @@ func WithPadding(padding int) CropperOption {
 	}
 }
 
+// WithJPEGQuality sets the quality of saved JPEG images, ranging from 1 to 100 inclusive.
+// By default JPEG images are saved with jpeg.DefaultQuality.
+func WithJPEGQuality(quality int) CropperOption {
+	return func(c *Cropper) error {
+		if quality < 1 || quality > 100 {
+			return errors.New("jpeg quality must be between 1 and 100")
+		}
+
+		c.jpegQuality = quality
+
+		return nil
+	}
+}
+
+// WithGIFColors sets the maximum number of colors of the palette built for GIF images which are not paletted,
+// ranging from 2 to 256 inclusive. Paletted images keep their own palette.
+func WithGIFColors(colors int) CropperOption {
+	return func(c *Cropper) error {
+		if colors < 2 || colors > 256 {
+			return errors.New("number of gif colors must be between 2 and 256")
+		}
+
+		c.gifColors = colors
+
+		return nil
+	}
+}
+
+// WithDither sets the Dither used when GIF images which are not paletted are converted to a paletted image,
+// DitherFloydSteinberg by default.
+func WithDither(dither Dither) CropperOption {
+	return func(c *Cropper) error {
+		if _, ok := ditherNames[dither]; !ok {
+			return fmt.Errorf("%s: %w", dither, ErrUnknownDither)
+		}
+
+		c.dither = dither
+
+		return nil
+	}
+}
+
+// WithSharedIconRect crops all entries of icons and cursors to the same proportions instead of cropping each entry to its own content,
+// so entries of different sizes stay consistent, see IconRects.
+func WithSharedIconRect(shared bool) CropperOption {
+	return func(c *Cropper) error {
+		c.sharedIconRect = shared
+		return nil
+	}
+}
+
+// WithFallbackFormat sets the name of the format used for saving images of formats without an encoder, e.g. "png" for WebP images.
+// The first extension of the fallback format is appended to the names of saved images, "image.webp" is saved as "image.webp.png".
+// Without a fallback format such images can't be saved.
+func WithFallbackFormat(name string) CropperOption {
+	return func(c *Cropper) error {
+		coder, ok := coderByName(name)
+		if !ok || coder.encode == nil {
+			return fmt.Errorf("%s has no encoder: %w", name, ErrUnsupportedFormat)
+		}
+
+		c.fallback = name
+
+		return nil
+	}
+}
+
 // WithOutPrefix adds a prefix to an image name.
 // Given prefix "cropped_" and image file name "image1.png", the output image will be named "cropped_image1.png".
 func WithOutPrefix(prefix string) CropperOption {
@@ -351,3 +743,25 @@ func WithEnumerate(enumerate bool) CropperOption {
 		return nil
 	}
 }
+
+// WithSidecar saves the Metadata of every cropped image next to it in the given format, named after the saved image
+// with the extension of the format appended, e.g. sprite.png.json. MetadataNone disables sidecars.
+func WithSidecar(format MetadataFormat) CropperOption {
+	return func(c *Cropper) error {
+		if _, ok := metadataFormatNames[format]; !ok {
+			return fmt.Errorf("%s: %w", format, ErrUnknownMetadataFormat)
+		}
+
+		c.sidecar = format
+
+		return nil
+	}
+}
+
+// WithCollectMetadata keeps the Metadata of all cropped images, e.g. to save a single manifest of a batch with SaveMetadata.
+func WithCollectMetadata(collect bool) CropperOption {
+	return func(c *Cropper) error {
+		c.collect = collect
+		return nil
+	}
+}
@@ -2,6 +2,8 @@ package gocropper_test
 
 import (
 	"image"
+	"image/color"
+	"image/draw"
 	"path"
 	"sync"
 	"testing"
@@ -19,7 +21,7 @@ func TestLoad(t *testing.T) {
 		{"blank.png", nil},
 		{"circle.png", nil},
 		{"line.gif", nil},
-		{"white.jpg", gocropper.ErrUnsupportedFormat},
+		{"white.jpg", nil},
 		{"rect.png", nil},
 	}
 
@@ -70,6 +72,16 @@ func TestCropper_Rect(t *testing.T) {
 			fn:      "line1px-49-0-50-100.gif",
 			exRect:  image.Rect(49, 0, 50, 100),
 		},
+		{
+			cropper: basicCropper,
+			fn:      "circle-25-25-75-75.qoi",
+			exRect:  image.Rect(25, 25, 75, 75),
+		},
+		{
+			cropper: basicCropper,
+			fn:      "rect-25-30-75-70.pam",
+			exRect:  image.Rect(25, 30, 75, 70),
+		},
 	}
 
 	for _, tt := range tests {
@@ -130,6 +142,18 @@ func TestCropper_Crop(t *testing.T) {
 			exFn:    "line1px-49-0-50-100.gif",
 			ok:      true,
 		},
+		{
+			cropper: basicCropper,
+			fn:      "circle-25-25-75-75.qoi",
+			exFn:    "circle-25-25-75-75.qoi",
+			ok:      true,
+		},
+		{
+			cropper: basicCropper,
+			fn:      "rect-25-30-75-70.pam",
+			exFn:    "rect-25-30-75-70.pam",
+			ok:      true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -153,6 +177,78 @@ func TestCropper_Crop(t *testing.T) {
 	}
 }
 
+func TestCropper_OffsetImage(t *testing.T) {
+	croppable, err := gocropper.Load("testdata/described/circle-25-25-75-75.png")
+	assert.NoError(t, err)
+
+	offsetImg := image.NewNRGBA(image.Rect(-100, -50, 0, 50))
+	draw.Draw(offsetImg, offsetImg.Bounds(), croppable.Image, image.Point{}, draw.Src)
+
+	tests := []struct {
+		name      string
+		img       gocropper.CroppableImage
+		padding   int
+		exRect    image.Rectangle
+		exBounds  image.Rectangle
+		contentAt image.Point
+	}{
+		{
+			name:      "subimage",
+			img:       croppable.Image.SubImage(image.Rect(10, 10, 90, 90)).(gocropper.CroppableImage),
+			exRect:    image.Rect(25, 25, 75, 75),
+			exBounds:  image.Rect(25, 25, 75, 75),
+			contentAt: image.Pt(50, 50),
+		},
+		{
+			name:      "subimage with padding inside bounds",
+			img:       croppable.Image.SubImage(image.Rect(10, 10, 90, 90)).(gocropper.CroppableImage),
+			padding:   10,
+			exRect:    image.Rect(25, 25, 75, 75),
+			exBounds:  image.Rect(15, 15, 85, 85),
+			contentAt: image.Pt(50, 50),
+		},
+		{
+			name:      "subimage with padding outside bounds",
+			img:       croppable.Image.SubImage(image.Rect(20, 20, 80, 80)).(gocropper.CroppableImage),
+			padding:   10,
+			exRect:    image.Rect(25, 25, 75, 75),
+			exBounds:  image.Rect(15, 15, 85, 85),
+			contentAt: image.Pt(50, 50),
+		},
+		{
+			name:      "negative origin",
+			img:       offsetImg,
+			padding:   30,
+			exRect:    image.Rect(-75, -25, -25, 25),
+			exBounds:  image.Rect(-105, -55, 5, 55),
+			contentAt: image.Pt(-50, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cropper, err := gocropper.NewCropper(gocropper.WithPadding(tt.padding))
+			assert.NoError(t, err)
+
+			assert.Equal(t, tt.exRect, cropper.Rect(tt.img))
+
+			cropped, ok := cropper.Crop(croppable.With(tt.img))
+			assert.True(t, ok)
+			assert.Equal(t, tt.exBounds, cropped.Image.Bounds())
+			assert.Equal(t,
+				color.RGBA64Model.Convert(tt.img.At(tt.contentAt.X, tt.contentAt.Y)),
+				color.RGBA64Model.Convert(cropped.Image.At(tt.contentAt.X, tt.contentAt.Y)))
+
+			_, _, _, a := cropped.Image.At(tt.exBounds.Min.X, tt.exBounds.Min.Y).RGBA()
+			assert.Zero(t, a)
+
+			// cropping again makes no changes
+			_, ok = cropper.Crop(cropped)
+			assert.False(t, ok)
+		})
+	}
+}
+
 func imagesEqual(img1, img2 image.Image) bool {
 	return img1.Bounds().Size().Eq(img2.Bounds().Size())
 }
@@ -0,0 +1,22 @@
+package gocropper
+
+// RestoreFormats returns a function restoring the registered formats to their current state. Tests registering formats
+// pass it to t.Cleanup, so that other tests and repeated runs see the builtin formats only.
+func RestoreFormats() func() {
+	codersMu.RLock()
+	defer codersMu.RUnlock()
+
+	saved := make(map[string]imageCoder, len(coders))
+	for ext, ic := range coders {
+		saved[ext] = ic
+	}
+
+	savedSniffers := append([]imageCoder{}, sniffers...)
+
+	return func() {
+		codersMu.Lock()
+		defer codersMu.Unlock()
+
+		coders, sniffers = saved, savedSniffers
+	}
+}
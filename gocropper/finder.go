@@ -2,7 +2,6 @@ package gocropper
 
 import (
 	"errors"
-	"fmt"
 	"io/fs"
 	"os"
 	"path"
@@ -33,6 +32,8 @@ func NewFinder(options ...FinderOptions) (*Finder, error) {
 
 // Find takes a slice of directory paths, searches those directories for images in supported formats
 // and returns a list of croppables, ready to be loaded. Does not load images to check if they can be cropped.
+// Formats are detected from extensions and content, see NewCroppable. Croppables of files whose content does not match
+// their extension have Mismatch set.
 func (d *Finder) Find(dirs []string) ([]*Croppable, error) {
 	var loader func(string) ([]*Croppable, error)
 
@@ -49,7 +50,6 @@ func (d *Finder) Find(dirs []string) ([]*Croppable, error) {
 		p, err := loader(dir)
 		if err != nil {
 			errs = append(errs, err)
-			continue
 		}
 
 		crops = append(crops, p...)
@@ -66,19 +66,8 @@ func (i *Finder) findRecursive(dir string) ([]*Croppable, error) {
 			return nil
 		}
 
-		_, _, ext := dirFileExt(p)
-		coder, ok := imageCoders[ext]
-		if !ok {
-			return nil
-		}
-
 		if i.regex == nil || (i.regex != nil && i.regex.MatchString(d.Name())) {
-			crops = append(crops, &Croppable{
-				Path:   p,
-				Image:  nil,
-				Decode: coder.decode,
-				Encode: coder.encode,
-			})
+			crops = appendCroppable(crops, p)
 		}
 
 		return nil
@@ -96,7 +85,6 @@ func (i *Finder) findInDir(dir string) ([]*Croppable, error) {
 
 	fileInfos, err := os.ReadDir(dir)
 	if err != nil {
-		fmt.Printf("unable to read %s: %s\n", dir, err.Error())
 		return []*Croppable{}, err
 	}
 
@@ -105,25 +93,24 @@ func (i *Finder) findInDir(dir string) ([]*Croppable, error) {
 			continue
 		}
 
-		_, ext := fileExt(fi.Name())
-		coder, ok := imageCoders[ext]
-		if !ok {
-			continue
-		}
-
 		if i.regex == nil || (i.regex != nil && i.regex.MatchString(fi.Name())) {
-			crops = append(crops, &Croppable{
-				Path:   path.Join(dir, fi.Name()),
-				Image:  nil,
-				Decode: coder.decode,
-				Encode: coder.encode,
-			})
+			crops = appendCroppable(crops, path.Join(dir, fi.Name()))
 		}
 	}
 
 	return crops, nil
 }
 
+// appendCroppable appends a croppable of the file if its format is supported.
+func appendCroppable(crops []*Croppable, fp string) []*Croppable {
+	c, err := NewCroppable(fp)
+	if err != nil {
+		return crops
+	}
+
+	return append(crops, c)
+}
+
 type FinderOptions func(*Finder) error
 
 // WithRegex attempts to compile given regex string
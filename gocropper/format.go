@@ -0,0 +1,224 @@
+package gocropper
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var ErrFormatMismatch = errors.New("file extension does not match image format")
+
+// FormatMismatchError describes an image whose content does not match the format of its file extension, see Croppable.Mismatch.
+type FormatMismatchError struct {
+	Path string
+	// ExtFormat is the format registered for the file extension.
+	ExtFormat string
+	// Format is the format detected from the content.
+	Format string
+}
+
+func (e *FormatMismatchError) Error() string {
+	return fmt.Sprintf("%s: extension of %s format, content of %s format: %s", e.Path, e.ExtFormat, e.Format, ErrFormatMismatch)
+}
+
+// Is makes FormatMismatchError match ErrFormatMismatch.
+func (e *FormatMismatchError) Is(target error) bool {
+	return target == ErrFormatMismatch
+}
+
+// sniffLen is the number of bytes read from the beginning of files to detect their format.
+const sniffLen = 64
+
+var (
+	codersMu sync.RWMutex
+	// coders holds registered formats by lowercase file extension.
+	coders = map[string]imageCoder{}
+	// sniffers holds registered formats in the order of registration.
+	sniffers []imageCoder
+)
+
+// RegisterFormat registers an image format, Load, NewCroppable and Finder use it for files with the given extensions.
+// Extensions are matched with the leading dot, e.g. ".png", registering an extension again replaces its format,
+// which allows overriding the builtin formats. Extensions are case-insensitive.
+//
+// Images of a registered format are always decoded and encoded with decode and encode, even if it is named after
+// a builtin format. Frames of animated GIFs and PNGs and entries of icons are kept by the builtin formats only,
+// PNG images of a registered format are not streamed, see WithStreaming.
+//
+// magic is the prefix of encoded images of the format, "?" matches any byte. It is used to detect the format
+// of files with unknown extensions or content not matching their extension, formats registered later take precedence.
+// Formats with empty magic are detected by their extensions only.
+//
+// decodeConfig and encode can be nil, images of formats without an encoder can be loaded and cropped but not saved.
+// RegisterFormat panics if name, exts or decode are empty.
+func RegisterFormat(
+	name string,
+	exts []string,
+	magic []byte,
+	decode func(r io.Reader) (image.Image, error),
+	decodeConfig func(r io.Reader) (image.Config, error),
+	encode func(w io.Writer, m image.Image) error,
+) {
+	if name == "" || len(exts) == 0 || decode == nil {
+		panic("gocropper: RegisterFormat requires a name, extensions and a decoder")
+	}
+
+	var magics [][]byte
+	if len(magic) > 0 {
+		magics = [][]byte{magic}
+	}
+
+	registerCoder(imageCoder{
+		name:         name,
+		exts:         exts,
+		magic:        magics,
+		decode:       decode,
+		decodeConfig: decodeConfig,
+		encode:       encode,
+		registered:   true,
+	})
+}
+
+func registerCoder(ic imageCoder) {
+	codersMu.Lock()
+	defer codersMu.Unlock()
+
+	for _, ext := range ic.exts {
+		coders[normalizeExt(ext)] = ic
+	}
+
+	sniffers = append(sniffers, ic)
+}
+
+// coderByExt returns the format registered for the file extension.
+func coderByExt(ext string) (imageCoder, bool) {
+	codersMu.RLock()
+	defer codersMu.RUnlock()
+
+	ic, ok := coders[strings.ToLower(ext)]
+
+	return ic, ok
+}
+
+// coderByName returns the format registered last with the name.
+func coderByName(name string) (imageCoder, bool) {
+	codersMu.RLock()
+	defer codersMu.RUnlock()
+
+	for i := len(sniffers) - 1; i >= 0; i-- {
+		if sniffers[i].name == name {
+			return sniffers[i], true
+		}
+	}
+
+	return imageCoder{}, false
+}
+
+// sniff returns the format whose magic matches the beginning of an encoded image.
+func sniff(header []byte) (imageCoder, bool) {
+	codersMu.RLock()
+	defer codersMu.RUnlock()
+
+	for i := len(sniffers) - 1; i >= 0; i-- {
+		if sniffers[i].matches(header) {
+			return sniffers[i], true
+		}
+	}
+
+	return imageCoder{}, false
+}
+
+// detectFormat returns the format of the image at path. The format of the file extension is used if its magic matches the content
+// or it has no magic, otherwise the format is detected from the content. If the detected format differs from the format
+// of the extension, it is returned along with *FormatMismatchError.
+// If the file can't be read the format of the extension is returned, the error is reported once the image is loaded.
+func detectFormat(path string) (imageCoder, *FormatMismatchError, error) {
+	byExt, extOK := coderByExt(filepath.Ext(path))
+
+	header, err := readHeader(path)
+	if err != nil {
+		if extOK {
+			return byExt, nil, nil
+		}
+
+		return imageCoder{}, nil, ErrUnsupportedFormat
+	}
+
+	if extOK && (len(byExt.magic) == 0 || byExt.matches(header)) {
+		return byExt, nil, nil
+	}
+
+	sniffed, ok := sniff(header)
+
+	switch {
+	case ok && extOK && sniffed.name == byExt.name:
+		// e.g. a registered format overriding the extensions but not all the magic of a builtin format
+		return byExt, nil, nil
+	case ok && extOK:
+		return sniffed, &FormatMismatchError{Path: path, ExtFormat: byExt.name, Format: sniffed.name}, nil
+	case ok:
+		return sniffed, nil, nil
+	case extOK:
+		// unknown content, let the decoder report it
+		return byExt, nil, nil
+	default:
+		return imageCoder{}, nil, ErrUnsupportedFormat
+	}
+}
+
+func readHeader(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	header := make([]byte, sniffLen)
+
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return header[:n], nil
+}
+
+// matches returns true if any magic of the format matches the beginning of an encoded image.
+func (ic imageCoder) matches(header []byte) bool {
+	for _, magic := range ic.magic {
+		if matchMagic(magic, header) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchMagic(magic, header []byte) bool {
+	if len(header) < len(magic) {
+		return false
+	}
+
+	for i, b := range magic {
+		if b != '?' && header[i] != b {
+			return false
+		}
+	}
+
+	return true
+}
+
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+
+	return ext
+}
@@ -0,0 +1,261 @@
+package gocropper_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/H3Cki/gocrop/gocropper"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/bmp"
+)
+
+// g8 is a minimal format used for testing: magic, 1 byte width, 1 byte height and 8-bit alpha values.
+var g8Magic = []byte("G8")
+
+func decodeG8Config(r io.Reader) (image.Config, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return image.Config{}, err
+	}
+
+	if !bytes.HasPrefix(header, g8Magic) {
+		return image.Config{}, errors.New("not a g8 image")
+	}
+
+	return image.Config{ColorModel: color.AlphaModel, Width: int(header[2]), Height: int(header[3])}, nil
+}
+
+func decodeG8(r io.Reader) (image.Image, error) {
+	cfg, err := decodeG8Config(r)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewAlpha(image.Rect(0, 0, cfg.Width, cfg.Height))
+	_, err = io.ReadFull(r, img.Pix)
+
+	return img, err
+}
+
+func encodeG8(w io.Writer, m image.Image) error {
+	b := m.Bounds()
+	buf := append([]byte{}, g8Magic...)
+	buf = append(buf, byte(b.Dx()), byte(b.Dy()))
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			buf = append(buf, color.AlphaModel.Convert(m.At(x, y)).(color.Alpha).A)
+		}
+	}
+
+	_, err := w.Write(buf)
+
+	return err
+}
+
+func TestRegisterFormat(t *testing.T) {
+	t.Cleanup(gocropper.RestoreFormats())
+
+	gocropper.RegisterFormat("g8", []string{".g8"}, g8Magic, decodeG8, decodeG8Config, encodeG8)
+	gocropper.RegisterFormat("g8ro", []string{"G8RO"}, g8Magic, decodeG8, decodeG8Config, nil)
+
+	dir := t.TempDir()
+
+	src := image.NewAlpha(image.Rect(0, 0, 10, 8))
+	src.SetAlpha(3, 2, color.Alpha{0xff})
+	src.SetAlpha(6, 4, color.Alpha{0x80})
+
+	for _, fn := range []string{"img.g8", "img.g8ro", "img.unknown"} {
+		f, err := os.Create(path.Join(dir, fn))
+		assert.NoError(t, err)
+		assert.NoError(t, encodeG8(f, src))
+		assert.NoError(t, f.Close())
+	}
+
+	finder, _ := gocropper.NewFinder()
+	croppables, err := finder.Find([]string{dir})
+	assert.NoError(t, err)
+
+	formats := map[string]string{}
+	for _, c := range croppables {
+		formats[path.Base(c.Path)] = c.Format
+	}
+
+	// files with unknown extensions are detected by content, the format registered last takes precedence
+	assert.Equal(t, map[string]string{"img.g8": "g8", "img.g8ro": "g8ro", "img.unknown": "g8ro"}, formats)
+
+	outDir := t.TempDir()
+	cropper, _ := gocropper.NewCropper(gocropper.WithOutDir(outDir))
+
+	croppable, err := gocropper.Load(path.Join(dir, "img.g8"))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(3, 2, 7, 5), cropper.Rect(croppable.Image))
+	assert.NoError(t, cropper.CropAndSave(croppable))
+
+	saved, err := gocropper.Load(path.Join(outDir, "img.g8"))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 4, 3), saved.Image.Bounds())
+
+	// formats without an encoder can be cropped but not saved
+	readOnly, err := gocropper.Load(path.Join(dir, "img.g8ro"))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, cropper.CropAndSave(readOnly), gocropper.ErrUnsupportedFormat)
+
+	assert.Panics(t, func() { gocropper.RegisterFormat("", []string{".x"}, nil, decodeG8, nil, nil) })
+}
+
+func TestNewCroppable_Detect(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Transparent, color.Black})
+
+	write := func(fn string, encode func(w io.Writer, m image.Image) error) string {
+		fp := path.Join(dir, fn)
+		f, err := os.Create(fp)
+		assert.NoError(t, err)
+		assert.NoError(t, encode(f, img))
+		assert.NoError(t, f.Close())
+
+		return fp
+	}
+
+	encodeGIF := func(w io.Writer, m image.Image) error { return gif.Encode(w, m, nil) }
+
+	tests := []struct {
+		fp         string
+		exFormat   string
+		exErr      error
+		exMismatch bool
+	}{
+		{fp: write("upper.PNG", png.Encode), exFormat: "png"},
+		{fp: write("alias.tif", png.Encode), exFormat: "png", exMismatch: true},
+		{fp: write("noext", png.Encode), exFormat: "png"},
+		{fp: write("export.dat", encodeGIF), exFormat: "gif"},
+		{fp: write("wrong.gif", png.Encode), exFormat: "png", exMismatch: true},
+		{fp: write("text.txt", func(w io.Writer, m image.Image) error { _, err := w.Write([]byte("hello")); return err }), exErr: gocropper.ErrUnsupportedFormat},
+		{fp: path.Join(dir, "missing.jpg"), exFormat: "jpeg"},
+		{fp: path.Join(dir, "missing"), exErr: gocropper.ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(path.Base(tt.fp), func(t *testing.T) {
+			c, err := gocropper.NewCroppable(tt.fp)
+
+			if tt.exErr != nil {
+				assert.ErrorIs(t, err, tt.exErr)
+				assert.Nil(t, c)
+
+				return
+			}
+
+			assert.NoError(t, err)
+
+			if assert.NotNil(t, c) {
+				assert.Equal(t, tt.exFormat, c.Format)
+				assert.Equal(t, tt.exMismatch, c.Mismatch != nil)
+			}
+		})
+	}
+
+	finder, _ := gocropper.NewFinder()
+	croppables, err := finder.Find([]string{dir})
+	assert.NoError(t, err)
+	assert.Len(t, croppables, 5)
+
+	mismatches := 0
+	for _, c := range croppables {
+		if c.Mismatch != nil {
+			assert.ErrorIs(t, c.Mismatch, gocropper.ErrFormatMismatch)
+			mismatches++
+		}
+	}
+
+	assert.Equal(t, 2, mismatches)
+
+	// tiff files are detected by either byte order
+	for _, magic := range []string{"II*\x00", "MM\x00*"} {
+		fp := write("tiff", func(w io.Writer, m image.Image) error { _, err := w.Write([]byte(magic)); return err })
+
+		c, err := gocropper.NewCroppable(fp)
+		assert.NoError(t, err)
+		assert.Equal(t, "tiff", c.Format)
+	}
+}
+
+func TestCropper_SaveFallbackFormat(t *testing.T) {
+	dir := t.TempDir()
+
+	// bmp images are opaque
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	img.Set(4, 3, color.Black)
+	img.Set(12, 6, color.Black)
+
+	f, err := os.Create(path.Join(dir, "image.bmp"))
+	assert.NoError(t, err)
+	assert.NoError(t, bmp.Encode(f, img))
+	assert.NoError(t, f.Close())
+
+	tests := []struct {
+		fn       string
+		fallback string
+		exOut    string
+		exFormat string
+		exSize   image.Point
+		exErr    error
+	}{
+		{fn: "image.bmp", exOut: "image.bmp", exFormat: "bmp", exSize: image.Pt(9, 4)},
+		{fn: "image.bmp", fallback: "png", exOut: "image.bmp", exFormat: "bmp", exSize: image.Pt(9, 4)},
+		{fn: "gopher.webp", exErr: gocropper.ErrUnsupportedFormat},
+		{fn: "gopher.webp", fallback: "png", exOut: "gopher.webp.png", exFormat: "png", exSize: image.Pt(68, 96)},
+		{fn: "gopher.webp", fallback: "jpeg", exOut: "gopher.webp.jpg", exFormat: "jpeg", exSize: image.Pt(68, 96)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fn+"/"+tt.fallback, func(t *testing.T) {
+			outDir := t.TempDir()
+			opts := []gocropper.CropperOption{gocropper.WithOutDir(outDir), gocropper.WithBackgroundColor(color.White, 0)}
+
+			if tt.fallback != "" {
+				opts = append(opts, gocropper.WithFallbackFormat(tt.fallback))
+			}
+
+			cropper, err := gocropper.NewCropper(opts...)
+			assert.NoError(t, err)
+
+			fp := path.Join(dir, tt.fn)
+			if tt.fn == "gopher.webp" {
+				fp = "testdata/gopher.webp"
+			}
+
+			croppable, err := gocropper.Load(fp)
+			assert.NoError(t, err)
+
+			err = cropper.CropAndSave(croppable)
+			assert.ErrorIs(t, err, tt.exErr)
+
+			if tt.exErr != nil {
+				return
+			}
+
+			saved, err := gocropper.Load(path.Join(outDir, tt.exOut))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.exFormat, saved.Format)
+			assert.Equal(t, tt.exSize, saved.Image.Bounds().Size())
+		})
+	}
+
+	_, err = gocropper.NewCropper(gocropper.WithFallbackFormat("webp"))
+	assert.ErrorIs(t, err, gocropper.ErrUnsupportedFormat)
+
+	_, err = gocropper.NewCropper(gocropper.WithFallbackFormat("unknown"))
+	assert.ErrorIs(t, err, gocropper.ErrUnsupportedFormat)
+}
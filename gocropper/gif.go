@@ -0,0 +1,246 @@
+package gocropper
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+)
+
+var ErrInvalidGIF = errors.New("invalid gif")
+
+// GIF block introducers and flags
+const (
+	gifExtension       = 0x21
+	gifImageDescriptor = 0x2c
+	gifTrailer         = 0x3b
+	gifColorTable      = 0x80
+)
+
+// loadGIF decodes all frames of a GIF image. The first frame becomes the image of the croppable,
+// animated images also keep all their frames in c.GIF.
+func (c *Croppable) loadGIF(r io.Reader) error {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return err
+	}
+
+	c.Image = g.Image[0]
+
+	if len(g.Image) > 1 {
+		c.GIF = g
+	}
+
+	return nil
+}
+
+// gifFramePixels reads the blocks of a GIF image and returns the total number of pixels of its frames, without decoding them.
+func gifFramePixels(r io.Reader) (int64, error) {
+	br := bufio.NewReader(r)
+
+	// header and logical screen descriptor
+	screen := make([]byte, 13)
+	if _, err := io.ReadFull(br, screen); err != nil {
+		return 0, fmt.Errorf("%s: %w", err.Error(), ErrInvalidGIF)
+	}
+
+	if err := skipGIFColorTable(br, screen[10]); err != nil {
+		return 0, err
+	}
+
+	var pixels int64
+
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", err.Error(), ErrInvalidGIF)
+		}
+
+		switch b {
+		case gifExtension:
+			// the label precedes the data sub-blocks
+			if _, err := br.ReadByte(); err != nil {
+				return 0, fmt.Errorf("%s: %w", err.Error(), ErrInvalidGIF)
+			}
+		case gifImageDescriptor:
+			desc := make([]byte, 9)
+			if _, err := io.ReadFull(br, desc); err != nil {
+				return 0, fmt.Errorf("%s: %w", err.Error(), ErrInvalidGIF)
+			}
+
+			pixels += int64(binary.LittleEndian.Uint16(desc[4:])) * int64(binary.LittleEndian.Uint16(desc[6:]))
+
+			if err := skipGIFColorTable(br, desc[8]); err != nil {
+				return 0, err
+			}
+
+			// the LZW minimum code size precedes the data sub-blocks
+			if _, err := br.ReadByte(); err != nil {
+				return 0, fmt.Errorf("%s: %w", err.Error(), ErrInvalidGIF)
+			}
+		case gifTrailer:
+			return pixels, nil
+		default:
+			return 0, fmt.Errorf("block 0x%02x: %w", b, ErrInvalidGIF)
+		}
+
+		if err := skipGIFSubBlocks(br); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// skipGIFColorTable skips the color table following a descriptor with the flags.
+func skipGIFColorTable(br *bufio.Reader, flags byte) error {
+	if flags&gifColorTable == 0 {
+		return nil
+	}
+
+	if _, err := br.Discard(3 << (flags&0x07 + 1)); err != nil {
+		return fmt.Errorf("color table: %s: %w", err.Error(), ErrInvalidGIF)
+	}
+
+	return nil
+}
+
+// skipGIFSubBlocks skips data sub-blocks up to the block terminator.
+func skipGIFSubBlocks(br *bufio.Reader) error {
+	for {
+		size, err := br.ReadByte()
+		if err != nil {
+			return fmt.Errorf("%s: %w", err.Error(), ErrInvalidGIF)
+		}
+
+		if size == 0 {
+			return nil
+		}
+
+		if _, err := br.Discard(int(size)); err != nil {
+			return fmt.Errorf("%s: %w", err.Error(), ErrInvalidGIF)
+		}
+	}
+}
+
+// cropGIFRect crops all frames of an animated GIF to the rectangle extended by padding, usually the union of the content rectangles
+// of its composited frames, see GIFRect. Frames are translated so the cropped animation starts at the origin, as required by the format.
+func (i *Cropper) cropGIFRect(c *Croppable, rect image.Rectangle) (*Croppable, bool) {
+	padded := rect.Inset(-i.padding)
+
+	if padded.Eq(gifScreen(c.GIF)) {
+		return c, false
+	}
+
+	cropped := &gif.GIF{
+		Image:           make([]*image.Paletted, len(c.GIF.Image)),
+		Delay:           append([]int{}, c.GIF.Delay...),
+		LoopCount:       c.GIF.LoopCount,
+		Disposal:        make([]byte, len(c.GIF.Image)),
+		Config:          c.GIF.Config,
+		BackgroundIndex: c.GIF.BackgroundIndex,
+	}
+
+	cropped.Config.Width, cropped.Config.Height = padded.Dx(), padded.Dy()
+
+	for f, frame := range c.GIF.Image {
+		cropped.Image[f], cropped.Disposal[f] = cropFrame(frame, gifDisposal(c.GIF, f), padded)
+	}
+
+	return c.withGIF(cropped), true
+}
+
+// GIFRect returns the cropping rectangle of an animated GIF, does not include padding.
+// It is the union of the rectangles of all frames composited according to their disposal methods,
+// the bounds of the logical screen are returned if no frame has content.
+func (i *Cropper) GIFRect(g *gif.GIF) image.Rectangle {
+	return i.gifRect(g)
+}
+
+func (i *Cropper) gifRect(g *gif.GIF) image.Rectangle {
+	if rect, ok := i.gifContent(g); ok {
+		return rect
+	}
+
+	return gifScreen(g)
+}
+
+// gifContent returns the union of the content rectangles of all composited frames, false if no frame has content.
+func (i *Cropper) gifContent(g *gif.GIF) (image.Rectangle, bool) {
+	screen := gifScreen(g)
+	canvas := image.NewRGBA(screen)
+
+	var (
+		m        pixelMatcher
+		union    image.Rectangle
+		previous *image.RGBA
+	)
+
+	for f, frame := range g.Image {
+		disposal := gifDisposal(g, f)
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(screen)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		// the background is detected on the first composited frame only
+		if f == 0 {
+			m = i.matcher(canvas)
+		}
+
+		if rect, ok := i.content(canvas, m); ok {
+			union = union.Union(rect)
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return union, !union.Empty()
+}
+
+// cropFrame returns the part of the frame inside the rectangle, translated by its minimum point.
+// Frames entirely outside the rectangle are replaced with a single transparent pixel, GIF animations cannot skip frames.
+func cropFrame(frame *image.Paletted, disposal byte, rect image.Rectangle) (*image.Paletted, byte) {
+	visible := frame.Bounds().Intersect(rect)
+
+	if visible.Empty() {
+		return image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Transparent}), gif.DisposalNone
+	}
+
+	cropped := image.NewPaletted(visible.Sub(rect.Min), frame.Palette)
+
+	for y := visible.Min.Y; y < visible.Max.Y; y++ {
+		row := frame.Pix[frame.PixOffset(visible.Min.X, y):frame.PixOffset(visible.Max.X, y)]
+		copy(cropped.Pix[(y-visible.Min.Y)*cropped.Stride:], row)
+	}
+
+	return cropped, disposal
+}
+
+func gifScreen(g *gif.GIF) image.Rectangle {
+	return image.Rect(0, 0, g.Config.Width, g.Config.Height)
+}
+
+func gifDisposal(g *gif.GIF, frame int) byte {
+	if frame < len(g.Disposal) {
+		return g.Disposal[frame]
+	}
+
+	return gif.DisposalNone
+}
+
+func encodeGIF(g *gif.GIF) func(w io.Writer, m image.Image) error {
+	return func(w io.Writer, m image.Image) error {
+		return gif.EncodeAll(w, g)
+	}
+}
@@ -0,0 +1,100 @@
+package gocropper_test
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/H3Cki/gocrop/gocropper"
+	"github.com/stretchr/testify/assert"
+)
+
+// spinnerGIF returns a 60x40 animation of three frames, the last one is empty and lies outside the content.
+func spinnerGIF() *gif.GIF {
+	palette := color.Palette{color.Transparent, color.Black, color.RGBA{0xff, 0, 0, 0xff}}
+
+	f0 := image.NewPaletted(image.Rect(0, 0, 60, 40), palette)
+	f0.SetColorIndex(10, 5, 1)
+
+	f1 := image.NewPaletted(image.Rect(30, 20, 40, 30), palette)
+	f1.SetColorIndex(35, 28, 2)
+
+	f2 := image.NewPaletted(image.Rect(50, 0, 60, 10), palette)
+
+	return &gif.GIF{
+		Image:     []*image.Paletted{f0, f1, f2},
+		Delay:     []int{10, 20, 30},
+		Disposal:  []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalPrevious},
+		LoopCount: 3,
+		Config:    image.Config{ColorModel: palette, Width: 60, Height: 40},
+	}
+}
+
+func TestCropper_GIFRect(t *testing.T) {
+	cropper, _ := gocropper.NewCropper()
+
+	g := spinnerGIF()
+	assert.Equal(t, image.Rect(10, 5, 36, 29), cropper.GIFRect(g))
+
+	// content of frames disposed to background is still part of the animation
+	g.Disposal[0] = gif.DisposalBackground
+	assert.Equal(t, image.Rect(10, 5, 36, 29), cropper.GIFRect(g))
+
+	g.Image = g.Image[2:]
+	g.Delay, g.Disposal = g.Delay[2:], g.Disposal[2:]
+	assert.Equal(t, image.Rect(0, 0, 60, 40), cropper.GIFRect(g))
+}
+
+func TestCropper_CropAndSaveGIF(t *testing.T) {
+	dir := t.TempDir()
+	fp := path.Join(dir, "spinner.gif")
+
+	f, err := os.Create(fp)
+	assert.NoError(t, err)
+	assert.NoError(t, gif.EncodeAll(f, spinnerGIF()))
+	assert.NoError(t, f.Close())
+
+	outDir := t.TempDir()
+	cropper, _ := gocropper.NewCropper(gocropper.WithOutDir(outDir), gocropper.WithPadding(2))
+
+	croppable, err := gocropper.Load(fp)
+	assert.NoError(t, err)
+	assert.NotNil(t, croppable.GIF)
+	assert.NoError(t, cropper.CropAndSave(croppable))
+
+	f, err = os.Open(path.Join(outDir, "spinner.gif"))
+	assert.NoError(t, err)
+
+	defer f.Close()
+
+	g, err := gif.DecodeAll(f)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 30, g.Config.Width)
+	assert.Equal(t, 28, g.Config.Height)
+	assert.Equal(t, []int{10, 20, 30}, g.Delay)
+	assert.Equal(t, 3, g.LoopCount)
+	assert.Equal(t, []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone}, g.Disposal)
+
+	if assert.Len(t, g.Image, 3) {
+		assert.Equal(t, image.Rect(0, 0, 30, 28), g.Image[0].Bounds())
+		assert.Equal(t, uint8(1), g.Image[0].ColorIndexAt(2, 2))
+		assert.Equal(t, image.Rect(22, 17, 30, 27), g.Image[1].Bounds())
+		assert.Equal(t, uint8(2), g.Image[1].ColorIndexAt(27, 25))
+		assert.Equal(t, image.Rect(0, 0, 1, 1), g.Image[2].Bounds())
+		assert.Equal(t, uint32(0), alphaAt(g.Image[2], 0, 0))
+	}
+
+	// still GIFs are loaded without frames
+	still, err := gocropper.Load("testdata/line.gif")
+	assert.NoError(t, err)
+	assert.Nil(t, still.GIF)
+}
+
+func alphaAt(img image.Image, x, y int) uint32 {
+	_, _, _, a := img.At(x, y).RGBA()
+	return a
+}
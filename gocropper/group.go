@@ -0,0 +1,156 @@
+package gocropper
+
+import (
+	"image"
+	"path/filepath"
+	"regexp"
+)
+
+// GroupByRegex groups croppables in the same directory whose file names match the regex with the same key,
+// the first capture group of the match or the whole match if the regex has no groups, e.g. `^(.*)_\d+\.png$` groups walk_000.png and walk_001.png.
+// Croppables whose names don't match form groups of their own. Groups are in the order of their first croppable.
+func GroupByRegex(croppables []*Croppable, re *regexp.Regexp) [][]*Croppable {
+	return groupBy(croppables, func(c *Croppable) (string, bool) {
+		match := re.FindStringSubmatch(filepath.Base(c.Path))
+		if match == nil {
+			return "", false
+		}
+
+		key := match[0]
+		if len(match) > 1 {
+			key = match[1]
+		}
+
+		return filepath.Join(filepath.Dir(c.Path), key), true
+	})
+}
+
+// GroupByDirectory groups croppables in the same directory. Groups are in the order of their first croppable.
+func GroupByDirectory(croppables []*Croppable) [][]*Croppable {
+	return groupBy(croppables, func(c *Croppable) (string, bool) {
+		return filepath.Dir(c.Path), true
+	})
+}
+
+// groupBy groups croppables with the same key, croppables without a key form groups of their own.
+func groupBy(croppables []*Croppable, key func(*Croppable) (string, bool)) [][]*Croppable {
+	var groups [][]*Croppable
+
+	index := map[string]int{}
+
+	for _, c := range croppables {
+		k, ok := key(c)
+		if !ok {
+			groups = append(groups, []*Croppable{c})
+			continue
+		}
+
+		n, ok := index[k]
+		if !ok {
+			n = len(groups)
+			index[k] = n
+			groups = append(groups, nil)
+		}
+
+		groups[n] = append(groups[n], c)
+	}
+
+	return groups
+}
+
+// GroupRect returns the cropping rectangle shared by a group of loaded croppables, does not include padding,
+// e.g. frames of an animation exported as separate images which have to be cropped the same way.
+// It is the union of the rectangles of all croppables with content, see Rect, GIFRect and APNGRect,
+// the union of their bounds is returned if none has content. Icons and cursors are not included, see CropGroup.
+func (i *Cropper) GroupRect(croppables []*Croppable) image.Rectangle {
+	var u groupUnion
+
+	for _, c := range croppables {
+		u.add(i.contentOf(c))
+	}
+
+	return u.rect()
+}
+
+// groupUnion accumulates the content rectangles and bounds of a group of croppables.
+type groupUnion struct {
+	content image.Rectangle
+	bounds  image.Rectangle
+}
+
+func (u *groupUnion) add(rect, bounds image.Rectangle, ok bool) {
+	u.bounds = u.bounds.Union(bounds)
+
+	if ok {
+		u.content = u.content.Union(rect)
+	}
+}
+
+// rect returns the union of the content, or of the bounds if no croppable has content.
+func (u *groupUnion) rect() image.Rectangle {
+	if u.content.Empty() {
+		return u.bounds
+	}
+
+	return u.content
+}
+
+// contentOf returns the content rectangle of a loaded croppable along with its bounds, false if it has no content.
+// Icons never have content, their entries are cropped separately.
+func (i *Cropper) contentOf(c *Croppable) (rect, bounds image.Rectangle, ok bool) {
+	switch {
+	case c.GIF != nil:
+		rect, ok = i.gifContent(c.GIF)
+		return rect, gifScreen(c.GIF), ok
+	case c.APNG != nil:
+		rect, ok = i.apngContent(c.APNG)
+		return rect, c.APNG.canvas(), ok
+	case c.Icon != nil:
+		return image.Rectangle{}, image.Rectangle{}, false
+	}
+
+	rect, ok = i.content(c.Image, i.matcher(c.Image))
+
+	return rect, c.Image.Bounds(), ok
+}
+
+// CropGroup crops a group of loaded croppables to the rectangle returned by GroupRect extended by padding and returns them
+// along with flags indicating if cropping was done, see Crop. Images smaller than the rectangle are padded like the cropper pads images.
+// Icons and cursors are cropped with Crop, their entries differ in size.
+func (i *Cropper) CropGroup(croppables []*Croppable) ([]*Croppable, []bool) {
+	rect := i.GroupRect(croppables)
+	cropped := make([]*Croppable, len(croppables))
+	ok := make([]bool, len(croppables))
+
+	for n, c := range croppables {
+		cropped[n], _, ok[n] = i.cropTo(c, rect)
+	}
+
+	return cropped, ok
+}
+
+// cropTo crops the croppable to the rectangle extended by padding and returns the rectangle, see crop for icons.
+func (i *Cropper) cropTo(c *Croppable, rect image.Rectangle) (*Croppable, image.Rectangle, bool) {
+	var (
+		cropped *Croppable
+		ok      bool
+	)
+
+	switch {
+	case c.GIF != nil:
+		cropped, ok = i.cropGIFRect(c, rect)
+	case c.APNG != nil:
+		cropped, ok = i.cropAPNGRect(c, rect)
+	case c.Icon != nil:
+		return i.cropIcon(c)
+	default:
+		var img CroppableImage
+
+		cropped = c
+		if img, ok = i.cropRect(c.Image, rect, i.matcher(c.Image)); ok {
+			cropped = c.With(img)
+		}
+	}
+
+	return cropped, rect, ok
+}
@@ -0,0 +1,187 @@
+package gocropper_test
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/H3Cki/gocrop/gocropper"
+	"github.com/stretchr/testify/assert"
+)
+
+func paths(groups [][]*gocropper.Croppable) [][]string {
+	out := [][]string{}
+
+	for _, group := range groups {
+		names := []string{}
+		for _, c := range group {
+			names = append(names, filepath.ToSlash(c.Path))
+		}
+
+		out = append(out, names)
+	}
+
+	return out
+}
+
+func TestGroupByRegex(t *testing.T) {
+	croppables := []*gocropper.Croppable{}
+	for _, p := range []string{"a/walk_000.png", "a/run_000.png", "a/walk_001.png", "b/walk_000.png", "a/logo.png", "a/run_001.png"} {
+		croppables = append(croppables, &gocropper.Croppable{Path: p})
+	}
+
+	tests := []struct {
+		name  string
+		regex string
+		want  [][]string
+	}{
+		{
+			name:  "capture group",
+			regex: `^(.*)_\d+\.png$`,
+			want: [][]string{
+				{"a/walk_000.png", "a/walk_001.png"},
+				{"a/run_000.png", "a/run_001.png"},
+				{"b/walk_000.png"},
+				{"a/logo.png"},
+			},
+		},
+		{
+			name:  "whole match",
+			regex: `^[a-z]+`,
+			want: [][]string{
+				{"a/walk_000.png", "a/walk_001.png"},
+				{"a/run_000.png", "a/run_001.png"},
+				{"b/walk_000.png"},
+				{"a/logo.png"},
+			},
+		},
+		{
+			name:  "no match",
+			regex: `^x`,
+			want: [][]string{
+				{"a/walk_000.png"}, {"a/run_000.png"}, {"a/walk_001.png"}, {"b/walk_000.png"}, {"a/logo.png"}, {"a/run_001.png"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, paths(gocropper.GroupByRegex(croppables, regexp.MustCompile(tt.regex))))
+		})
+	}
+
+	assert.Equal(t, [][]string{
+		{"a/walk_000.png", "a/run_000.png", "a/walk_001.png", "a/logo.png", "a/run_001.png"},
+		{"b/walk_000.png"},
+	}, paths(gocropper.GroupByDirectory(croppables)))
+}
+
+// frame returns a transparent 20x20 image with the rectangle filled.
+func frame(r image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{0, 0, 255, 255})
+		}
+	}
+
+	return img
+}
+
+func TestCropper_CropGroup(t *testing.T) {
+	cropper, err := gocropper.NewCropper(gocropper.WithPadding(1))
+	assert.NoError(t, err)
+
+	croppables := []*gocropper.Croppable{
+		{Path: "walk_0.png", Image: frame(image.Rect(5, 5, 8, 10))},
+		{Path: "walk_1.png", Image: frame(image.Rect(9, 6, 12, 11))},
+		{Path: "walk_2.png", Image: frame(image.Rectangle{})},
+	}
+
+	assert.Equal(t, image.Rect(5, 5, 12, 11), cropper.GroupRect(croppables))
+
+	cropped, ok := cropper.CropGroup(croppables)
+	assert.Equal(t, []bool{true, true, true}, ok)
+
+	for _, c := range cropped {
+		assert.Equal(t, image.Rect(4, 4, 13, 12), c.Image.Bounds())
+	}
+
+	// content keeps its position in the shared rectangle
+	_, _, _, a := cropped[1].Image.At(11, 10).RGBA()
+	assert.Equal(t, uint32(0xffff), a)
+
+	_, _, _, a = cropped[0].Image.At(11, 10).RGBA()
+	assert.Zero(t, a)
+
+	// images smaller than the shared rectangle are padded
+	small := &gocropper.Croppable{Path: "small.png", Image: image.NewNRGBA(image.Rect(0, 0, 8, 8))}
+	cropped, _ = cropper.CropGroup([]*gocropper.Croppable{croppables[0], small})
+	assert.Equal(t, image.Rect(4, 4, 9, 11), cropped[1].Image.Bounds())
+
+	// the union of bounds is returned if no image has content
+	assert.Equal(t, image.Rect(0, 0, 20, 20), cropper.GroupRect([]*gocropper.Croppable{croppables[2], small}))
+}
+
+func TestProcessor_ProcessGroups(t *testing.T) {
+	srcDir, outDir := t.TempDir(), t.TempDir()
+
+	rects := map[string]image.Rectangle{
+		"walk_0.png": image.Rect(5, 5, 8, 10),
+		"walk_1.png": image.Rect(9, 6, 12, 11),
+		"logo.png":   image.Rect(1, 2, 3, 4),
+	}
+
+	croppables := []*gocropper.Croppable{}
+
+	for _, fn := range []string{"walk_0.png", "logo.png", "walk_1.png"} {
+		f, err := os.Create(path.Join(srcDir, fn))
+		assert.NoError(t, err)
+		assert.NoError(t, png.Encode(f, frame(rects[fn])))
+		assert.NoError(t, f.Close())
+
+		c, err := gocropper.NewCroppable(path.Join(srcDir, fn))
+		assert.NoError(t, err)
+
+		croppables = append(croppables, c)
+	}
+
+	cropper, err := gocropper.NewCropper(gocropper.WithOutDir(outDir))
+	assert.NoError(t, err)
+
+	processor, err := gocropper.NewProcessor(cropper, gocropper.WithJobs(2))
+	assert.NoError(t, err)
+
+	groups := gocropper.GroupByRegex(croppables, regexp.MustCompile(`^(.*)_\d+\.png$`))
+	results := []gocropper.Result{}
+
+	processor.ProcessGroups(groups, func(r gocropper.Result) {
+		results = append(results, r)
+	})
+
+	assert.Len(t, results, 3)
+
+	want := map[string]image.Rectangle{
+		"walk_0.png": image.Rect(0, 0, 7, 6),
+		"walk_1.png": image.Rect(0, 0, 7, 6),
+		"logo.png":   image.Rect(0, 0, 2, 2),
+	}
+
+	for idx, res := range results {
+		assert.Equal(t, idx, res.Index)
+		assert.NoError(t, res.Err)
+		assert.True(t, res.Cropped)
+
+		c, err := gocropper.Load(path.Join(outDir, filepath.Base(res.Path)))
+		assert.NoError(t, err)
+		assert.Equal(t, want[filepath.Base(res.Path)], c.Image.Bounds())
+	}
+
+	assert.Equal(t, "walk_1.png", filepath.Base(results[1].Path))
+}
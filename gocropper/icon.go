@@ -0,0 +1,573 @@
+package gocropper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+)
+
+var ErrInvalidIcon = errors.New("invalid icon")
+
+// icon resource types
+const (
+	iconTypeIcon   = 1
+	iconTypeCursor = 2
+)
+
+const (
+	iconDirLen      = 6
+	iconDirEntryLen = 16
+	bmpInfoLen      = 40
+	// iconMaxEntries limits the number of entries read from a directory.
+	iconMaxEntries = 256
+)
+
+// Icon is a Windows icon (.ico) or cursor (.cur), a directory of images of different sizes and bit depths.
+type Icon struct {
+	// Cursor is true for cursors.
+	Cursor  bool
+	Entries []*IconEntry
+}
+
+// IconEntry is a single image of an icon or a cursor.
+type IconEntry struct {
+	Image CroppableImage
+	// PNG is true for entries stored as PNG images, other entries are stored as bitmaps.
+	PNG bool
+	// Hotspot is the point of a cursor entry which points at the cursor position, in the coordinate space of the image.
+	Hotspot image.Point
+}
+
+// largest returns the image of the entry with the most pixels.
+func (ic *Icon) largest() CroppableImage {
+	var img CroppableImage
+
+	for _, e := range ic.Entries {
+		if img == nil || area(e.Image.Bounds()) > area(img.Bounds()) {
+			img = e.Image
+		}
+	}
+
+	return img
+}
+
+func area(r image.Rectangle) int {
+	return r.Dx() * r.Dy()
+}
+
+// loadIcon decodes all entries of an icon or a cursor. The largest entry becomes the image of the croppable.
+func (c *Croppable) loadIcon(r io.Reader) error {
+	ic, err := decodeIcon(r)
+	if err != nil {
+		return err
+	}
+
+	c.Image = ic.largest()
+	c.Icon = ic
+
+	return nil
+}
+
+// cropIcon crops all entries of an icon, each to its own content rectangle or to the shared rectangle, see IconRects.
+// Cursor entries are never cropped past their hotspot, see IconRects. Returns the rectangle of the largest entry.
+func (i *Cropper) cropIcon(c *Croppable) (*Croppable, image.Rectangle, bool) {
+	cropped := &Icon{Cursor: c.Icon.Cursor, Entries: make([]*IconEntry, len(c.Icon.Entries))}
+	largest := c.Icon.largest()
+	changed := false
+
+	var largestRect image.Rectangle
+
+	for n, rect := range i.iconRects(c.Icon) {
+		e := c.Icon.Entries[n]
+		if e.Image == largest {
+			largestRect = rect
+		}
+
+		img, ok := i.cropRect(e.Image, rect, i.matcher(e.Image))
+		changed = changed || ok
+
+		cropped.Entries[n] = &IconEntry{Image: img, PNG: e.PNG, Hotspot: e.Hotspot}
+	}
+
+	if !changed {
+		return c, largestRect, false
+	}
+
+	return c.withIcon(cropped), largestRect, true
+}
+
+// IconRects returns the cropping rectangles of all entries of an icon, does not include padding.
+// By default every entry is cropped to its own content. With WithSharedIconRect the rectangles are the union of
+// the content of all entries scaled to the size of each entry, so all entries are cropped to the same proportions.
+// The rectangles of cursor entries include the pixel of their hotspot, so the cursor keeps pointing at the same pixel.
+func (i *Cropper) IconRects(ic *Icon) []image.Rectangle {
+	return i.iconRects(ic)
+}
+
+func (i *Cropper) iconRects(ic *Icon) []image.Rectangle {
+	rects := make([]image.Rectangle, len(ic.Entries))
+
+	for n, e := range ic.Entries {
+		rects[n] = i.Rect(e.Image)
+
+		if ic.Cursor {
+			rects[n] = rects[n].Union(image.Rectangle{e.Hotspot, e.Hotspot.Add(image.Pt(1, 1))})
+		}
+	}
+
+	if !i.sharedIconRect || len(ic.Entries) < 2 {
+		return rects
+	}
+
+	// the union is computed in the coordinate space of the largest entry
+	ref := ic.largest().Bounds()
+
+	var union image.Rectangle
+	for n, e := range ic.Entries {
+		union = union.Union(scaleRect(rects[n], e.Image.Bounds(), ref))
+	}
+
+	for n, e := range ic.Entries {
+		rects[n] = scaleRect(union, ref, e.Image.Bounds())
+	}
+
+	return rects
+}
+
+// scaleRect maps r from the from rectangle to the to rectangle, the result covers every pixel r partially covers.
+func scaleRect(r, from, to image.Rectangle) image.Rectangle {
+	scale := func(v, fromMin, fromLen, toMin, toLen int, ceil bool) int {
+		n := (v - fromMin) * toLen
+		if ceil {
+			n += fromLen - 1
+		}
+
+		return toMin + n/fromLen
+	}
+
+	return image.Rect(
+		scale(r.Min.X, from.Min.X, from.Dx(), to.Min.X, to.Dx(), false),
+		scale(r.Min.Y, from.Min.Y, from.Dy(), to.Min.Y, to.Dy(), false),
+		scale(r.Max.X, from.Min.X, from.Dx(), to.Min.X, to.Dx(), true),
+		scale(r.Max.Y, from.Min.Y, from.Dy(), to.Min.Y, to.Dy(), true),
+	).Intersect(to)
+}
+
+// decodeIcon decodes the directory and all entries of an icon or a cursor.
+func decodeIcon(r io.Reader) (*Icon, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	typ, entries, err := readIconDir(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ic := &Icon{Cursor: typ == iconTypeCursor, Entries: make([]*IconEntry, len(entries))}
+
+	for n, d := range entries {
+		if d.offset > len(data) || d.size > len(data)-d.offset {
+			return nil, fmt.Errorf("entry %d exceeds the file: %w", n, ErrInvalidIcon)
+		}
+
+		e, err := decodeIconEntry(data[d.offset : d.offset+d.size])
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", n, err)
+		}
+
+		if ic.Cursor {
+			if !d.hotspot.In(e.Image.Bounds()) {
+				return nil, fmt.Errorf("entry %d hotspot %v outside of the image: %w", n, d.hotspot, ErrInvalidIcon)
+			}
+
+			e.Hotspot = d.hotspot
+		}
+
+		ic.Entries[n] = e
+	}
+
+	return ic, nil
+}
+
+type iconDirEntry struct {
+	width, height int
+	hotspot       image.Point
+	size, offset  int
+}
+
+func readIconDir(data []byte) (int, []iconDirEntry, error) {
+	if len(data) < iconDirLen {
+		return 0, nil, fmt.Errorf("missing directory: %w", ErrInvalidIcon)
+	}
+
+	typ := int(binary.LittleEndian.Uint16(data[2:]))
+	count := int(binary.LittleEndian.Uint16(data[4:]))
+
+	if binary.LittleEndian.Uint16(data) != 0 || (typ != iconTypeIcon && typ != iconTypeCursor) {
+		return 0, nil, fmt.Errorf("missing magic: %w", ErrInvalidIcon)
+	}
+
+	if count == 0 || count > iconMaxEntries || len(data) < iconDirLen+count*iconDirEntryLen {
+		return 0, nil, fmt.Errorf("%d entries: %w", count, ErrInvalidIcon)
+	}
+
+	entries := make([]iconDirEntry, count)
+
+	for n := range entries {
+		b := data[iconDirLen+n*iconDirEntryLen:]
+		entries[n] = iconDirEntry{
+			width:   iconDim(b[0]),
+			height:  iconDim(b[1]),
+			hotspot: image.Pt(int(binary.LittleEndian.Uint16(b[4:])), int(binary.LittleEndian.Uint16(b[6:]))),
+			size:    int(binary.LittleEndian.Uint32(b[8:])),
+			offset:  int(binary.LittleEndian.Uint32(b[12:])),
+		}
+	}
+
+	return typ, entries, nil
+}
+
+// iconDim returns the dimension stored in a directory entry, 0 means 256 pixels or more.
+func iconDim(b byte) int {
+	if b == 0 {
+		return 256
+	}
+
+	return int(b)
+}
+
+func decodeIconEntry(data []byte) (*IconEntry, error) {
+	if bytes.HasPrefix(data, pngSignature) {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		ci, ok := img.(CroppableImage)
+		if !ok {
+			return nil, ErrImageUncroppable
+		}
+
+		return &IconEntry{Image: ci, PNG: true}, nil
+	}
+
+	img, err := decodeIconBitmap(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IconEntry{Image: img}, nil
+}
+
+// decodeIconBitmap decodes an uncompressed 1, 4, 8, 24 or 32-bit bitmap entry into *image.NRGBA.
+// Bitmaps are stored with double height, the color rows are followed by the rows of the 1-bit transparency mask.
+// 32-bit bitmaps use their alpha channel unless it is empty.
+func decodeIconBitmap(data []byte) (*image.NRGBA, error) {
+	if len(data) < bmpInfoLen {
+		return nil, fmt.Errorf("missing bitmap header: %w", ErrInvalidIcon)
+	}
+
+	headerLen := int(binary.LittleEndian.Uint32(data))
+	width := int(int32(binary.LittleEndian.Uint32(data[4:])))
+	height := int(int32(binary.LittleEndian.Uint32(data[8:]))) / 2
+	bpp := int(binary.LittleEndian.Uint16(data[14:]))
+	compression := binary.LittleEndian.Uint32(data[16:])
+	colorsUsed := int(binary.LittleEndian.Uint32(data[32:]))
+
+	if headerLen < bmpInfoLen || headerLen > len(data) || width <= 0 || height <= 0 || width > 1<<12 || height > 1<<12 {
+		return nil, fmt.Errorf("bitmap header: %w", ErrInvalidIcon)
+	}
+
+	if compression != 0 {
+		return nil, fmt.Errorf("compressed bitmap: %w", ErrInvalidIcon)
+	}
+
+	var palette []color.NRGBA
+
+	switch bpp {
+	case 1, 4, 8:
+		if colorsUsed == 0 || colorsUsed > 1<<bpp {
+			colorsUsed = 1 << bpp
+		}
+
+		palette = make([]color.NRGBA, colorsUsed)
+	case 24, 32:
+	default:
+		return nil, fmt.Errorf("%d-bit bitmap: %w", bpp, ErrInvalidIcon)
+	}
+
+	stride := (width*bpp + 31) / 32 * 4
+	maskStride := (width + 31) / 32 * 4
+	pixOffset := headerLen + len(palette)*4
+	maskOffset := pixOffset + stride*height
+
+	if len(data) < maskOffset {
+		return nil, fmt.Errorf("truncated bitmap: %w", ErrInvalidIcon)
+	}
+
+	for n := range palette {
+		p := data[headerLen+n*4:]
+		palette[n] = color.NRGBA{p[2], p[1], p[0], 0xff}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hasAlpha := false
+
+	for y := 0; y < height; y++ {
+		// rows are stored bottom to top
+		row := data[pixOffset+(height-1-y)*stride:]
+
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+
+			switch bpp {
+			case 32:
+				p := row[x*4:]
+				c = color.NRGBA{p[2], p[1], p[0], p[3]}
+				hasAlpha = hasAlpha || c.A != 0
+			case 24:
+				p := row[x*3:]
+				c = color.NRGBA{p[2], p[1], p[0], 0xff}
+			default:
+				bit := x * bpp
+				idx := int(row[bit/8]>>(8-bpp-bit%8)) & (1<<bpp - 1)
+
+				if idx < len(palette) {
+					c = palette[idx]
+				}
+			}
+
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	if hasAlpha {
+		return img, nil
+	}
+
+	// the mask is optional for 32-bit bitmaps
+	if len(data) < maskOffset+maskStride*height {
+		if bpp == 32 {
+			return img, nil
+		}
+
+		return nil, fmt.Errorf("truncated mask: %w", ErrInvalidIcon)
+	}
+
+	for y := 0; y < height; y++ {
+		row := data[maskOffset+(height-1-y)*maskStride:]
+
+		for x := 0; x < width; x++ {
+			i := img.PixOffset(x, y) + 3
+			img.Pix[i] = 0xff
+
+			if row[x/8]&(0x80>>(x%8)) != 0 {
+				img.Pix[i] = 0
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// encodeIcon returns an encode function writing the icon, the image passed to the function is ignored.
+func encodeIcon(ic *Icon) func(w io.Writer, m image.Image) error {
+	return func(w io.Writer, m image.Image) error {
+		return writeIcon(w, ic)
+	}
+}
+
+// writeIcon writes the directory and entries of an icon. Entries keep their storage, bitmaps are written as 32-bit bitmaps
+// with a transparency mask, entries larger than 256 pixels are written as PNG. Images are translated to the origin,
+// cursor hotspots are written relative to the minimum point of their image.
+func writeIcon(w io.Writer, ic *Icon) error {
+	typ := iconTypeIcon
+	if ic.Cursor {
+		typ = iconTypeCursor
+	}
+
+	dir := make([]byte, iconDirLen+len(ic.Entries)*iconDirEntryLen)
+	binary.LittleEndian.PutUint16(dir[2:], uint16(typ))
+	binary.LittleEndian.PutUint16(dir[4:], uint16(len(ic.Entries)))
+
+	entries := make([][]byte, len(ic.Entries))
+	offset := len(dir)
+
+	for n, e := range ic.Entries {
+		b := e.Image.Bounds()
+
+		var (
+			data []byte
+			err  error
+		)
+
+		if e.PNG || b.Dx() > 256 || b.Dy() > 256 {
+			buf := &bytes.Buffer{}
+			err = png.Encode(buf, e.Image)
+			data = buf.Bytes()
+		} else {
+			data = iconBitmap(e.Image)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		d := dir[iconDirLen+n*iconDirEntryLen:]
+		d[0], d[1] = byte(b.Dx()), byte(b.Dy())
+
+		if b.Dx() >= 256 {
+			d[0] = 0
+		}
+
+		if b.Dy() >= 256 {
+			d[1] = 0
+		}
+
+		if ic.Cursor {
+			if !e.Hotspot.In(b) {
+				return fmt.Errorf("entry %d hotspot %v outside of the image: %w", n, e.Hotspot, ErrInvalidIcon)
+			}
+
+			hotspot := e.Hotspot.Sub(b.Min)
+			binary.LittleEndian.PutUint16(d[4:], uint16(hotspot.X))
+			binary.LittleEndian.PutUint16(d[6:], uint16(hotspot.Y))
+		} else {
+			binary.LittleEndian.PutUint16(d[4:], 1)
+			binary.LittleEndian.PutUint16(d[6:], 32)
+		}
+
+		binary.LittleEndian.PutUint32(d[8:], uint32(len(data)))
+		binary.LittleEndian.PutUint32(d[12:], uint32(offset))
+
+		entries[n] = data
+		offset += len(data)
+	}
+
+	if _, err := w.Write(dir); err != nil {
+		return err
+	}
+
+	for _, data := range entries {
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// iconBitmap returns the image as a 32-bit bitmap entry with a transparency mask.
+func iconBitmap(m image.Image) []byte {
+	b := m.Bounds()
+	width, height := b.Dx(), b.Dy()
+	stride := width * 4
+	maskStride := (width + 31) / 32 * 4
+
+	data := make([]byte, bmpInfoLen+(stride+maskStride)*height)
+	binary.LittleEndian.PutUint32(data, bmpInfoLen)
+	binary.LittleEndian.PutUint32(data[4:], uint32(width))
+	binary.LittleEndian.PutUint32(data[8:], uint32(height*2))
+	binary.LittleEndian.PutUint16(data[12:], 1)
+	binary.LittleEndian.PutUint16(data[14:], 32)
+	binary.LittleEndian.PutUint32(data[20:], uint32((stride+maskStride)*height))
+
+	pix := data[bmpInfoLen:]
+	mask := pix[stride*height:]
+
+	for y := 0; y < height; y++ {
+		row := pix[(height-1-y)*stride:]
+		maskRow := mask[(height-1-y)*maskStride:]
+
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(m.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			row[x*4], row[x*4+1], row[x*4+2], row[x*4+3] = c.B, c.G, c.R, c.A
+
+			if c.A == 0 {
+				maskRow[x/8] |= 0x80 >> (x % 8)
+			}
+		}
+	}
+
+	return data
+}
+
+// decodeIconImage decodes the largest entry of an icon or a cursor.
+func decodeIconImage(r io.Reader) (image.Image, error) {
+	ic, err := decodeIcon(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return ic.largest(), nil
+}
+
+// decodeIconConfig decodes the dimensions of the largest entry of an icon or a cursor from its directory.
+func decodeIconConfig(r io.Reader) (image.Config, error) {
+	entries, err := decodeIconDir(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	cfg := image.Config{ColorModel: color.NRGBAModel}
+
+	for _, e := range entries {
+		if e.width*e.height > cfg.Width*cfg.Height {
+			cfg.Width, cfg.Height = e.width, e.height
+		}
+	}
+
+	return cfg, nil
+}
+
+// decodeIconDir reads the directory of an icon or a cursor without reading its entries.
+func decodeIconDir(r io.Reader) ([]iconDirEntry, error) {
+	header := make([]byte, iconDirLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	count := int(binary.LittleEndian.Uint16(header[4:]))
+	if count > iconMaxEntries {
+		return nil, fmt.Errorf("%d entries: %w", count, ErrInvalidIcon)
+	}
+
+	dir := make([]byte, count*iconDirEntryLen)
+	if _, err := io.ReadFull(r, dir); err != nil {
+		return nil, err
+	}
+
+	_, entries, err := readIconDir(append(header, dir...))
+
+	return entries, err
+}
+
+// encodeIconImage encodes the image as an icon with a single entry.
+func encodeIconImage(w io.Writer, m image.Image) error {
+	return writeIcon(w, &Icon{Entries: []*IconEntry{{Image: croppableImage(m)}}})
+}
+
+// encodeCursorImage encodes the image as a cursor with a single entry and the hotspot at the top-left corner.
+func encodeCursorImage(w io.Writer, m image.Image) error {
+	img := croppableImage(m)
+	return writeIcon(w, &Icon{Cursor: true, Entries: []*IconEntry{{Image: img, Hotspot: img.Bounds().Min}}})
+}
+
+func croppableImage(m image.Image) CroppableImage {
+	if ci, ok := m.(CroppableImage); ok {
+		return ci
+	}
+
+	img := image.NewNRGBA(m.Bounds())
+	draw.Draw(img, img.Bounds(), m, img.Bounds().Min, draw.Src)
+
+	return img
+}
@@ -0,0 +1,174 @@
+package gocropper_test
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/H3Cki/gocrop/gocropper"
+	"github.com/stretchr/testify/assert"
+)
+
+// iconEntry returns an entry of the size with opaque content inside rect.
+func iconEntry(size int, rect image.Rectangle, png bool, hotspot image.Point) *gocropper.IconEntry {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, rect, image.NewUniform(color.NRGBA{0xff, 0, 0, 0xff}), image.Point{}, draw.Src)
+
+	return &gocropper.IconEntry{Image: img, PNG: png, Hotspot: hotspot}
+}
+
+// saveIcon saves the icon in dir under the name and loads it back.
+func saveIcon(t *testing.T, dir, fn string, ic *gocropper.Icon) *gocropper.Croppable {
+	cropper, err := gocropper.NewCropper(gocropper.WithOutDir(dir))
+	assert.NoError(t, err)
+
+	format := "ico"
+	if ic.Cursor {
+		format = "cur"
+	}
+
+	assert.NoError(t, cropper.Save(&gocropper.Croppable{Path: fn, Format: format, Image: ic.Entries[0].Image, Icon: ic}))
+
+	c, err := gocropper.Load(path.Join(dir, fn))
+	assert.NoError(t, err)
+
+	return c
+}
+
+func TestCropper_CropAndSaveIcon(t *testing.T) {
+	src := t.TempDir()
+
+	cursor := saveIcon(t, src, "pointer.cur", &gocropper.Icon{
+		Cursor: true,
+		Entries: []*gocropper.IconEntry{
+			iconEntry(32, image.Rect(8, 4, 20, 24), false, image.Pt(10, 6)),
+			iconEntry(16, image.Rect(2, 2, 10, 12), true, image.Pt(15, 15)),
+		},
+	})
+
+	if assert.NotNil(t, cursor.Icon) {
+		assert.Equal(t, "cur", cursor.Format)
+		assert.True(t, cursor.Icon.Cursor)
+		assert.Len(t, cursor.Icon.Entries, 2)
+		assert.Equal(t, image.Rect(0, 0, 32, 32), cursor.Image.Bounds())
+		assert.Equal(t, []bool{false, true}, []bool{cursor.Icon.Entries[0].PNG, cursor.Icon.Entries[1].PNG})
+		assert.Equal(t, image.Pt(15, 15), cursor.Icon.Entries[1].Hotspot)
+	}
+
+	tests := []struct {
+		name       string
+		shared     bool
+		exSizes    []image.Point
+		exHotspots []image.Point
+	}{
+		{
+			name:    "each entry",
+			exSizes: []image.Point{{12, 20}, {14, 14}},
+			// the second hotspot lies outside the content, the entry is cropped to include it
+			exHotspots: []image.Point{{2, 2}, {13, 13}},
+		},
+		{
+			name:       "shared rect",
+			shared:     true,
+			exSizes:    []image.Point{{28, 28}, {14, 14}},
+			exHotspots: []image.Point{{6, 2}, {13, 13}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outDir := t.TempDir()
+
+			cropper, err := gocropper.NewCropper(gocropper.WithOutDir(outDir), gocropper.WithSharedIconRect(tt.shared))
+			assert.NoError(t, err)
+			assert.NoError(t, cropper.CropAndSave(cursor))
+
+			saved, err := gocropper.Load(path.Join(outDir, "pointer.cur"))
+			assert.NoError(t, err)
+
+			for n, e := range saved.Icon.Entries {
+				assert.Equal(t, tt.exSizes[n], e.Image.Bounds().Size())
+				assert.Equal(t, tt.exHotspots[n], e.Hotspot)
+				assert.Equal(t, cursor.Icon.Entries[n].PNG, e.PNG)
+
+				// the hotspot points at the same pixel of the content
+				src := cursor.Icon.Entries[n]
+				assert.Equal(t, src.Image.At(src.Hotspot.X, src.Hotspot.Y), e.Image.At(e.Hotspot.X, e.Hotspot.Y))
+			}
+		})
+	}
+
+	// icons without content are not cropped
+	icon := saveIcon(t, src, "blank.ico", &gocropper.Icon{Entries: []*gocropper.IconEntry{iconEntry(16, image.Rect(0, 0, 16, 16), false, image.Point{})}})
+	cropper, _ := gocropper.NewCropper()
+	_, ok := cropper.Crop(icon)
+	assert.False(t, ok)
+	assert.False(t, icon.Icon.Cursor)
+}
+
+func TestLoad_IconBitmap(t *testing.T) {
+	// 1-bit 2x2 bitmap, black and white palette, the top-left pixel is masked
+	bitmap := make([]byte, 40)
+	binary.LittleEndian.PutUint32(bitmap, 40)
+	binary.LittleEndian.PutUint32(bitmap[4:], 2)
+	binary.LittleEndian.PutUint32(bitmap[8:], 4)
+	binary.LittleEndian.PutUint16(bitmap[12:], 1)
+	binary.LittleEndian.PutUint16(bitmap[14:], 1)
+
+	bitmap = append(bitmap,
+		0, 0, 0, 0, 0xff, 0xff, 0xff, 0, // palette
+		0x40, 0, 0, 0, // bottom row: black, white
+		0x80, 0, 0, 0, // top row: white, black
+		0, 0, 0, 0, // bottom mask row
+		0x80, 0, 0, 0, // top mask row
+	)
+
+	dir := []byte{0, 0, 1, 0, 1, 0, 2, 2, 2, 0, 1, 0, 1, 0}
+	dir = binary.LittleEndian.AppendUint32(dir, uint32(len(bitmap)))
+	dir = binary.LittleEndian.AppendUint32(dir, 22)
+
+	fp := path.Join(t.TempDir(), "bitmap.ico")
+	assert.NoError(t, os.WriteFile(fp, append(dir, bitmap...), 0o644))
+
+	c, err := gocropper.Load(fp)
+	assert.NoError(t, err)
+
+	black, white := color.NRGBA{0, 0, 0, 0xff}, color.NRGBA{0xff, 0xff, 0xff, 0xff}
+
+	assert.Equal(t, color.NRGBA{0xff, 0xff, 0xff, 0}, c.Image.At(0, 0))
+	assert.Equal(t, black, c.Image.At(1, 0))
+	assert.Equal(t, black, c.Image.At(0, 1))
+	assert.Equal(t, white, c.Image.At(1, 1))
+
+	cfg, err := c.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, image.Pt(2, 2), image.Pt(cfg.Width, cfg.Height))
+}
+
+func TestIcon_HotspotOutside(t *testing.T) {
+	dir := t.TempDir()
+
+	// cursors with a hotspot outside of their entry are not saved
+	cropper, _ := gocropper.NewCropper(gocropper.WithOutDir(dir))
+	entry := iconEntry(16, image.Rect(0, 0, 4, 4), false, image.Pt(16, 0))
+	err := cropper.Save(&gocropper.Croppable{Path: "outside.cur", Format: "cur", Image: entry.Image, Icon: &gocropper.Icon{Cursor: true, Entries: []*gocropper.IconEntry{entry}}})
+	assert.ErrorIs(t, err, gocropper.ErrInvalidIcon)
+
+	// nor loaded
+	saveIcon(t, dir, "inside.cur", &gocropper.Icon{Cursor: true, Entries: []*gocropper.IconEntry{iconEntry(16, image.Rect(0, 0, 4, 4), false, image.Pt(15, 0))}})
+
+	data, err := os.ReadFile(path.Join(dir, "inside.cur"))
+	assert.NoError(t, err)
+
+	// the hotspot x of the first directory entry
+	binary.LittleEndian.PutUint16(data[10:], 16)
+	assert.NoError(t, os.WriteFile(path.Join(dir, "outside.cur"), data, 0o644))
+
+	_, err = gocropper.Load(path.Join(dir, "outside.cur"))
+	assert.ErrorIs(t, err, gocropper.ErrImageLoadFailed)
+	assert.ErrorContains(t, err, "hotspot")
+}
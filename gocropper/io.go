@@ -4,13 +4,16 @@ import (
 	"errors"
 	"image"
 	"image/gif"
+	"image/jpeg"
 	"image/png"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"golang.org/x/image/bmp"
 	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
 )
 
 var ErrUnsupportedFormat = errors.New("unsupported format")
@@ -18,29 +21,174 @@ var ErrImageUncroppable = errors.New("image does not support cropping")
 var ErrImageLoadFailed = errors.New("unable to load image")
 
 type imageCoder struct {
-	decode func(r io.Reader) (image.Image, error)
-	encode func(w io.Writer, m image.Image) error
+	name         string
+	exts         []string
+	magic        [][]byte
+	decode       func(r io.Reader) (image.Image, error)
+	decodeConfig func(r io.Reader) (image.Config, error)
+	encode       func(w io.Writer, m image.Image) error
+	// registered is true for formats registered with RegisterFormat, their croppables are always decoded and encoded
+	// with their own functions, even if they replace a builtin format.
+	registered bool
 }
 
-var imageCoders = map[string]imageCoder{
-	".png": {
-		decode: png.Decode,
-		encode: png.Encode,
-	},
-	".gif": {
-		decode: gif.Decode,
-		encode: func(w io.Writer, m image.Image) error {
-			return gif.Encode(w, m, nil)
-		},
-	},
-	".tiff": {
-		decode: tiff.Decode,
-		encode: func(w io.Writer, m image.Image) error {
-			return tiff.Encode(w, m, nil)
-		},
+// croppable creates a *Croppable of the image at given path, using the coder for encoding and decoding.
+func (ic imageCoder) croppable(path string) *Croppable {
+	return &Croppable{
+		Path:         path,
+		Format:       ic.name,
+		Decode:       ic.decode,
+		DecodeConfig: ic.decodeConfig,
+		Encode:       ic.encode,
+		registered:   ic.registered,
+	}
+}
+
+func init() {
+	for _, ic := range []imageCoder{pngCoder, gifCoder, jpegCoder, tiffCoder, bmpCoder, webpCoder, tgaCoder, qoiCoder, pbmCoder, pgmCoder, ppmCoder, pnmCoder, pamCoder, icoCoder, curCoder} {
+		registerCoder(ic)
+	}
+}
+
+var pngCoder = imageCoder{
+	name:         "png",
+	exts:         []string{".png"},
+	magic:        [][]byte{pngSignature},
+	decode:       png.Decode,
+	decodeConfig: png.DecodeConfig,
+	encode:       png.Encode,
+}
+
+var gifCoder = imageCoder{
+	name:         "gif",
+	exts:         []string{".gif"},
+	magic:        [][]byte{[]byte("GIF8?a")},
+	decode:       gif.Decode,
+	decodeConfig: gif.DecodeConfig,
+	encode:       gifEncoder(256, DitherFloydSteinberg),
+}
+
+var jpegCoder = imageCoder{
+	name:         "jpeg",
+	exts:         []string{".jpg", ".jpeg"},
+	magic:        [][]byte{[]byte("\xff\xd8")},
+	decode:       decodeJPEG,
+	decodeConfig: decodeJPEGConfig,
+	encode:       jpegEncoder(jpeg.DefaultQuality),
+}
+
+var tiffCoder = imageCoder{
+	name:         "tiff",
+	exts:         []string{".tiff", ".tif"},
+	magic:        [][]byte{[]byte("II*\x00"), []byte("MM\x00*")},
+	decode:       tiff.Decode,
+	decodeConfig: tiff.DecodeConfig,
+	encode: func(w io.Writer, m image.Image) error {
+		return tiff.Encode(w, m, nil)
 	},
 }
 
+var bmpCoder = imageCoder{
+	name:         "bmp",
+	exts:         []string{".bmp"},
+	magic:        [][]byte{[]byte("BM")},
+	decode:       bmp.Decode,
+	decodeConfig: bmp.DecodeConfig,
+	encode:       bmp.Encode,
+}
+
+// webpCoder has no encoder, WebP images are saved in the fallback format of the cropper.
+var webpCoder = imageCoder{
+	name:         "webp",
+	exts:         []string{".webp"},
+	magic:        [][]byte{[]byte("RIFF????WEBPVP8")},
+	decode:       webp.Decode,
+	decodeConfig: webp.DecodeConfig,
+}
+
+// tgaCoder has no magic, TGA images are detected by their extension only.
+var tgaCoder = imageCoder{
+	name:         "tga",
+	exts:         []string{".tga"},
+	decode:       decodeTGA,
+	decodeConfig: decodeTGAConfig,
+	encode:       encodeTGA,
+}
+
+var qoiCoder = imageCoder{
+	name:         "qoi",
+	exts:         []string{".qoi"},
+	magic:        [][]byte{qoiMagic},
+	decode:       decodeQOI,
+	decodeConfig: decodeQOIConfig,
+	encode:       encodeQOI,
+}
+
+// Netpbm formats share the decoder, plain (ascii) images are saved in the raw variant of their format.
+var pbmCoder = imageCoder{
+	name:         "pbm",
+	exts:         []string{".pbm"},
+	magic:        [][]byte{[]byte("P1"), []byte("P4")},
+	decode:       decodeNetpbm,
+	decodeConfig: decodeNetpbmConfig,
+	encode:       encodePBM,
+}
+
+var pgmCoder = imageCoder{
+	name:         "pgm",
+	exts:         []string{".pgm"},
+	magic:        [][]byte{[]byte("P2"), []byte("P5")},
+	decode:       decodeNetpbm,
+	decodeConfig: decodeNetpbmConfig,
+	encode:       encodePGM,
+}
+
+var ppmCoder = imageCoder{
+	name:         "ppm",
+	exts:         []string{".ppm"},
+	magic:        [][]byte{[]byte("P3"), []byte("P6")},
+	decode:       decodeNetpbm,
+	decodeConfig: decodeNetpbmConfig,
+	encode:       encodePPM,
+}
+
+// pnmCoder has no magic, .pnm files can hold any of PBM, PGM and PPM images.
+var pnmCoder = imageCoder{
+	name:         "pnm",
+	exts:         []string{".pnm"},
+	decode:       decodeNetpbm,
+	decodeConfig: decodeNetpbmConfig,
+	encode:       encodePNM,
+}
+
+var pamCoder = imageCoder{
+	name:         "pam",
+	exts:         []string{".pam"},
+	magic:        [][]byte{[]byte("P7")},
+	decode:       decodeNetpbm,
+	decodeConfig: decodeNetpbmConfig,
+	encode:       encodePAM,
+}
+
+// icoCoder and curCoder decode and encode the largest entry of icons and cursors, croppables hold all their entries, see Croppable.Icon.
+var icoCoder = imageCoder{
+	name:         "ico",
+	exts:         []string{".ico"},
+	magic:        [][]byte{[]byte("\x00\x00\x01\x00")},
+	decode:       decodeIconImage,
+	decodeConfig: decodeIconConfig,
+	encode:       encodeIconImage,
+}
+
+var curCoder = imageCoder{
+	name:         "cur",
+	exts:         []string{".cur"},
+	magic:        [][]byte{[]byte("\x00\x00\x02\x00")},
+	decode:       decodeIconImage,
+	decodeConfig: decodeIconConfig,
+	encode:       encodeCursorImage,
+}
+
 func saveImage(fp string, img image.Image, encode func(w io.Writer, m image.Image) error) error {
 	fd, err := os.Create(fp)
 	if err != nil {
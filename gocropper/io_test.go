@@ -1,6 +1,12 @@
 package gocropper
 
 import (
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"os"
+	"path"
 	"runtime"
 	"testing"
 
@@ -66,3 +72,42 @@ func TestFileExt(t *testing.T) {
 		})
 	}
 }
+
+func TestImageCoder_Registered(t *testing.T) {
+	decoded, encoded := 0, 0
+
+	// a registered format named after a builtin format is decoded and encoded with its own functions, frames of animations are not loaded
+	ic := imageCoder{
+		name: gifCoder.name,
+		exts: []string{".gifo"},
+		decode: func(r io.Reader) (image.Image, error) {
+			decoded++
+			return gif.Decode(r)
+		},
+		decodeConfig: gif.DecodeConfig,
+		encode: func(w io.Writer, m image.Image) error {
+			encoded++
+			return gif.Encode(w, m, nil)
+		},
+		registered: true,
+	}
+
+	frame := image.NewPaletted(image.Rect(0, 0, 6, 6), color.Palette{color.Transparent, color.Black})
+	frame.SetColorIndex(2, 3, 1)
+
+	fp := path.Join(t.TempDir(), "anim.gifo")
+	f, err := os.Create(fp)
+	assert.NoError(t, err)
+	assert.NoError(t, gif.EncodeAll(f, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}}))
+	assert.NoError(t, f.Close())
+
+	croppable := ic.croppable(fp)
+	assert.NoError(t, croppable.Load())
+	assert.Equal(t, "gif", croppable.Format)
+	assert.Nil(t, croppable.GIF)
+	assert.Equal(t, 1, decoded)
+
+	cropper, _ := NewCropper(WithOutDir(t.TempDir()))
+	assert.NoError(t, cropper.CropAndSave(croppable))
+	assert.Equal(t, 1, encoded)
+}
@@ -0,0 +1,231 @@
+package gocropper
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+)
+
+// EXIF orientation values, see the Orientation tag of the EXIF specification.
+const (
+	orientationNormal     = 1
+	orientationFlipH      = 2
+	orientationRotate180  = 3
+	orientationFlipV      = 4
+	orientationTranspose  = 5
+	orientationRotate90   = 6
+	orientationTransverse = 7
+	orientationRotate270  = 8
+)
+
+const exifOrientationTag = 0x0112
+
+// decodeJPEG decodes a JPEG image and applies its EXIF orientation, so the returned image is upright.
+func decodeJPEG(r io.Reader) (image.Image, error) {
+	orientation, r, err := readJPEGOrientation(r)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := jpeg.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return orient(img, orientation), nil
+}
+
+// decodeJPEGConfig decodes the config of a JPEG image, dimensions are swapped if its EXIF orientation rotates it.
+func decodeJPEGConfig(r io.Reader) (image.Config, error) {
+	orientation, r, err := readJPEGOrientation(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	cfg, err := jpeg.DecodeConfig(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	if orientation >= orientationTranspose {
+		cfg.Width, cfg.Height = cfg.Height, cfg.Width
+	}
+
+	return cfg, nil
+}
+
+func jpegEncoder(quality int) func(w io.Writer, m image.Image) error {
+	return func(w io.Writer, m image.Image) error {
+		return jpeg.Encode(w, m, &jpeg.Options{Quality: quality})
+	}
+}
+
+// readJPEGOrientation reads the EXIF orientation from the markers preceding the image data.
+// It returns a reader replaying the consumed bytes followed by the rest of r.
+// Missing or malformed EXIF data results in orientationNormal, errors are returned only if reading r fails.
+func readJPEGOrientation(r io.Reader) (int, io.Reader, error) {
+	br := bufio.NewReader(r)
+	consumed := &bytes.Buffer{}
+	tr := io.TeeReader(br, consumed)
+	replay := func() io.Reader { return io.MultiReader(consumed, br) }
+
+	marker := make([]byte, 2)
+	if _, err := io.ReadFull(tr, marker); err != nil || marker[0] != 0xff || marker[1] != 0xd8 {
+		// not a JPEG, let the decoder report it
+		return orientationNormal, replay(), nil
+	}
+
+	for {
+		if _, err := io.ReadFull(tr, marker); err != nil || marker[0] != 0xff {
+			return orientationNormal, replay(), nil
+		}
+
+		// start of scan, EXIF data always precedes it
+		if marker[1] == 0xda {
+			return orientationNormal, replay(), nil
+		}
+
+		// markers without a length
+		if marker[1] == 0x01 || (marker[1] >= 0xd0 && marker[1] <= 0xd7) || marker[1] == 0xff {
+			continue
+		}
+
+		var length uint16
+		if err := binary.Read(tr, binary.BigEndian, &length); err != nil || length < 2 {
+			return orientationNormal, replay(), nil
+		}
+
+		segment := make([]byte, length-2)
+		if _, err := io.ReadFull(tr, segment); err != nil {
+			return orientationNormal, replay(), nil
+		}
+
+		if marker[1] == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			o, err := exifOrientation(segment[6:])
+			if err != nil {
+				o = orientationNormal
+			}
+
+			return o, replay(), nil
+		}
+	}
+}
+
+var errInvalidExif = errors.New("invalid exif data")
+
+// exifOrientation finds the orientation tag in the first IFD of TIFF structured EXIF data.
+func exifOrientation(tiff []byte) (int, error) {
+	if len(tiff) < 8 {
+		return 0, errInvalidExif
+	}
+
+	var order binary.ByteOrder
+
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, errInvalidExif
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) || ifd < 8 {
+		return 0, errInvalidExif
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+
+	for e := 0; e < entries; e++ {
+		entry := ifd + 2 + e*12
+		if entry+12 > len(tiff) {
+			return 0, errInvalidExif
+		}
+
+		if order.Uint16(tiff[entry:]) != exifOrientationTag {
+			continue
+		}
+
+		// the value is a SHORT stored in the first bytes of the value field
+		o := int(order.Uint16(tiff[entry+8:]))
+		if o < orientationNormal || o > orientationRotate270 {
+			return 0, errInvalidExif
+		}
+
+		return o, nil
+	}
+
+	return orientationNormal, nil
+}
+
+// orient transforms the image so it is displayed upright according to the EXIF orientation.
+// Grayscale images stay grayscale, all other images are converted to *image.RGBA.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= orientationNormal || orientation > orientationRotate270 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+
+	if orientation >= orientationTranspose {
+		dw, dh = h, w
+	}
+
+	// src returns the source coordinates of the destination pixel at x, y
+	var src func(x, y int) (int, int)
+
+	switch orientation {
+	case orientationFlipH:
+		src = func(x, y int) (int, int) { return w - 1 - x, y }
+	case orientationRotate180:
+		src = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case orientationFlipV:
+		src = func(x, y int) (int, int) { return x, h - 1 - y }
+	case orientationTranspose:
+		src = func(x, y int) (int, int) { return y, x }
+	case orientationRotate90:
+		src = func(x, y int) (int, int) { return y, h - 1 - x }
+	case orientationTransverse:
+		src = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case orientationRotate270:
+		src = func(x, y int) (int, int) { return w - 1 - y, x }
+	}
+
+	if gray, ok := img.(*image.Gray); ok {
+		dst := image.NewGray(image.Rect(0, 0, dw, dh))
+		permute(dst.Pix, dst.Stride, gray.Pix[gray.PixOffset(b.Min.X, b.Min.Y):], gray.Stride, 1, dw, dh, src)
+
+		return dst
+	}
+
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	} else {
+		rgba = rgba.SubImage(b).(*image.RGBA)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	permute(dst.Pix, dst.Stride, rgba.Pix, rgba.Stride, 4, dw, dh, src)
+
+	return dst
+}
+
+// permute copies pixels of bpp bytes from src to dst, which has dimensions w and h, using the coordinate mapping.
+func permute(dst []byte, dstStride int, src []byte, srcStride, bpp, w, h int, mapping func(x, y int) (int, int)) {
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx, sy := mapping(x, y)
+			copy(dst[y*dstStride+x*bpp:y*dstStride+x*bpp+bpp], src[sy*srcStride+sx*bpp:])
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package gocropper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exifJPEG encodes the image as JPEG with an EXIF segment containing the orientation tag.
+func exifJPEG(t *testing.T, img image.Image, order binary.ByteOrder, orientation uint16) []byte {
+	buf := &bytes.Buffer{}
+	assert.NoError(t, jpeg.Encode(buf, img, &jpeg.Options{Quality: 100}))
+
+	tiff := &bytes.Buffer{}
+	if order == binary.LittleEndian {
+		tiff.WriteString("II")
+	} else {
+		tiff.WriteString("MM")
+	}
+
+	// header, first IFD at offset 8 with a single entry: tag, type SHORT, count 1, value
+	for _, v := range []interface{}{uint16(42), uint32(8), uint16(1), uint16(exifOrientationTag), uint16(3), uint32(1), orientation, uint16(0), uint32(0)} {
+		assert.NoError(t, binary.Write(tiff, order, v))
+	}
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	app1 := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+
+	data := buf.Bytes()
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	out = append(out, segment...)
+
+	return append(out, data[2:]...)
+}
+
+func TestOrient(t *testing.T) {
+	// a b c
+	// d e f
+	src := image.NewGray(image.Rect(0, 0, 3, 2))
+	copy(src.Pix, []byte{'a', 'b', 'c', 'd', 'e', 'f'})
+
+	tests := []struct {
+		orientation int
+		exSize      image.Point
+		exPix       string
+	}{
+		{orientationNormal, image.Pt(3, 2), "abcdef"},
+		{orientationFlipH, image.Pt(3, 2), "cbafed"},
+		{orientationRotate180, image.Pt(3, 2), "fedcba"},
+		{orientationFlipV, image.Pt(3, 2), "defabc"},
+		{orientationTranspose, image.Pt(2, 3), "adbecf"},
+		{orientationRotate90, image.Pt(2, 3), "daebfc"},
+		{orientationTransverse, image.Pt(2, 3), "fcebda"},
+		{orientationRotate270, image.Pt(2, 3), "cfbead"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(rune('0'+tt.orientation)), func(t *testing.T) {
+			gray := orient(src, tt.orientation).(*image.Gray)
+			assert.Equal(t, tt.exSize, gray.Bounds().Size())
+			assert.Equal(t, tt.exPix, string(gray.Pix))
+
+			// other image types are converted to RGBA
+			ycc := image.NewYCbCr(src.Bounds(), image.YCbCrSubsampleRatio444)
+			for y := 0; y < 2; y++ {
+				for x := 0; x < 3; x++ {
+					ycc.Y[ycc.YOffset(x, y)] = src.GrayAt(x, y).Y
+					ycc.Cb[ycc.COffset(x, y)] = 128
+					ycc.Cr[ycc.COffset(x, y)] = 128
+				}
+			}
+
+			rgba := orient(ycc, tt.orientation)
+			assert.Equal(t, tt.exSize, rgba.Bounds().Size())
+
+			pix := ""
+			for y := 0; y < tt.exSize.Y; y++ {
+				for x := 0; x < tt.exSize.X; x++ {
+					pix += string(rune(color.GrayModel.Convert(rgba.At(x, y)).(color.Gray).Y))
+				}
+			}
+
+			assert.Equal(t, tt.exPix, pix)
+		})
+	}
+}
+
+func TestDecodeJPEG(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(5, 5, 15, 10), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	cropper, _ := NewCropper(WithBackgroundColor(color.White, 100))
+
+	tests := []struct {
+		name   string
+		data   []byte
+		exSize image.Point
+		exRect image.Rectangle
+	}{
+		{
+			name:   "no exif",
+			data:   func() []byte { buf := &bytes.Buffer{}; jpeg.Encode(buf, img, nil); return buf.Bytes() }(),
+			exSize: image.Pt(40, 20),
+			exRect: image.Rect(5, 5, 15, 10),
+		},
+		{
+			name:   "little endian rotate 90",
+			data:   exifJPEG(t, img, binary.LittleEndian, orientationRotate90),
+			exSize: image.Pt(20, 40),
+			exRect: image.Rect(10, 5, 15, 15),
+		},
+		{
+			name:   "big endian rotate 270",
+			data:   exifJPEG(t, img, binary.BigEndian, orientationRotate270),
+			exSize: image.Pt(20, 40),
+			exRect: image.Rect(5, 25, 10, 35),
+		},
+		{
+			name:   "big endian flip vertical",
+			data:   exifJPEG(t, img, binary.BigEndian, orientationFlipV),
+			exSize: image.Pt(40, 20),
+			exRect: image.Rect(5, 10, 15, 15),
+		},
+		{
+			name:   "invalid orientation",
+			data:   exifJPEG(t, img, binary.BigEndian, 9),
+			exSize: image.Pt(40, 20),
+			exRect: image.Rect(5, 5, 15, 10),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := decodeJPEGConfig(bytes.NewReader(tt.data))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.exSize, image.Pt(cfg.Width, cfg.Height))
+
+			decoded, err := decodeJPEG(bytes.NewReader(tt.data))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.exSize, decoded.Bounds().Size())
+			assert.Equal(t, tt.exRect, cropper.Rect(decoded))
+		})
+	}
+}
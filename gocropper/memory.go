@@ -0,0 +1,158 @@
+package gocropper
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"sync"
+)
+
+var ErrImageTooLarge = errors.New("image is too large")
+
+// ImageTooLargeError is returned for images exceeding the pixel limit or the whole memory budget of a Processor.
+type ImageTooLargeError struct {
+	Path string
+	// Pixels is the number of pixels of the image.
+	Pixels int64
+	// Bytes is the estimated size of the decoded image.
+	Bytes int64
+	// MaxPixels and MaxMemory are the limits of the Processor, zero if not set.
+	MaxPixels int64
+	MaxMemory int64
+}
+
+func (e *ImageTooLargeError) Error() string {
+	if e.MaxPixels > 0 && e.Pixels > e.MaxPixels {
+		return fmt.Sprintf("%s: %d pixels exceed the limit of %d pixels: %s", e.Path, e.Pixels, e.MaxPixels, ErrImageTooLarge)
+	}
+
+	return fmt.Sprintf("%s: decoded size of %d bytes exceeds the memory limit of %d bytes: %s", e.Path, e.Bytes, e.MaxMemory, ErrImageTooLarge)
+}
+
+// Is makes ImageTooLargeError match ErrImageTooLarge.
+func (e *ImageTooLargeError) Is(target error) bool {
+	return target == ErrImageTooLarge
+}
+
+// decodedSize estimates the size in bytes of the pixel buffer of a decoded image.
+func decodedSize(cfg image.Config) int64 {
+	return int64(cfg.Width) * int64(cfg.Height) * bytesPerPixel(cfg.ColorModel)
+}
+
+// loadedSize estimates the size in bytes of the memory held while loading the croppable with the config. Animated GIF and PNG
+// images count all their frames, icons and cursors all their entries and JPEG images rotated by their EXIF orientation the
+// copies made while orienting them, see orient. Animated PNG images, icons and cursors are read entirely before being decoded.
+func (c *Croppable) loadedSize(cfg image.Config) (int64, error) {
+	size := decodedSize(cfg)
+
+	if !c.is(gifCoder) && !c.is(pngCoder) && !c.is(jpegCoder) && !c.is(icoCoder) && !c.is(curCoder) {
+		return size, nil
+	}
+
+	file, err := os.Open(c.Path)
+	if err != nil {
+		return 0, err
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case c.is(gifCoder):
+		// frames are paletted, 1 byte per pixel
+		size, err = gifFramePixels(file)
+	case c.is(pngCoder):
+		var pixels int64
+		if pixels, err = apngFramePixels(file); pixels > 0 {
+			size = info.Size() + pixels*bytesPerPixel(cfg.ColorModel)
+		}
+	case c.is(jpegCoder):
+		var orientation int
+		if orientation, _, err = readJPEGOrientation(file); orientation > orientationNormal {
+			pixels := int64(cfg.Width) * int64(cfg.Height)
+
+			// gray images are permuted directly, other images are copied to RGBA before being permuted to RGBA
+			if cfg.ColorModel == color.GrayModel {
+				size += pixels
+			} else {
+				size += 2 * 4 * pixels
+			}
+		}
+	default:
+		var entries []iconDirEntry
+		if entries, err = decodeIconDir(file); err == nil {
+			// entries are decoded to NRGBA
+			size = info.Size()
+			for _, e := range entries {
+				size += int64(e.width) * int64(e.height) * 4
+			}
+		}
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", err.Error(), ErrImageLoadFailed)
+	}
+
+	return size, nil
+}
+
+func bytesPerPixel(model color.Model) int64 {
+	if _, ok := model.(color.Palette); ok {
+		return 1
+	}
+
+	switch model {
+	case color.GrayModel, color.AlphaModel:
+		return 1
+	case color.Gray16Model, color.Alpha16Model:
+		return 2
+	case color.YCbCrModel:
+		// assumes no chroma subsampling
+		return 3
+	case color.RGBA64Model, color.NRGBA64Model:
+		return 8
+	default:
+		return 4
+	}
+}
+
+// memoryBudget admits reservations of memory while their total stays under the limit.
+type memoryBudget struct {
+	limit int64
+	used  int64
+	mu    sync.Mutex
+	cond  *sync.Cond
+}
+
+func newMemoryBudget(limit int64) *memoryBudget {
+	b := &memoryBudget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+
+	return b
+}
+
+// acquire blocks until n bytes fit in the budget. n must not exceed the limit.
+func (b *memoryBudget) acquire(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.used+n > b.limit {
+		b.cond.Wait()
+	}
+
+	b.used += n
+}
+
+func (b *memoryBudget) release(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.used -= n
+	b.cond.Broadcast()
+}
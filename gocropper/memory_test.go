@@ -0,0 +1,148 @@
+package gocropper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCroppable_LoadedSize(t *testing.T) {
+	palette := color.Palette{color.Transparent, color.Black}
+
+	animation := &gif.GIF{
+		Image: []*image.Paletted{
+			image.NewPaletted(image.Rect(0, 0, 20, 10), palette),
+			image.NewPaletted(image.Rect(5, 5, 10, 10), palette),
+			image.NewPaletted(image.Rect(0, 0, 4, 4), palette),
+		},
+		Delay:  []int{10, 10, 10},
+		Config: image.Config{ColorModel: palette, Width: 20, Height: 10},
+	}
+
+	apng := func(def CroppableImage) *APNG {
+		return &APNG{
+			Width:  20,
+			Height: 10,
+			Frames: []*APNGFrame{
+				{Image: image.NewPaletted(image.Rect(0, 0, 20, 10), palette)},
+				{Image: image.NewPaletted(image.Rect(5, 5, 10, 10), palette)},
+			},
+			Default: def,
+		}
+	}
+
+	icon := &Icon{Entries: []*IconEntry{
+		{Image: image.NewNRGBA(image.Rect(0, 0, 16, 16))},
+		{Image: image.NewNRGBA(image.Rect(0, 0, 32, 32)), PNG: true},
+	}}
+
+	rgb := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	gray := image.NewGray(image.Rect(0, 0, 8, 4))
+
+	tests := []struct {
+		name   string
+		fn     string
+		encode func(w io.Writer) error
+		// exSize returns the expected size for the size of the file
+		exSize func(file int64) int64
+	}{
+		{
+			name:   "gif frames",
+			fn:     "anim.gif",
+			encode: func(w io.Writer) error { return gif.EncodeAll(w, animation) },
+			exSize: func(int64) int64 { return 20*10 + 5*5 + 4*4 },
+		},
+		{
+			name:   "png",
+			fn:     "still.png",
+			encode: func(w io.Writer) error { return png.Encode(w, animation.Image[0]) },
+			exSize: func(int64) int64 { return 20 * 10 },
+		},
+		{
+			name:   "apng frames",
+			fn:     "anim.png",
+			encode: func(w io.Writer) error { return encodeAPNG(apng(nil))(w, nil) },
+			exSize: func(file int64) int64 { return file + 20*10 + 5*5 },
+		},
+		{
+			name:   "apng frames and default image",
+			fn:     "default.png",
+			encode: func(w io.Writer) error { return encodeAPNG(apng(animation.Image[0]))(w, nil) },
+			exSize: func(file int64) int64 { return file + 2*20*10 + 5*5 },
+		},
+		{
+			name:   "icon entries",
+			fn:     "icon.ico",
+			encode: func(w io.Writer) error { return encodeIcon(icon)(w, nil) },
+			exSize: func(file int64) int64 { return file + (16*16+32*32)*4 },
+		},
+		{
+			name:   "jpeg",
+			fn:     "upright.jpg",
+			encode: func(w io.Writer) error { return write(w, exifJPEG(t, rgb, binary.BigEndian, orientationNormal)) },
+			exSize: func(int64) int64 { return 8 * 4 * 3 },
+		},
+		{
+			name:   "rotated jpeg",
+			fn:     "rotated.jpg",
+			encode: func(w io.Writer) error { return write(w, exifJPEG(t, rgb, binary.BigEndian, orientationRotate90)) },
+			exSize: func(int64) int64 { return 8 * 4 * (3 + 4 + 4) },
+		},
+		{
+			name:   "rotated gray jpeg",
+			fn:     "gray.jpg",
+			encode: func(w io.Writer) error { return write(w, exifJPEG(t, gray, binary.BigEndian, orientationRotate90)) },
+			exSize: func(int64) int64 { return 8 * 4 * 2 },
+		},
+	}
+
+	dir := t.TempDir()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fp := path.Join(dir, tt.fn)
+
+			buf := &bytes.Buffer{}
+			assert.NoError(t, tt.encode(buf))
+			assert.NoError(t, os.WriteFile(fp, buf.Bytes(), 0o644))
+
+			c, err := NewCroppable(fp)
+			assert.NoError(t, err)
+
+			cfg, err := c.Config()
+			assert.NoError(t, err)
+
+			size, err := c.loadedSize(cfg)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.exSize(int64(buf.Len())), size)
+		})
+	}
+
+	t.Run("truncated gif", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		assert.NoError(t, gif.EncodeAll(buf, animation))
+
+		fp := path.Join(dir, "truncated.gif")
+		assert.NoError(t, os.WriteFile(fp, buf.Bytes()[:buf.Len()-20], 0o644))
+
+		c, err := NewCroppable(fp)
+		assert.NoError(t, err)
+
+		_, err = c.loadedSize(image.Config{})
+		assert.ErrorIs(t, err, ErrImageLoadFailed)
+	})
+}
+
+func write(w io.Writer, data []byte) error {
+	_, err := w.Write(data)
+	return err
+}
@@ -0,0 +1,190 @@
+package gocropper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrUnknownMetadataFormat = errors.New("unknown metadata format")
+
+// MetadataFormat is the encoding of saved Metadata.
+type MetadataFormat int
+
+const (
+	// MetadataNone disables saving metadata.
+	MetadataNone MetadataFormat = iota
+	MetadataJSON
+	MetadataYAML
+)
+
+var metadataFormatNames = map[MetadataFormat]string{
+	MetadataNone: "none",
+	MetadataJSON: "json",
+	MetadataYAML: "yaml",
+}
+
+// ParseMetadataFormat returns the MetadataFormat with the given name: none, json or yaml.
+func ParseMetadataFormat(name string) (MetadataFormat, error) {
+	for f, n := range metadataFormatNames {
+		if strings.EqualFold(n, name) {
+			return f, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%s: %w", name, ErrUnknownMetadataFormat)
+}
+
+func (f MetadataFormat) String() string {
+	if name, ok := metadataFormatNames[f]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("MetadataFormat(%d)", int(f))
+}
+
+// Metadata describes where a saved image was on its source image, e.g. for game engines rendering trimmed sprites in place.
+type Metadata struct {
+	// Source and Output are the paths of the source image and of the saved image.
+	Source string `json:"source" yaml:"source"`
+	Output string `json:"output" yaml:"output"`
+	// Bounds are the bounds of the source image, of the largest entry for icons and cursors.
+	Bounds MetadataRect `json:"bounds" yaml:"bounds"`
+	// Rect is the cropping rectangle of the source image, see Rect, it does not include padding.
+	Rect    MetadataRect `json:"rect" yaml:"rect"`
+	Padding int          `json:"padding" yaml:"padding"`
+	// OffsetX and OffsetY are the position of the saved image on the source image, relative to its bounds.
+	// They are negative if the padding reaches beyond the source image.
+	OffsetX int `json:"offsetX" yaml:"offsetX"`
+	OffsetY int `json:"offsetY" yaml:"offsetY"`
+	// Width and Height are the size of the saved image.
+	Width  int `json:"width" yaml:"width"`
+	Height int `json:"height" yaml:"height"`
+}
+
+// MetadataRect is a rectangle with its top left corner at X, Y.
+type MetadataRect struct {
+	X int `json:"x" yaml:"x"`
+	Y int `json:"y" yaml:"y"`
+	W int `json:"w" yaml:"w"`
+	H int `json:"h" yaml:"h"`
+}
+
+func metadataRect(r image.Rectangle) MetadataRect {
+	return MetadataRect{X: r.Min.X, Y: r.Min.Y, W: r.Dx(), H: r.Dy()}
+}
+
+// sourceBounds returns the bounds of the source image, of the largest entry for icons. The bounds of croppables which
+// are not loaded, e.g. streamed images, are decoded from the image header.
+func sourceBounds(c *Croppable) (image.Rectangle, error) {
+	switch {
+	case c.GIF != nil:
+		return gifScreen(c.GIF), nil
+	case c.APNG != nil:
+		return c.APNG.canvas(), nil
+	case c.Icon != nil:
+		return c.Icon.largest().Bounds(), nil
+	case c.Image != nil:
+		return c.Image.Bounds(), nil
+	}
+
+	cfg, err := c.Config()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+
+	return image.Rect(0, 0, cfg.Width, cfg.Height), nil
+}
+
+// saveMetadata saves the metadata of an image cropped to rect and saved at outPath next to it, and collects it if enabled.
+func (i *Cropper) saveMetadata(source *Croppable, outPath string, rect image.Rectangle) error {
+	if i.sidecar == MetadataNone && !i.collect {
+		return nil
+	}
+
+	bounds, err := sourceBounds(source)
+	if err != nil {
+		return err
+	}
+
+	padded := rect.Inset(-i.padding)
+	m := Metadata{
+		Source:  source.Path,
+		Output:  outPath,
+		Bounds:  metadataRect(bounds),
+		Rect:    metadataRect(rect),
+		Padding: i.padding,
+		OffsetX: padded.Min.X - bounds.Min.X,
+		OffsetY: padded.Min.Y - bounds.Min.Y,
+		Width:   padded.Dx(),
+		Height:  padded.Dy(),
+	}
+
+	if i.sidecar != MetadataNone {
+		if err := writeMetadata(outPath+"."+i.sidecar.String(), i.sidecar, m); err != nil {
+			return err
+		}
+	}
+
+	if i.collect {
+		i.metadataMu.Lock()
+		i.metadata = append(i.metadata, m)
+		i.metadataMu.Unlock()
+	}
+
+	return nil
+}
+
+// Metadata returns the metadata of all images saved by the cropper since it was created, sorted by output path.
+// Metadata is collected only if enabled with WithCollectMetadata.
+func (i *Cropper) Metadata() []Metadata {
+	i.metadataMu.Lock()
+	defer i.metadataMu.Unlock()
+
+	metadata := append([]Metadata{}, i.metadata...)
+	sort.Slice(metadata, func(a, b int) bool {
+		return metadata[a].Output < metadata[b].Output
+	})
+
+	return metadata
+}
+
+// SaveMetadata saves the metadata of all images saved by the cropper in a single manifest, see Metadata.
+// The manifest is saved as YAML if the path has a .yaml or .yml extension, as JSON otherwise.
+func (i *Cropper) SaveMetadata(path string) error {
+	format := MetadataJSON
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		format = MetadataYAML
+	}
+
+	return writeMetadata(path, format, i.Metadata())
+}
+
+func writeMetadata(path string, format MetadataFormat, v any) error {
+	var (
+		data []byte
+		err  error
+	)
+
+	switch format {
+	case MetadataJSON:
+		data, err = json.MarshalIndent(v, "", "  ")
+	case MetadataYAML:
+		data, err = yaml.Marshal(v)
+	default:
+		err = fmt.Errorf("%s: %w", format, ErrUnknownMetadataFormat)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
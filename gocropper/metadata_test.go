@@ -0,0 +1,182 @@
+package gocropper_test
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/H3Cki/gocrop/gocropper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCropper_Sidecar(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  gocropper.MetadataFormat
+		padding int
+		stream  bool
+		fn      string
+		want    gocropper.Metadata
+	}{
+		{
+			name:    "json",
+			format:  gocropper.MetadataJSON,
+			padding: 5,
+			fn:      "circle-25-25-75-75.png",
+			want: gocropper.Metadata{
+				Bounds:  gocropper.MetadataRect{X: 0, Y: 0, W: 100, H: 100},
+				Rect:    gocropper.MetadataRect{X: 25, Y: 25, W: 50, H: 50},
+				Padding: 5,
+				OffsetX: 20,
+				OffsetY: 20,
+				Width:   60,
+				Height:  60,
+			},
+		},
+		{
+			name:    "yaml with padding beyond the image",
+			format:  gocropper.MetadataYAML,
+			padding: 30,
+			fn:      "rect-25-30-75-70.png",
+			want: gocropper.Metadata{
+				Bounds:  gocropper.MetadataRect{X: 0, Y: 0, W: 100, H: 100},
+				Rect:    gocropper.MetadataRect{X: 25, Y: 30, W: 50, H: 40},
+				Padding: 30,
+				OffsetX: -5,
+				OffsetY: 0,
+				Width:   110,
+				Height:  100,
+			},
+		},
+		{
+			name:   "streamed",
+			format: gocropper.MetadataJSON,
+			stream: true,
+			fn:     "rect-25-30-75-70.png",
+			want: gocropper.Metadata{
+				Bounds:  gocropper.MetadataRect{X: 0, Y: 0, W: 100, H: 100},
+				Rect:    gocropper.MetadataRect{X: 25, Y: 30, W: 50, H: 40},
+				OffsetX: 25,
+				OffsetY: 30,
+				Width:   50,
+				Height:  40,
+			},
+		},
+		{
+			name:   "gif",
+			format: gocropper.MetadataJSON,
+			fn:     "line1px-49-0-50-100.gif",
+			want: gocropper.Metadata{
+				Bounds:  gocropper.MetadataRect{X: 0, Y: 0, W: 100, H: 100},
+				Rect:    gocropper.MetadataRect{X: 49, Y: 0, W: 1, H: 100},
+				OffsetX: 49,
+				Width:   1,
+				Height:  100,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outDir := t.TempDir()
+			src := path.Join("testdata/described", tt.fn)
+
+			cropper, err := gocropper.NewCropper(
+				gocropper.WithOutDir(outDir),
+				gocropper.WithPadding(tt.padding),
+				gocropper.WithSidecar(tt.format),
+			)
+			assert.NoError(t, err)
+
+			if tt.stream {
+				assert.NoError(t, cropper.StreamCropAndSave(src))
+			} else {
+				c, err := gocropper.Load(src)
+				assert.NoError(t, err)
+				assert.NoError(t, cropper.CropAndSave(c))
+			}
+
+			out := path.Join(outDir, tt.fn)
+			data, err := os.ReadFile(out + "." + tt.format.String())
+			assert.NoError(t, err)
+
+			got := gocropper.Metadata{}
+			if tt.format == gocropper.MetadataYAML {
+				assert.NoError(t, yaml.Unmarshal(data, &got))
+			} else {
+				assert.NoError(t, json.Unmarshal(data, &got))
+			}
+
+			tt.want.Source, tt.want.Output = src, out
+			assert.Equal(t, tt.want, got)
+
+			cropped, err := gocropper.Load(out)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want.Width, cropped.Image.Bounds().Dx())
+			assert.Equal(t, tt.want.Height, cropped.Image.Bounds().Dy())
+		})
+	}
+}
+
+func TestCropper_SaveMetadata(t *testing.T) {
+	outDir := t.TempDir()
+
+	cropper, err := gocropper.NewCropper(gocropper.WithOutDir(outDir), gocropper.WithCollectMetadata(true))
+	assert.NoError(t, err)
+
+	fns := []string{"rect-25-30-75-70.png", "circle-25-25-75-75.png"}
+
+	croppables := []*gocropper.Croppable{}
+
+	for _, fn := range fns {
+		c, err := gocropper.NewCroppable(path.Join("testdata/described", fn))
+		assert.NoError(t, err)
+
+		croppables = append(croppables, c)
+	}
+
+	processor, err := gocropper.NewProcessor(cropper, gocropper.WithJobs(2))
+	assert.NoError(t, err)
+
+	processor.Process(croppables, nil)
+
+	metadata := cropper.Metadata()
+	assert.Len(t, metadata, 2)
+	// sorted by output path
+	assert.Equal(t, path.Join(outDir, "circle-25-25-75-75.png"), metadata[0].Output)
+	assert.Equal(t, gocropper.MetadataRect{X: 25, Y: 30, W: 50, H: 40}, metadata[1].Rect)
+
+	// no sidecars are saved
+	_, err = os.Stat(path.Join(outDir, "circle-25-25-75-75.png.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	for _, fn := range []string{"manifest.json", "manifest.yml"} {
+		fp := path.Join(outDir, fn)
+		assert.NoError(t, cropper.SaveMetadata(fp))
+
+		data, err := os.ReadFile(fp)
+		assert.NoError(t, err)
+
+		saved := []gocropper.Metadata{}
+		if fn == "manifest.yml" {
+			assert.NoError(t, yaml.Unmarshal(data, &saved))
+		} else {
+			assert.NoError(t, json.Unmarshal(data, &saved))
+		}
+
+		assert.Equal(t, metadata, saved)
+	}
+}
+
+func TestParseMetadataFormat(t *testing.T) {
+	for _, name := range []string{"none", "json", "YAML"} {
+		f, err := gocropper.ParseMetadataFormat(name)
+		assert.NoError(t, err)
+		assert.Equal(t, f.String(), map[string]string{"none": "none", "json": "json", "YAML": "yaml"}[name])
+	}
+
+	_, err := gocropper.ParseMetadataFormat("xml")
+	assert.ErrorIs(t, err, gocropper.ErrUnknownMetadataFormat)
+}
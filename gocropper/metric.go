@@ -0,0 +1,274 @@
+package gocropper
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
+var ErrUnknownMetric = errors.New("unknown metric")
+
+// Metric selects the way of measuring the difference between two colors,
+// it decides which pixels are close enough to the background color to be considered empty.
+type Metric int
+
+const (
+	// MetricRGB is the euclidean distance between RGBA values, in 8-bit units (0-510).
+	MetricRGB Metric = iota
+	// MetricMaxChannel is the largest difference of a single RGBA channel, in 8-bit units (0-255).
+	MetricMaxChannel
+	// MetricCIE76 is the CIE 1976 Delta E, the euclidean distance between colors in CIE Lab space.
+	MetricCIE76
+	// MetricCIEDE2000 is the CIEDE2000 Delta E, a perceptually uniform distance between colors in CIE Lab space.
+	MetricCIEDE2000
+)
+
+var metricNames = map[Metric]string{
+	MetricRGB:        "rgb",
+	MetricMaxChannel: "max",
+	MetricCIE76:      "cie76",
+	MetricCIEDE2000:  "ciede2000",
+}
+
+// ParseMetric returns the Metric with the given name: rgb, max, cie76 or ciede2000.
+func ParseMetric(name string) (Metric, error) {
+	for m, n := range metricNames {
+		if strings.EqualFold(n, name) {
+			return m, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%s: %w", name, ErrUnknownMetric)
+}
+
+func (m Metric) String() string {
+	if name, ok := metricNames[m]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Metric(%d)", int(m))
+}
+
+// Distance returns the distance between two colors.
+// Lab based metrics compare colors composited over black and include the difference of their alpha,
+// scaled to the range of Lab lightness (0-100).
+func (m Metric) Distance(c1, c2 color.Color) float64 {
+	return m.distanceFrom(m.reference(toRGBA64(c1)), toRGBA64(c2))
+}
+
+// metricRef holds a color along with values precomputed for repeated comparisons against it.
+type metricRef struct {
+	c   color.RGBA64
+	lab labColor
+}
+
+func (m Metric) reference(c color.RGBA64) metricRef {
+	ref := metricRef{c: c}
+
+	if m == MetricCIE76 || m == MetricCIEDE2000 {
+		ref.lab = toLab(c)
+	}
+
+	return ref
+}
+
+func (m Metric) distanceFrom(ref metricRef, c color.RGBA64) float64 {
+	switch m {
+	case MetricMaxChannel:
+		return maxChannelDistance(ref.c, c)
+	case MetricCIE76:
+		return withAlpha(cie76(ref.lab, toLab(c)), ref.c.A, c.A)
+	case MetricCIEDE2000:
+		return withAlpha(ciede2000(ref.lab, toLab(c)), ref.c.A, c.A)
+	default:
+		return rgbDistance(ref.c, c)
+	}
+}
+
+func toRGBA64(c color.Color) color.RGBA64 {
+	return color.RGBA64Model.Convert(c).(color.RGBA64)
+}
+
+// rgbDistance returns the euclidean distance between two alpha-premultiplied colors in 8-bit units.
+func rgbDistance(c1, c2 color.RGBA64) float64 {
+	dr := (float64(c1.R) - float64(c2.R)) / 0x101
+	dg := (float64(c1.G) - float64(c2.G)) / 0x101
+	db := (float64(c1.B) - float64(c2.B)) / 0x101
+	da := (float64(c1.A) - float64(c2.A)) / 0x101
+
+	return math.Sqrt(dr*dr + dg*dg + db*db + da*da)
+}
+
+// maxChannelDistance returns the largest difference of a single channel of two alpha-premultiplied colors in 8-bit units.
+func maxChannelDistance(c1, c2 color.RGBA64) float64 {
+	d := absDiff(c1.R, c2.R)
+
+	for _, cd := range []uint16{absDiff(c1.G, c2.G), absDiff(c1.B, c2.B), absDiff(c1.A, c2.A)} {
+		if cd > d {
+			d = cd
+		}
+	}
+
+	return float64(d) / 0x101
+}
+
+func absDiff(a, b uint16) uint16 {
+	if a > b {
+		return a - b
+	}
+
+	return b - a
+}
+
+func withAlpha(deltaE float64, a1, a2 uint16) float64 {
+	da := float64(absDiff(a1, a2)) / 0xffff * 100
+
+	return math.Sqrt(deltaE*deltaE + da*da)
+}
+
+type labColor struct {
+	l, a, b float64
+}
+
+// srgbToLinear maps 8-bit sRGB values to linear light.
+var srgbToLinear = func() (lut [256]float64) {
+	for i := range lut {
+		v := float64(i) / 0xff
+		if v <= 0.04045 {
+			lut[i] = v / 12.92
+		} else {
+			lut[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+
+	return lut
+}()
+
+// D65 reference white.
+const (
+	whiteX = 0.95047
+	whiteY = 1.0
+	whiteZ = 1.08883
+)
+
+// toLab converts an alpha-premultiplied color, which equals the color composited over black, to CIE Lab.
+func toLab(c color.RGBA64) labColor {
+	r := srgbToLinear[c.R>>8]
+	g := srgbToLinear[c.G>>8]
+	b := srgbToLinear[c.B>>8]
+
+	x := labF((0.4124564*r + 0.3575761*g + 0.1804375*b) / whiteX)
+	y := labF((0.2126729*r + 0.7151522*g + 0.0721750*b) / whiteY)
+	z := labF((0.0193339*r + 0.1191920*g + 0.9503041*b) / whiteZ)
+
+	return labColor{
+		l: 116*y - 16,
+		a: 500 * (x - y),
+		b: 200 * (y - z),
+	}
+}
+
+func labF(t float64) float64 {
+	const epsilon = 216.0 / 24389.0
+	const kappa = 24389.0 / 27.0
+
+	if t > epsilon {
+		return math.Cbrt(t)
+	}
+
+	return (kappa*t + 16) / 116
+}
+
+func cie76(c1, c2 labColor) float64 {
+	dl := c1.l - c2.l
+	da := c1.a - c2.a
+	db := c1.b - c2.b
+
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
+
+// ciede2000 implements the CIEDE2000 color difference formula with unit weighting factors.
+func ciede2000(c1, c2 labColor) float64 {
+	const pow25to7 = 6103515625.0
+
+	cab1 := math.Hypot(c1.a, c1.b)
+	cab2 := math.Hypot(c2.a, c2.b)
+	cabMean7 := math.Pow((cab1+cab2)/2, 7)
+	g := 0.5 * (1 - math.Sqrt(cabMean7/(cabMean7+pow25to7)))
+
+	a1 := (1 + g) * c1.a
+	a2 := (1 + g) * c2.a
+	cp1 := math.Hypot(a1, c1.b)
+	cp2 := math.Hypot(a2, c2.b)
+	hp1 := hueAngle(c1.b, a1)
+	hp2 := hueAngle(c2.b, a2)
+
+	dlp := c2.l - c1.l
+	dcp := cp2 - cp1
+
+	dhp := 0.0
+	if cp1*cp2 != 0 {
+		dhp = hp2 - hp1
+		if dhp > 180 {
+			dhp -= 360
+		} else if dhp < -180 {
+			dhp += 360
+		}
+	}
+
+	dHp := 2 * math.Sqrt(cp1*cp2) * math.Sin(radians(dhp/2))
+
+	lpMean := (c1.l + c2.l) / 2
+	cpMean := (cp1 + cp2) / 2
+
+	hpMean := hp1 + hp2
+	if cp1*cp2 != 0 {
+		switch {
+		case math.Abs(hp1-hp2) <= 180:
+			hpMean /= 2
+		case hp1+hp2 < 360:
+			hpMean = (hpMean + 360) / 2
+		default:
+			hpMean = (hpMean - 360) / 2
+		}
+	}
+
+	t := 1 - 0.17*math.Cos(radians(hpMean-30)) +
+		0.24*math.Cos(radians(2*hpMean)) +
+		0.32*math.Cos(radians(3*hpMean+6)) -
+		0.20*math.Cos(radians(4*hpMean-63))
+
+	dTheta := 30 * math.Exp(-math.Pow((hpMean-275)/25, 2))
+	cpMean7 := math.Pow(cpMean, 7)
+	rc := 2 * math.Sqrt(cpMean7/(cpMean7+pow25to7))
+	lm := (lpMean - 50) * (lpMean - 50)
+	sl := 1 + 0.015*lm/math.Sqrt(20+lm)
+	sc := 1 + 0.045*cpMean
+	sh := 1 + 0.015*cpMean*t
+	rt := -math.Sin(radians(2*dTheta)) * rc
+
+	l := dlp / sl
+	c := dcp / sc
+	h := dHp / sh
+
+	return math.Sqrt(l*l + c*c + h*h + rt*c*h)
+}
+
+func hueAngle(b, a float64) float64 {
+	if a == 0 && b == 0 {
+		return 0
+	}
+
+	h := math.Atan2(b, a) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+
+	return h
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,86 @@
+package gocropper
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCIEDE2000(t *testing.T) {
+	// reference values from Sharma, Wu, Dalal: "The CIEDE2000 Color-Difference Formula"
+	tests := []struct {
+		c1, c2 labColor
+		exDE   float64
+	}{
+		{labColor{50, 2.6772, -79.7751}, labColor{50, 0, -82.7485}, 2.0425},
+		{labColor{50, 3.1571, -77.2803}, labColor{50, 0, -82.7485}, 2.8615},
+		{labColor{50, 0, 0}, labColor{50, -1, 2}, 2.3669},
+		{labColor{50, 2.5, 0}, labColor{73, 25, -18}, 27.1492},
+		{labColor{50, 2.5, 0}, labColor{50, 0, -2.5}, 4.3065},
+		{labColor{60.2574, -34.0099, 36.2677}, labColor{60.4626, -34.1751, 39.4387}, 1.2644},
+		{labColor{2.0776, 0.0795, -1.1350}, labColor{0.9033, -0.0636, -0.5514}, 0.9082},
+	}
+
+	for _, tt := range tests {
+		assert.InDelta(t, tt.exDE, ciede2000(tt.c1, tt.c2), 0.0001)
+		assert.InDelta(t, tt.exDE, ciede2000(tt.c2, tt.c1), 0.0001)
+	}
+}
+
+func TestToLab(t *testing.T) {
+	tests := []struct {
+		c     color.RGBA64
+		exLab labColor
+	}{
+		{color.RGBA64{0, 0, 0, 0xffff}, labColor{0, 0, 0}},
+		{color.RGBA64{0xffff, 0xffff, 0xffff, 0xffff}, labColor{100, 0, 0}},
+		{color.RGBA64{0xffff, 0, 0, 0xffff}, labColor{53.24, 80.09, 67.20}},
+	}
+
+	for _, tt := range tests {
+		lab := toLab(tt.c)
+		assert.InDelta(t, tt.exLab.l, lab.l, 0.01)
+		assert.InDelta(t, tt.exLab.a, lab.a, 0.01)
+		assert.InDelta(t, tt.exLab.b, lab.b, 0.01)
+	}
+}
+
+func TestMetric_Distance(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+	offWhite := color.RGBA{250, 252, 255, 255}
+
+	tests := []struct {
+		metric Metric
+		c1, c2 color.Color
+		exDist float64
+	}{
+		{MetricRGB, white, white, 0},
+		{MetricRGB, white, offWhite, 5.8309},
+		{MetricRGB, color.Transparent, color.Black, 255},
+		{MetricMaxChannel, white, offWhite, 5},
+		{MetricMaxChannel, color.Black, color.RGBA{10, 20, 30, 255}, 30},
+		{MetricCIE76, white, white, 0},
+		{MetricCIE76, color.Black, white, 100},
+		{MetricCIE76, color.Transparent, color.Black, 100},
+		{MetricCIEDE2000, color.Black, white, 100},
+		{MetricCIEDE2000, white, offWhite, 1.7334},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.metric.String(), func(t *testing.T) {
+			assert.InDelta(t, tt.exDist, tt.metric.Distance(tt.c1, tt.c2), 0.0001)
+		})
+	}
+}
+
+func TestParseMetric(t *testing.T) {
+	for m, name := range metricNames {
+		parsed, err := ParseMetric(name)
+		assert.NoError(t, err)
+		assert.Equal(t, m, parsed)
+	}
+
+	_, err := ParseMetric("cmyk")
+	assert.ErrorIs(t, err, ErrUnknownMetric)
+}
@@ -0,0 +1,550 @@
+package gocropper
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidNetpbm = errors.New("invalid netpbm")
+
+// pam tuple types written by the encoder
+const (
+	pamGrayscale = "GRAYSCALE"
+	pamRGB       = "RGB"
+	pamRGBAlpha  = "RGB_ALPHA"
+)
+
+type netpbmHeader struct {
+	// magic is the format number, 1-7 for P1-P7.
+	magic         byte
+	width, height int
+	// depth is the number of samples per pixel.
+	depth  int
+	maxval int
+	// alpha is true if the last sample of a pixel is alpha.
+	alpha bool
+}
+
+// plain returns true for the ascii formats P1-P3.
+func (h netpbmHeader) plain() bool {
+	return h.magic <= '3'
+}
+
+func (h netpbmHeader) colorModel() color.Model {
+	deep := h.maxval > 0xff
+
+	switch {
+	case h.alpha && deep:
+		return color.NRGBA64Model
+	case h.alpha:
+		return color.NRGBAModel
+	case h.depth == 1 && deep:
+		return color.Gray16Model
+	case h.depth == 1:
+		return color.GrayModel
+	case deep:
+		return color.RGBA64Model
+	default:
+		return color.RGBAModel
+	}
+}
+
+func readNetpbmHeader(r *bufio.Reader) (netpbmHeader, error) {
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return netpbmHeader{}, err
+	}
+
+	if magic[0] != 'P' || magic[1] < '1' || magic[1] > '7' {
+		return netpbmHeader{}, fmt.Errorf("missing magic: %w", ErrInvalidNetpbm)
+	}
+
+	h := netpbmHeader{magic: magic[1]}
+
+	if h.magic == '7' {
+		if err := readPAMHeader(r, &h); err != nil {
+			return netpbmHeader{}, err
+		}
+	} else {
+		if err := readPNMHeader(r, &h); err != nil {
+			return netpbmHeader{}, err
+		}
+	}
+
+	if h.width <= 0 || h.height <= 0 || h.width > 1<<20 || h.height > 1<<20 {
+		return netpbmHeader{}, fmt.Errorf("%dx%d: %w", h.width, h.height, ErrInvalidNetpbm)
+	}
+
+	if h.maxval <= 0 || h.maxval > 0xffff {
+		return netpbmHeader{}, fmt.Errorf("maxval %d: %w", h.maxval, ErrInvalidNetpbm)
+	}
+
+	return h, nil
+}
+
+// readPNMHeader reads the whitespace separated width, height and maxval of P1-P6 images, bitmaps have no maxval.
+func readPNMHeader(r *bufio.Reader, h *netpbmHeader) error {
+	fields := []*int{&h.width, &h.height, &h.maxval}
+
+	switch h.magic {
+	case '1', '4':
+		fields = fields[:2]
+		h.depth, h.maxval = 1, 1
+	case '2', '5':
+		h.depth = 1
+	default:
+		h.depth = 3
+	}
+
+	for _, f := range fields {
+		n, err := readNetpbmInt(r)
+		if err != nil {
+			return err
+		}
+
+		*f = n
+	}
+
+	// a single whitespace character separates the header from raw samples
+	if !h.plain() {
+		if _, err := r.ReadByte(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readPAMHeader reads the header lines of P7 images up to ENDHDR.
+// The tuple type is informational, the samples are interpreted by depth: grayscale, grayscale with alpha, rgb and rgb with alpha.
+func readPAMHeader(r *bufio.Reader, h *netpbmHeader) error {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if fields[0] == "ENDHDR" {
+			break
+		}
+
+		if fields[0] == "TUPLTYPE" {
+			continue
+		}
+
+		if len(fields) != 2 {
+			return fmt.Errorf("header line %q: %w", strings.TrimSpace(line), ErrInvalidNetpbm)
+		}
+
+		n, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("%s: %w", fields[0], ErrInvalidNetpbm)
+		}
+
+		switch fields[0] {
+		case "WIDTH":
+			h.width = n
+		case "HEIGHT":
+			h.height = n
+		case "DEPTH":
+			h.depth = n
+		case "MAXVAL":
+			h.maxval = n
+		}
+	}
+
+	if h.depth < 1 || h.depth > 4 {
+		return fmt.Errorf("depth %d: %w", h.depth, ErrInvalidNetpbm)
+	}
+
+	h.alpha = h.depth == 2 || h.depth == 4
+
+	return nil
+}
+
+// readNetpbmInt reads a decimal number skipping leading whitespace and comments.
+func readNetpbmInt(r *bufio.Reader) (int, error) {
+	var digits []byte
+
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF && len(digits) > 0 {
+			return strconv.Atoi(string(digits))
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case b >= '0' && b <= '9':
+			digits = append(digits, b)
+		case len(digits) > 0:
+			// the terminating whitespace or comment is left for the next read
+			r.UnreadByte()
+			return strconv.Atoi(string(digits))
+		case b == '#':
+			if _, err := r.ReadString('\n'); err != nil {
+				return 0, err
+			}
+		case !isNetpbmSpace(b):
+			return 0, fmt.Errorf("unexpected %q: %w", b, ErrInvalidNetpbm)
+		}
+	}
+}
+
+func isNetpbmSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\v' || b == '\f'
+}
+
+// decodeNetpbmConfig decodes the dimensions and color model of a Netpbm image.
+func decodeNetpbmConfig(r io.Reader) (image.Config, error) {
+	h, err := readNetpbmHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	return image.Config{ColorModel: h.colorModel(), Width: h.width, Height: h.height}, nil
+}
+
+// decodeNetpbm decodes plain and raw PBM, PGM and PPM images (P1-P6) and PAM images (P7).
+// Bitmaps and grayscale images are decoded into *image.Gray, or *image.Gray16 if maxval is above 255,
+// color images into *image.RGBA or *image.RGBA64 and images with alpha into *image.NRGBA or *image.NRGBA64.
+// Samples are scaled from maxval to the full range of the image type.
+func decodeNetpbm(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+
+	h, err := readNetpbmHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	img, pix, channels := newNetpbmImage(image.Rect(0, 0, h.width, h.height), h)
+
+	bps := 1
+	if h.maxval > 0xff {
+		bps = 2
+	}
+
+	// samples are decoded one row at a time straight into the pixel buffer
+	rr := newNetpbmRowReader(br, h)
+	row := make([]int, h.width*h.depth)
+	stride := h.width * channels * bps
+
+	for y := 0; y < h.height; y++ {
+		if err := rr.read(row); err != nil {
+			return nil, err
+		}
+
+		putNetpbmRow(pix[y*stride:(y+1)*stride], row, h, channels)
+	}
+
+	return img, nil
+}
+
+// putNetpbmRow scales the samples of a row and stores them in the row of the pixel buffer with the given number of channels.
+func putNetpbmRow(out []byte, row []int, h netpbmHeader, channels int) {
+	deep := h.maxval > 0xff
+
+	// gray with alpha is expanded to rgb with alpha
+	expand := h.alpha && h.depth == 2
+	bps := 1
+	if deep {
+		bps = 2
+	}
+
+	for x := 0; x < h.width; x++ {
+		px := row[x*h.depth : (x+1)*h.depth]
+		o := out[x*channels*bps : (x+1)*channels*bps]
+
+		for c := 0; c < channels; c++ {
+			s := c
+			switch {
+			case expand && c < 3:
+				s = 0
+			case expand:
+				s = 1
+			case c >= h.depth:
+				s = 0
+			}
+
+			if deep {
+				binary.BigEndian.PutUint16(o[c*2:], scaleSample(px[s], h.maxval, 0xffff))
+			} else {
+				o[c] = uint8(scaleSample(px[s], h.maxval, 0xff))
+			}
+		}
+
+		// opaque color images are stored with an alpha channel
+		if !h.alpha && channels == 4 {
+			if deep {
+				binary.BigEndian.PutUint16(o[6:], 0xffff)
+			} else {
+				o[3] = 0xff
+			}
+		}
+	}
+}
+
+// newNetpbmImage returns an image for the header along with its pixel buffer and number of channels.
+func newNetpbmImage(rect image.Rectangle, h netpbmHeader) (image.Image, []byte, int) {
+	switch h.colorModel() {
+	case color.NRGBA64Model:
+		m := image.NewNRGBA64(rect)
+		return m, m.Pix, 4
+	case color.NRGBAModel:
+		m := image.NewNRGBA(rect)
+		return m, m.Pix, 4
+	case color.Gray16Model:
+		m := image.NewGray16(rect)
+		return m, m.Pix, 1
+	case color.GrayModel:
+		m := image.NewGray(rect)
+		return m, m.Pix, 1
+	case color.RGBA64Model:
+		m := image.NewRGBA64(rect)
+		return m, m.Pix, 4
+	default:
+		m := image.NewRGBA(rect)
+		return m, m.Pix, 4
+	}
+}
+
+func scaleSample(s, maxval, full int) uint16 {
+	if s > maxval {
+		s = maxval
+	}
+
+	return uint16((s*full + maxval/2) / maxval)
+}
+
+// netpbmRowReader reads samples of plain and raw Netpbm images one row at a time.
+type netpbmRowReader struct {
+	r *bufio.Reader
+	h netpbmHeader
+	// buf holds the bytes of a row of raw images.
+	buf []byte
+}
+
+func newNetpbmRowReader(r *bufio.Reader, h netpbmHeader) *netpbmRowReader {
+	rr := &netpbmRowReader{r: r, h: h}
+
+	switch {
+	case h.plain():
+	case h.magic == '4':
+		// rows of bitmaps are padded to whole bytes
+		rr.buf = make([]byte, (h.width+7)/8)
+	case h.maxval > 0xff:
+		rr.buf = make([]byte, h.width*h.depth*2)
+	default:
+		rr.buf = make([]byte, h.width*h.depth)
+	}
+
+	return rr
+}
+
+// read reads the width*depth samples of the next row into row. Bitmaps store black as 1, their samples are inverted.
+func (rr *netpbmRowReader) read(row []int) error {
+	h := rr.h
+
+	switch {
+	case h.magic == '1':
+		// plain bitmap samples don't need to be separated by whitespace
+		for i := 0; i < len(row); {
+			b, err := rr.r.ReadByte()
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case b == '0' || b == '1':
+				row[i] = int('1' - b)
+				i++
+			case b == '#':
+				if _, err := rr.r.ReadString('\n'); err != nil {
+					return err
+				}
+			case !isNetpbmSpace(b):
+				return fmt.Errorf("unexpected %q: %w", b, ErrInvalidNetpbm)
+			}
+		}
+	case h.plain():
+		for i := range row {
+			s, err := readNetpbmInt(rr.r)
+			if err != nil {
+				return err
+			}
+
+			row[i] = s
+		}
+	case h.magic == '4':
+		if _, err := io.ReadFull(rr.r, rr.buf); err != nil {
+			return err
+		}
+
+		for x := range row {
+			row[x] = 1 - int(rr.buf[x/8]>>(7-x%8))&1
+		}
+	default:
+		if _, err := io.ReadFull(rr.r, rr.buf); err != nil {
+			return err
+		}
+
+		for i := range row {
+			if h.maxval > 0xff {
+				row[i] = int(binary.BigEndian.Uint16(rr.buf[i*2:]))
+			} else {
+				row[i] = int(rr.buf[i])
+			}
+		}
+	}
+
+	return nil
+}
+
+// encodePBM encodes the image as a raw bitmap (P4), pixels darker than mid gray are black.
+func encodePBM(w io.Writer, m image.Image) error {
+	return encodeNetpbm(w, m, '4')
+}
+
+// encodePGM encodes the image as a raw grayscale image (P5).
+func encodePGM(w io.Writer, m image.Image) error {
+	return encodeNetpbm(w, m, '5')
+}
+
+// encodePPM encodes the image as a raw color image (P6).
+func encodePPM(w io.Writer, m image.Image) error {
+	return encodeNetpbm(w, m, '6')
+}
+
+// encodePNM encodes grayscale images as PGM and other images as PPM.
+func encodePNM(w io.Writer, m image.Image) error {
+	if isGrayImage(m) {
+		return encodePGM(w, m)
+	}
+
+	return encodePPM(w, m)
+}
+
+// encodePAM encodes the image as a PAM image (P7) with GRAYSCALE tuples for grayscale images,
+// RGB tuples for opaque images and RGB_ALPHA tuples for other images.
+func encodePAM(w io.Writer, m image.Image) error {
+	return encodeNetpbm(w, m, '7')
+}
+
+// encodeNetpbm encodes the image in the raw format of the magic number. Images with 16-bit samples are encoded with maxval 65535,
+// other images with maxval 255. Formats without alpha store the pixels composited over black.
+func encodeNetpbm(w io.Writer, m image.Image, magic byte) error {
+	b := m.Bounds()
+	h := netpbmHeader{magic: magic, width: b.Dx(), height: b.Dy(), depth: 1, maxval: 0xff}
+
+	if is16Bit(m) {
+		h.maxval = 0xffff
+	}
+
+	bw := bufio.NewWriter(w)
+
+	switch magic {
+	case '4':
+		fmt.Fprintf(bw, "P4\n%d %d\n", h.width, h.height)
+		writePBMRows(bw, m)
+
+		return bw.Flush()
+	case '5':
+		fmt.Fprintf(bw, "P5\n%d %d\n%d\n", h.width, h.height, h.maxval)
+	case '6':
+		h.depth = 3
+		fmt.Fprintf(bw, "P6\n%d %d\n%d\n", h.width, h.height, h.maxval)
+	default:
+		tupleType := pamGrayscale
+
+		switch {
+		case isGrayImage(m):
+		case opaque(m):
+			h.depth, tupleType = 3, pamRGB
+		default:
+			h.depth, h.alpha, tupleType = 4, true, pamRGBAlpha
+		}
+
+		fmt.Fprintf(bw, "P7\nWIDTH %d\nHEIGHT %d\nDEPTH %d\nMAXVAL %d\nTUPLTYPE %s\nENDHDR\n", h.width, h.height, h.depth, h.maxval, tupleType)
+	}
+
+	samples := make([]uint16, 0, 4)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			samples = samples[:0]
+
+			switch {
+			case h.depth == 1:
+				samples = append(samples, color.Gray16Model.Convert(m.At(x, y)).(color.Gray16).Y)
+			case h.alpha:
+				c := color.NRGBA64Model.Convert(m.At(x, y)).(color.NRGBA64)
+				samples = append(samples, c.R, c.G, c.B, c.A)
+			default:
+				c := color.RGBA64Model.Convert(m.At(x, y)).(color.RGBA64)
+				samples = append(samples, c.R, c.G, c.B)
+			}
+
+			for _, s := range samples {
+				if h.maxval == 0xff {
+					bw.WriteByte(uint8(s >> 8))
+				} else {
+					bw.Write([]byte{uint8(s >> 8), uint8(s)})
+				}
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writePBMRows(w *bufio.Writer, m image.Image) {
+	b := m.Bounds()
+	row := make([]byte, (b.Dx()+7)/8)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for i := range row {
+			row[i] = 0
+		}
+
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if color.Gray16Model.Convert(m.At(x, y)).(color.Gray16).Y < 0x8000 {
+				i := x - b.Min.X
+				row[i/8] |= 0x80 >> (i % 8)
+			}
+		}
+
+		w.Write(row)
+	}
+}
+
+func isGrayImage(m image.Image) bool {
+	switch m.(type) {
+	case *image.Gray, *image.Gray16:
+		return true
+	default:
+		return false
+	}
+}
+
+func is16Bit(m image.Image) bool {
+	switch m.(type) {
+	case *image.Gray16, *image.Alpha16, *image.RGBA64, *image.NRGBA64:
+		return true
+	default:
+		return false
+	}
+}
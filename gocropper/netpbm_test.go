@@ -0,0 +1,196 @@
+package gocropper
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeNetpbm(t *testing.T) {
+	black, white := color.Gray{0}, color.Gray{0xff}
+
+	tests := []struct {
+		name     string
+		data     string
+		exType   image.Image
+		exPixels [][]color.Color
+		exErr    error
+	}{
+		{
+			name:     "P1 comments without separators",
+			data:     "P1\n# comment\n3 2\n010\n1 1\t0",
+			exType:   &image.Gray{},
+			exPixels: [][]color.Color{{white, black, white}, {black, black, white}},
+		},
+		{
+			name:     "P2 scaled maxval",
+			data:     "P2 3 1 15 0 7 15",
+			exType:   &image.Gray{},
+			exPixels: [][]color.Color{{color.Gray{0}, color.Gray{0x77}, color.Gray{0xff}}},
+		},
+		{
+			name:     "P3",
+			data:     "P3\n2 1\n255\n255 0 0  0 0 128\n",
+			exType:   &image.RGBA{},
+			exPixels: [][]color.Color{{color.RGBA{0xff, 0, 0, 0xff}, color.RGBA{0, 0, 0x80, 0xff}}},
+		},
+		{
+			name:   "P4 padded rows",
+			data:   "P4\n10 2\n\x80\x40\x00\x3f",
+			exType: &image.Gray{},
+			exPixels: [][]color.Color{
+				{black, white, white, white, white, white, white, white, white, black},
+				{white, white, white, white, white, white, white, white, white, white},
+			},
+		},
+		{
+			name:     "P5 16-bit",
+			data:     "P5 2 1 65535\n\x12\x34\xff\xff",
+			exType:   &image.Gray16{},
+			exPixels: [][]color.Color{{color.Gray16{0x1234}, color.Gray16{0xffff}}},
+		},
+		{
+			name:     "P6",
+			data:     "P6 1 1 255\n\x01\x02\x03",
+			exType:   &image.RGBA{},
+			exPixels: [][]color.Color{{color.RGBA{1, 2, 3, 0xff}}},
+		},
+		{
+			name:     "P6 16-bit rows",
+			data:     "P6 1 2 65535\n\x00\x01\x00\x02\x00\x03\xff\xff\x00\x00\x80\x00",
+			exType:   &image.RGBA64{},
+			exPixels: [][]color.Color{{color.RGBA64{1, 2, 3, 0xffff}}, {color.RGBA64{0xffff, 0, 0x8000, 0xffff}}},
+		},
+		{
+			name:     "P7 RGB_ALPHA",
+			data:     "P7\nWIDTH 2\nHEIGHT 1\nDEPTH 4\nMAXVAL 255\n# comment\nTUPLTYPE RGB_ALPHA\nENDHDR\n\x01\x02\x03\x80\xff\xff\xff\x00",
+			exType:   &image.NRGBA{},
+			exPixels: [][]color.Color{{color.NRGBA{1, 2, 3, 0x80}, color.NRGBA{0xff, 0xff, 0xff, 0}}},
+		},
+		{
+			name:     "P7 GRAYSCALE_ALPHA 16-bit",
+			data:     "P7\nWIDTH 1\nHEIGHT 1\nDEPTH 2\nMAXVAL 65535\nTUPLTYPE GRAYSCALE_ALPHA\nENDHDR\n\x80\x00\x40\x00",
+			exType:   &image.NRGBA64{},
+			exPixels: [][]color.Color{{color.NRGBA64{0x8000, 0x8000, 0x8000, 0x4000}}},
+		},
+		{
+			name:     "P7 BLACKANDWHITE",
+			data:     "P7\nWIDTH 2\nHEIGHT 1\nDEPTH 1\nMAXVAL 1\nTUPLTYPE BLACKANDWHITE\nENDHDR\n\x00\x01",
+			exType:   &image.Gray{},
+			exPixels: [][]color.Color{{black, white}},
+		},
+		{
+			name:  "bad magic",
+			data:  "P8 1 1 255\n\x00",
+			exErr: ErrInvalidNetpbm,
+		},
+		{
+			name:  "zero maxval",
+			data:  "P5 1 1 0\n\x00",
+			exErr: ErrInvalidNetpbm,
+		},
+		{
+			name:  "bad depth",
+			data:  "P7\nWIDTH 1\nHEIGHT 1\nDEPTH 5\nMAXVAL 255\nENDHDR\n\x00\x00\x00\x00\x00",
+			exErr: ErrInvalidNetpbm,
+		},
+		{
+			name:  "bad plain sample",
+			data:  "P2 2 1 255 1 x",
+			exErr: ErrInvalidNetpbm,
+		},
+		{
+			name:  "truncated",
+			data:  "P6 2 1 255\n\x00\x00\x00",
+			exErr: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := decodeNetpbm(strings.NewReader(tt.data))
+			assert.ErrorIs(t, err, tt.exErr)
+
+			if tt.exErr != nil {
+				return
+			}
+
+			assert.IsType(t, tt.exType, img)
+
+			for y, row := range tt.exPixels {
+				for x, c := range row {
+					assert.Equal(t, c, img.At(x, y), "pixel %d,%d", x, y)
+				}
+			}
+
+			cfg, err := decodeNetpbmConfig(strings.NewReader(tt.data))
+			assert.NoError(t, err)
+			assert.Equal(t, img.ColorModel(), cfg.ColorModel)
+			assert.Equal(t, img.Bounds().Size(), image.Pt(cfg.Width, cfg.Height))
+		})
+	}
+}
+
+func TestEncodeNetpbm(t *testing.T) {
+	bw := image.NewGray(image.Rect(0, 0, 10, 2))
+	for x := 0; x < 10; x += 3 {
+		bw.SetGray(x, 1, color.Gray{0xff})
+	}
+
+	gray16 := image.NewGray16(image.Rect(0, 0, 3, 2))
+	gray16.SetGray16(1, 1, color.Gray16{0x1234})
+
+	rgb := image.NewRGBA(image.Rect(5, 5, 10, 8))
+	for i := range rgb.Pix {
+		rgb.Pix[i] = uint8(i * 13)
+		if i%4 == 3 {
+			rgb.Pix[i] = 0xff
+		}
+	}
+
+	nrgba64 := image.NewNRGBA64(image.Rect(0, 0, 2, 2))
+	nrgba64.SetNRGBA64(1, 0, color.NRGBA64{0x1234, 0x5678, 0x9abc, 0x8000})
+
+	tests := []struct {
+		name     string
+		img      image.Image
+		encode   func(w io.Writer, m image.Image) error
+		exHeader string
+	}{
+		{"pbm", bw, encodePBM, "P4\n10 2\n"},
+		{"pgm", bw, encodePGM, "P5\n10 2\n255\n"},
+		{"pgm 16-bit", gray16, encodePGM, "P5\n3 2\n65535\n"},
+		{"ppm", rgb, encodePPM, "P6\n5 3\n255\n"},
+		{"pnm gray", bw, encodePNM, "P5\n"},
+		{"pnm color", rgb, encodePNM, "P6\n"},
+		{"pam gray", gray16, encodePAM, "P7\nWIDTH 3\nHEIGHT 2\nDEPTH 1\nMAXVAL 65535\nTUPLTYPE GRAYSCALE\n"},
+		{"pam rgb", rgb, encodePAM, "P7\nWIDTH 5\nHEIGHT 3\nDEPTH 3\nMAXVAL 255\nTUPLTYPE RGB\n"},
+		{"pam rgb alpha", nrgba64, encodePAM, "P7\nWIDTH 2\nHEIGHT 2\nDEPTH 4\nMAXVAL 65535\nTUPLTYPE RGB_ALPHA\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			assert.NoError(t, tt.encode(buf, tt.img))
+			assert.True(t, strings.HasPrefix(buf.String(), tt.exHeader), buf.String())
+
+			decoded, err := decodeNetpbm(buf)
+			assert.NoError(t, err)
+
+			b := tt.img.Bounds()
+			for y := 0; y < b.Dy(); y++ {
+				for x := 0; x < b.Dx(); x++ {
+					ex := color.NRGBA64Model.Convert(tt.img.At(b.Min.X+x, b.Min.Y+y))
+					if !assert.Equal(t, ex, color.NRGBA64Model.Convert(decoded.At(x, y)), "pixel %d,%d", x, y) {
+						return
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,381 @@
+package gocropper
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrUnknownPackAlgorithm = errors.New("unknown pack algorithm")
+	ErrSpriteTooLarge       = errors.New("sprite does not fit in the max atlas size")
+	ErrDuplicateSprite      = errors.New("duplicate sprite name")
+)
+
+// PackAlgorithm selects the way sprites are placed in atlas pages.
+type PackAlgorithm int
+
+const (
+	// PackMaxRects places every sprite in the free rectangle it fits best, it usually packs the tightest.
+	PackMaxRects PackAlgorithm = iota
+	// PackSkyline places every sprite as low as possible on the top edge of the placed sprites, it is faster than PackMaxRects.
+	PackSkyline
+)
+
+var packAlgorithmNames = map[PackAlgorithm]string{
+	PackMaxRects: "maxrects",
+	PackSkyline:  "skyline",
+}
+
+// ParsePackAlgorithm returns the PackAlgorithm with the given name: maxrects or skyline.
+func ParsePackAlgorithm(name string) (PackAlgorithm, error) {
+	for a, n := range packAlgorithmNames {
+		if strings.EqualFold(n, name) {
+			return a, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%s: %w", name, ErrUnknownPackAlgorithm)
+}
+
+func (a PackAlgorithm) String() string {
+	if name, ok := packAlgorithmNames[a]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("PackAlgorithm(%d)", int(a))
+}
+
+// Packer trims images with a cropper and packs them into texture atlases, see Pack and Save.
+type Packer struct {
+	cropper    *Cropper
+	algorithm  PackAlgorithm
+	rotation   bool
+	powerOfTwo bool
+	maxSize    image.Point
+	padding    int
+	format     AtlasFormat
+}
+
+// NewPacker creates a *Packer trimming images using the given cropper, returns error if any option fails.
+//
+// Default Packer with no options packs sprites with PackMaxRects into pages of at most 2048x2048 pixels without rotating them
+// and saves TexturePacker JSON hash data.
+func NewPacker(cropper *Cropper, options ...PackerOption) (*Packer, error) {
+	if cropper == nil {
+		return nil, errors.New("cropper cannot be nil")
+	}
+
+	p := &Packer{cropper: cropper, maxSize: image.Pt(2048, 2048)}
+
+	for _, opt := range options {
+		if err := opt(p); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// AtlasPage is a packed atlas image along with the sprites placed in it.
+type AtlasPage struct {
+	Image   *image.NRGBA
+	Sprites []AtlasSprite
+}
+
+// AtlasSprite is a trimmed image placed in an atlas page.
+type AtlasSprite struct {
+	// Name is the path of the source image relative to the common directory of all packed images, with forward slashes,
+	// e.g. the file name if all images are in the same directory.
+	Name string
+	// Frame is the rectangle occupied by the sprite in the page, its width and height are swapped if the sprite is rotated.
+	Frame image.Rectangle
+	// Rotated is true if the sprite is rotated by 90 degrees clockwise in the page.
+	Rotated bool
+	// Source is the rectangle of the trimmed sprite in the source image, relative to its bounds. It includes the padding
+	// of the cropper and may reach beyond the source image.
+	Source image.Rectangle
+	// SourceSize is the size of the source image.
+	SourceSize image.Point
+}
+
+// Trimmed reports whether the sprite is smaller than its source image.
+func (s AtlasSprite) Trimmed() bool {
+	return !s.Source.Eq(image.Rectangle{Max: s.SourceSize})
+}
+
+// packItem is a trimmed sprite waiting to be placed.
+type packItem struct {
+	sprite AtlasSprite
+	img    image.Image
+}
+
+// Pack trims the loaded croppables with the cropper and packs them into as many pages as needed, see AtlasPage.
+// Only the image of a croppable is packed, frames of animations are ignored. Sprites are named after the paths of
+// the croppables, see AtlasSprite.Name. Returns ErrDuplicateSprite if two croppables have the same path
+// and ErrSpriteTooLarge if a sprite doesn't fit in the max atlas size.
+func (p *Packer) Pack(croppables []*Croppable) ([]*AtlasPage, error) {
+	names, err := spriteNames(croppables)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]packItem, len(croppables))
+
+	for n, c := range croppables {
+		b := c.Image.Bounds()
+		m := p.cropper.matcher(c.Image)
+		rect := p.cropper.rect(c.Image, m)
+		trimmed, _ := p.cropper.cropRect(c.Image, rect, m)
+		tb := trimmed.Bounds()
+
+		items[n] = packItem{
+			sprite: AtlasSprite{
+				Name:       names[n],
+				Source:     tb.Sub(b.Min),
+				SourceSize: b.Size(),
+			},
+			img: trimmed,
+		}
+
+		if !p.fits(tb.Dx(), tb.Dy()) {
+			return nil, fmt.Errorf("%s %dx%d: %w", items[n].sprite.Name, tb.Dx(), tb.Dy(), ErrSpriteTooLarge)
+		}
+	}
+
+	// larger sprites first, they are the hardest to place
+	sort.SliceStable(items, func(a, b int) bool {
+		sa, sb := items[a].img.Bounds().Size(), items[b].img.Bounds().Size()
+		if la, lb := maxInt(sa.X, sa.Y), maxInt(sb.X, sb.Y); la != lb {
+			return la > lb
+		}
+
+		return sa.X*sa.Y > sb.X*sb.Y
+	})
+
+	var pages []*AtlasPage
+
+	for len(items) > 0 {
+		page, rest := p.packPage(items)
+		pages = append(pages, page)
+		items = rest
+	}
+
+	return pages, nil
+}
+
+// spriteNames returns the paths of the croppables relative to their common directory, with forward slashes.
+// Returns ErrDuplicateSprite if two names are the same.
+func spriteNames(croppables []*Croppable) ([]string, error) {
+	elems := make([][]string, len(croppables))
+
+	var common []string
+
+	for n, c := range croppables {
+		elems[n] = strings.Split(filepath.ToSlash(filepath.Clean(c.Path)), "/")
+		dir := elems[n][:len(elems[n])-1]
+
+		if n == 0 {
+			common = dir
+			continue
+		}
+
+		k := 0
+		for k < len(common) && k < len(dir) && common[k] == dir[k] {
+			k++
+		}
+
+		common = common[:k]
+	}
+
+	names := make([]string, len(croppables))
+	seen := map[string]bool{}
+
+	for n, e := range elems {
+		names[n] = strings.Join(e[len(common):], "/")
+		if seen[names[n]] {
+			return nil, fmt.Errorf("%s: %w", names[n], ErrDuplicateSprite)
+		}
+
+		seen[names[n]] = true
+	}
+
+	return names, nil
+}
+
+// area returns the size of the area sprites are packed in, the max size rounded down to a power of two if required.
+func (p *Packer) area() image.Point {
+	size := p.maxSize
+	if p.powerOfTwo {
+		size = image.Pt(floorPowerOfTwo(size.X), floorPowerOfTwo(size.Y))
+	}
+
+	return size
+}
+
+// fits reports whether a sprite of size w x h fits in an empty page.
+func (p *Packer) fits(w, h int) bool {
+	area := p.area()
+	fit := func(w, h int) bool { return w <= area.X && h <= area.Y }
+
+	return fit(w, h) || (p.rotation && fit(h, w))
+}
+
+// packPage places as many items as fit in a single page and returns the page along with the items left over.
+func (p *Packer) packPage(items []packItem) (*AtlasPage, []packItem) {
+	area := p.area()
+
+	// the padding after the last sprite of a row or column may reach beyond the page
+	var b bin = newMaxRectsBin(area.X+p.padding, area.Y+p.padding)
+	if p.algorithm == PackSkyline {
+		b = newSkylineBin(area.X+p.padding, area.Y+p.padding)
+	}
+
+	var (
+		placed, rest []packItem
+		used         image.Rectangle
+	)
+
+	for _, it := range items {
+		size := it.img.Bounds().Size()
+
+		r, rotated, ok := b.insert(size.X+p.padding, size.Y+p.padding, p.rotation)
+		if !ok {
+			rest = append(rest, it)
+			continue
+		}
+
+		frame := image.Rectangle{r.Min, r.Min.Add(size)}
+		if rotated {
+			frame.Max = r.Min.Add(image.Pt(size.Y, size.X))
+		}
+
+		it.sprite.Frame, it.sprite.Rotated = frame, rotated
+		used = used.Union(frame)
+		placed = append(placed, it)
+	}
+
+	size := used.Max
+	if p.powerOfTwo {
+		size = image.Pt(ceilPowerOfTwo(size.X), ceilPowerOfTwo(size.Y))
+	}
+
+	page := &AtlasPage{Image: image.NewNRGBA(image.Rectangle{Max: size})}
+
+	for _, it := range placed {
+		drawSprite(page.Image, it.sprite.Frame, it.img, it.sprite.Rotated)
+		page.Sprites = append(page.Sprites, it.sprite)
+	}
+
+	return page, rest
+}
+
+// drawSprite draws the image in the frame, rotated by 90 degrees clockwise if rotated is true.
+func drawSprite(dst *image.NRGBA, frame image.Rectangle, img image.Image, rotated bool) {
+	b := img.Bounds()
+
+	if !rotated {
+		draw.Draw(dst, frame, img, b.Min, draw.Src)
+		return
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(frame.Min.X+b.Max.Y-1-y, frame.Min.Y+x-b.Min.X, img.At(x, y))
+		}
+	}
+}
+
+func floorPowerOfTwo(n int) int {
+	p := 1
+	for p*2 <= n {
+		p *= 2
+	}
+
+	return p
+}
+
+func ceilPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+
+	return p
+}
+
+type PackerOption func(*Packer) error
+
+// WithPackAlgorithm sets the PackAlgorithm placing sprites in pages, PackMaxRects by default.
+func WithPackAlgorithm(algorithm PackAlgorithm) PackerOption {
+	return func(p *Packer) error {
+		if _, ok := packAlgorithmNames[algorithm]; !ok {
+			return fmt.Errorf("%s: %w", algorithm, ErrUnknownPackAlgorithm)
+		}
+
+		p.algorithm = algorithm
+
+		return nil
+	}
+}
+
+// WithRotation allows rotating sprites by 90 degrees clockwise when they fit better, see AtlasSprite.Rotated.
+func WithRotation(rotation bool) PackerOption {
+	return func(p *Packer) error {
+		p.rotation = rotation
+		return nil
+	}
+}
+
+// WithPowerOfTwo makes the width and height of pages powers of two, as required by some GPUs and engines.
+// The max size is rounded down to a power of two.
+func WithPowerOfTwo(powerOfTwo bool) PackerOption {
+	return func(p *Packer) error {
+		p.powerOfTwo = powerOfTwo
+		return nil
+	}
+}
+
+// WithMaxSize sets the maximum width and height of pages, sprites which don't fit in a page are placed in the next one.
+func WithMaxSize(width, height int) PackerOption {
+	return func(p *Packer) error {
+		if width <= 0 || height <= 0 {
+			return errors.New("max size must be positive")
+		}
+
+		p.maxSize = image.Pt(width, height)
+
+		return nil
+	}
+}
+
+// WithSpritePadding sets the number of transparent pixels between sprites in pages, it prevents colors of neighbouring sprites
+// from bleeding into each other when the atlas is filtered.
+func WithSpritePadding(padding int) PackerOption {
+	return func(p *Packer) error {
+		if padding < 0 {
+			return errors.New("sprite padding cannot be negative")
+		}
+
+		p.padding = padding
+
+		return nil
+	}
+}
+
+// WithAtlasFormat sets the AtlasFormat of the data saved along with atlas pages, AtlasJSONHash by default.
+func WithAtlasFormat(format AtlasFormat) PackerOption {
+	return func(p *Packer) error {
+		if _, ok := atlasFormatNames[format]; !ok {
+			return fmt.Errorf("%s: %w", format, ErrUnknownAtlasFormat)
+		}
+
+		p.format = format
+
+		return nil
+	}
+}
@@ -0,0 +1,347 @@
+package gocropper_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/H3Cki/gocrop/gocropper"
+	"github.com/stretchr/testify/assert"
+)
+
+// sprite returns a croppable with a transparent image of size w x h with the content rectangle filled with a color unique to n.
+func sprite(n, w, h int, content image.Rectangle) *gocropper.Croppable {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+
+	for y := content.Min.Y; y < content.Max.Y; y++ {
+		for x := content.Min.X; x < content.Max.X; x++ {
+			img.SetNRGBA(x, y, spriteColor(n))
+		}
+	}
+
+	return &gocropper.Croppable{Path: fmt.Sprintf("sprites/sprite%d.png", n), Image: img}
+}
+
+func spriteColor(n int) color.NRGBA {
+	return color.NRGBA{uint8(n * 40), uint8(255 - n*20), uint8(n), 255}
+}
+
+func sprites() []*gocropper.Croppable {
+	return []*gocropper.Croppable{
+		sprite(0, 40, 40, image.Rect(5, 10, 35, 20)),
+		sprite(1, 20, 40, image.Rect(0, 0, 20, 40)),
+		sprite(2, 16, 16, image.Rect(4, 4, 12, 12)),
+		sprite(3, 50, 10, image.Rect(0, 2, 50, 8)),
+		sprite(4, 30, 30, image.Rect(1, 1, 29, 29)),
+		sprite(5, 8, 24, image.Rect(2, 0, 6, 24)),
+	}
+}
+
+func TestPacker_Pack(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []gocropper.PackerOption
+		pages     int
+		maxSize   image.Point
+		pot       bool
+		padding   int
+		wantError error
+	}{
+		{
+			name:    "maxrects",
+			opts:    []gocropper.PackerOption{gocropper.WithMaxSize(64, 64)},
+			pages:   1,
+			maxSize: image.Pt(64, 64),
+		},
+		{
+			name:    "skyline",
+			opts:    []gocropper.PackerOption{gocropper.WithPackAlgorithm(gocropper.PackSkyline), gocropper.WithMaxSize(64, 64)},
+			pages:   1,
+			maxSize: image.Pt(64, 64),
+		},
+		{
+			name:    "maxrects with rotation and padding",
+			opts:    []gocropper.PackerOption{gocropper.WithRotation(true), gocropper.WithSpritePadding(2), gocropper.WithMaxSize(64, 64)},
+			pages:   1,
+			maxSize: image.Pt(64, 64),
+			padding: 2,
+		},
+		{
+			name:    "skyline with rotation and padding",
+			opts:    []gocropper.PackerOption{gocropper.WithPackAlgorithm(gocropper.PackSkyline), gocropper.WithRotation(true), gocropper.WithSpritePadding(1), gocropper.WithMaxSize(64, 64)},
+			pages:   1,
+			maxSize: image.Pt(64, 64),
+			padding: 1,
+		},
+		{
+			name:    "multiple pages",
+			opts:    []gocropper.PackerOption{gocropper.WithMaxSize(50, 40)},
+			pages:   2,
+			maxSize: image.Pt(50, 40),
+		},
+		{
+			name:    "power of two",
+			opts:    []gocropper.PackerOption{gocropper.WithPowerOfTwo(true), gocropper.WithMaxSize(100, 70)},
+			maxSize: image.Pt(64, 64),
+			pages:   1,
+			pot:     true,
+		},
+		{
+			name:      "too large",
+			opts:      []gocropper.PackerOption{gocropper.WithMaxSize(40, 40)},
+			wantError: gocropper.ErrSpriteTooLarge,
+		},
+		{
+			name:    "rotated to fit",
+			opts:    []gocropper.PackerOption{gocropper.WithRotation(true), gocropper.WithMaxSize(40, 50)},
+			maxSize: image.Pt(40, 50),
+			pages:   2,
+		},
+	}
+
+	cropper, err := gocropper.NewCropper()
+	assert.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packer, err := gocropper.NewPacker(cropper, tt.opts...)
+			assert.NoError(t, err)
+
+			croppables := sprites()
+
+			pages, err := packer.Pack(croppables)
+			if tt.wantError != nil {
+				assert.ErrorIs(t, err, tt.wantError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Len(t, pages, tt.pages)
+
+			packed := map[string]gocropper.AtlasSprite{}
+
+			for _, page := range pages {
+				size := page.Image.Bounds().Size()
+				assert.LessOrEqual(t, size.X, tt.maxSize.X)
+				assert.LessOrEqual(t, size.Y, tt.maxSize.Y)
+
+				if tt.pot {
+					assert.Zero(t, size.X&(size.X-1))
+					assert.Zero(t, size.Y&(size.Y-1))
+				}
+
+				for n, s := range page.Sprites {
+					assert.True(t, s.Frame.In(page.Image.Bounds()))
+
+					// sprites don't overlap, including padding
+					for _, o := range page.Sprites[n+1:] {
+						assert.False(t, s.Frame.Inset(-tt.padding).Overlaps(o.Frame), "%s overlaps %s", s.Name, o.Name)
+					}
+
+					packed[s.Name] = s
+				}
+			}
+
+			assert.Len(t, packed, len(croppables))
+
+			for n, c := range croppables {
+				s := packed[fmt.Sprintf("sprite%d.png", n)]
+				content := cropper.Rect(c.Image)
+
+				assert.Equal(t, content, s.Source)
+				assert.Equal(t, c.Image.Bounds().Size(), s.SourceSize)
+
+				size := content.Size()
+				if s.Rotated {
+					size = image.Pt(size.Y, size.X)
+				}
+
+				assert.Equal(t, size, s.Frame.Size())
+
+				// every pixel of the frame has the color of the sprite
+				page := pages[0]
+				for _, p := range pages {
+					for _, ps := range p.Sprites {
+						if ps.Name == s.Name {
+							page = p
+						}
+					}
+				}
+
+				for y := s.Frame.Min.Y; y < s.Frame.Max.Y; y++ {
+					for x := s.Frame.Min.X; x < s.Frame.Max.X; x++ {
+						assert.Equal(t, spriteColor(n), page.Image.NRGBAAt(x, y))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPacker_PackRotation(t *testing.T) {
+	cropper, err := gocropper.NewCropper()
+	assert.NoError(t, err)
+
+	packer, err := gocropper.NewPacker(cropper, gocropper.WithRotation(true), gocropper.WithMaxSize(4, 8))
+	assert.NoError(t, err)
+
+	// 8x4 sprite with a marked top left corner has to be rotated clockwise, the corner ends up at the top right
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{0, 0, 255, 255})
+		}
+	}
+
+	img.SetNRGBA(0, 0, color.NRGBA{255, 0, 0, 255})
+
+	pages, err := packer.Pack([]*gocropper.Croppable{{Path: "bar.png", Image: img}})
+	assert.NoError(t, err)
+	assert.Len(t, pages, 1)
+	assert.True(t, pages[0].Sprites[0].Rotated)
+	assert.Equal(t, image.Rect(0, 0, 4, 8), pages[0].Image.Bounds())
+	assert.Equal(t, color.NRGBA{255, 0, 0, 255}, pages[0].Image.NRGBAAt(3, 0))
+	assert.Equal(t, color.NRGBA{0, 0, 255, 255}, pages[0].Image.NRGBAAt(0, 0))
+}
+
+func TestPacker_PackNames(t *testing.T) {
+	cropper, err := gocropper.NewCropper()
+	assert.NoError(t, err)
+
+	packer, err := gocropper.NewPacker(cropper)
+	assert.NoError(t, err)
+
+	names := func(paths ...string) ([]string, error) {
+		croppables := []*gocropper.Croppable{}
+		for n, p := range paths {
+			c := sprite(n, 4, 4, image.Rect(0, 0, 4, 4))
+			c.Path = p
+			croppables = append(croppables, c)
+		}
+
+		pages, err := packer.Pack(croppables)
+		if err != nil {
+			return nil, err
+		}
+
+		out := []string{}
+		for _, s := range pages[0].Sprites {
+			out = append(out, s.Name)
+		}
+
+		return out, nil
+	}
+
+	// sprites are named relative to the common directory of all images
+	got, err := names("assets/hero/walk.png", "assets/enemy/walk.png", "assets/hero/idle/0.png")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"hero/walk.png", "enemy/walk.png", "hero/idle/0.png"}, got)
+
+	got, err = names("walk.png", "idle.png")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"walk.png", "idle.png"}, got)
+
+	_, err = names("assets/walk.png", "assets/./walk.png")
+	assert.ErrorIs(t, err, gocropper.ErrDuplicateSprite)
+}
+
+func TestPacker_Save(t *testing.T) {
+	cropper, err := gocropper.NewCropper()
+	assert.NoError(t, err)
+
+	tests := []struct {
+		format gocropper.AtlasFormat
+		files  []string
+	}{
+		{format: gocropper.AtlasJSONHash, files: []string{"atlas_0.png", "atlas_0.json", "atlas_1.png", "atlas_1.json"}},
+		{format: gocropper.AtlasJSONArray, files: []string{"atlas_0.png", "atlas_0.json", "atlas_1.png", "atlas_1.json"}},
+		{format: gocropper.AtlasCSV, files: []string{"atlas_0.png", "atlas_1.png", "atlas.csv"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format.String(), func(t *testing.T) {
+			dir := path.Join(t.TempDir(), "out")
+
+			packer, err := gocropper.NewPacker(cropper, gocropper.WithAtlasFormat(tt.format), gocropper.WithMaxSize(50, 40))
+			assert.NoError(t, err)
+
+			pages, err := packer.Pack(sprites())
+			assert.NoError(t, err)
+			assert.NoError(t, packer.Save(pages, path.Join(dir, "atlas.png")))
+
+			for _, fn := range tt.files {
+				assert.FileExists(t, path.Join(dir, fn))
+			}
+
+			// the first page holds the largest sprite, sprite3.png trimmed to 50x6 at 0,2 of a 50x10 image
+			want := map[string]any{
+				"frame":            map[string]any{"x": 0.0, "y": 0.0, "w": 50.0, "h": 6.0},
+				"rotated":          false,
+				"trimmed":          true,
+				"spriteSourceSize": map[string]any{"x": 0.0, "y": 2.0, "w": 50.0, "h": 6.0},
+				"sourceSize":       map[string]any{"w": 50.0, "h": 10.0},
+			}
+
+			switch tt.format {
+			case gocropper.AtlasJSONHash:
+				data := struct {
+					Frames map[string]map[string]any `json:"frames"`
+					Meta   map[string]any            `json:"meta"`
+				}{}
+
+				raw, err := os.ReadFile(path.Join(dir, "atlas_0.json"))
+				assert.NoError(t, err)
+				assert.NoError(t, json.Unmarshal(raw, &data))
+				assert.Equal(t, want, data.Frames["sprite3.png"])
+				assert.Equal(t, "atlas_0.png", data.Meta["image"])
+			case gocropper.AtlasJSONArray:
+				data := struct {
+					Frames []map[string]any `json:"frames"`
+				}{}
+
+				raw, err := os.ReadFile(path.Join(dir, "atlas_0.json"))
+				assert.NoError(t, err)
+				assert.NoError(t, json.Unmarshal(raw, &data))
+
+				want["filename"] = "sprite3.png"
+				assert.Contains(t, data.Frames, want)
+			case gocropper.AtlasCSV:
+				fd, err := os.Open(path.Join(dir, "atlas.csv"))
+				assert.NoError(t, err)
+
+				defer fd.Close()
+
+				rows, err := csv.NewReader(fd).ReadAll()
+				assert.NoError(t, err)
+				assert.Len(t, rows, 7)
+				assert.Equal(t, []string{"name", "image", "x", "y", "w", "h", "rotated", "offset_x", "offset_y", "source_w", "source_h"}, rows[0])
+				assert.Contains(t, rows, []string{"sprite3.png", "atlas_0.png", "0", "0", "50", "6", "false", "0", "2", "50", "10"})
+			}
+		})
+	}
+}
+
+func TestNewPacker(t *testing.T) {
+	cropper, err := gocropper.NewCropper()
+	assert.NoError(t, err)
+
+	_, err = gocropper.NewPacker(nil)
+	assert.Error(t, err)
+
+	_, err = gocropper.NewPacker(cropper, gocropper.WithMaxSize(0, 10))
+	assert.Error(t, err)
+
+	_, err = gocropper.NewPacker(cropper, gocropper.WithSpritePadding(-1))
+	assert.Error(t, err)
+
+	_, err = gocropper.ParsePackAlgorithm("guillotine")
+	assert.ErrorIs(t, err, gocropper.ErrUnknownPackAlgorithm)
+
+	_, err = gocropper.ParseAtlasFormat("xml")
+	assert.ErrorIs(t, err, gocropper.ErrUnknownAtlasFormat)
+}
@@ -0,0 +1,260 @@
+package gocropper
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"sort"
+	"strings"
+)
+
+var ErrUnknownDither = errors.New("unknown dither")
+
+// Dither selects the way colors missing from the palette are drawn when an image is converted to a paletted image,
+// e.g. when a padded or truecolor image is saved as GIF.
+type Dither int
+
+const (
+	// DitherFloydSteinberg diffuses the error of every pixel to its neighbours.
+	DitherFloydSteinberg Dither = iota
+	// DitherNone replaces every color with the nearest color of the palette.
+	DitherNone
+)
+
+var ditherNames = map[Dither]string{
+	DitherFloydSteinberg: "floyd-steinberg",
+	DitherNone:           "none",
+}
+
+// ParseDither returns the Dither with the given name: floyd-steinberg or none.
+func ParseDither(name string) (Dither, error) {
+	for d, n := range ditherNames {
+		if strings.EqualFold(n, name) {
+			return d, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%s: %w", name, ErrUnknownDither)
+}
+
+func (d Dither) String() string {
+	if name, ok := ditherNames[d]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Dither(%d)", int(d))
+}
+
+func (d Dither) drawer() draw.Drawer {
+	if d == DitherNone {
+		return draw.Src
+	}
+
+	return draw.FloydSteinberg
+}
+
+// gifEncoder returns a GIF encode function. Paletted images are encoded with their own palette,
+// other images are converted to a palette of at most colors colors built by median cut.
+func gifEncoder(colors int, dither Dither) func(w io.Writer, m image.Image) error {
+	return func(w io.Writer, m image.Image) error {
+		return gif.Encode(w, m, &gif.Options{
+			NumColors: colors,
+			Quantizer: MedianCut{},
+			Drawer:    dither.drawer(),
+		})
+	}
+}
+
+// MedianCut is a draw.Quantizer building a palette by recursively splitting the box of image colors
+// along its longest axis at the median. Images with transparent pixels get a fully transparent color,
+// remaining pixels are treated as opaque, like in GIF images.
+type MedianCut struct{}
+
+// Quantize appends up to cap(p)-len(p) colors to p.
+func (MedianCut) Quantize(p color.Palette, m image.Image) color.Palette {
+	n := cap(p) - len(p)
+	if n <= 0 {
+		return p
+	}
+
+	hist := map[color.NRGBA]int{}
+	transparent := false
+	b := m.Bounds()
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+			if c.A < 0x80 {
+				transparent = true
+				continue
+			}
+
+			c.A = 0xff
+			hist[c]++
+		}
+	}
+
+	if transparent {
+		p = append(p, color.Transparent)
+		n--
+	}
+
+	colors := make([]weightedColor, 0, len(hist))
+	for c, count := range hist {
+		colors = append(colors, weightedColor{c, count})
+	}
+
+	// sorted for a deterministic palette
+	sort.Slice(colors, func(i, j int) bool { return colors[i].key() < colors[j].key() })
+
+	if len(colors) <= n {
+		for _, wc := range colors {
+			p = append(p, wc.c)
+		}
+
+		return p
+	}
+
+	boxes := []colorBox{newColorBox(colors)}
+
+	for len(boxes) < n {
+		split := -1
+
+		for idx, box := range boxes {
+			if box.spread > 0 && (split == -1 || box.score() > boxes[split].score()) {
+				split = idx
+			}
+		}
+
+		if split == -1 {
+			break
+		}
+
+		b1, b2 := boxes[split].split()
+		boxes[split] = b1
+		boxes = append(boxes, b2)
+	}
+
+	for _, box := range boxes {
+		p = append(p, box.average())
+	}
+
+	return p
+}
+
+type weightedColor struct {
+	c     color.NRGBA
+	count int
+}
+
+func (wc weightedColor) key() uint32 {
+	return uint32(wc.c.R)<<16 | uint32(wc.c.G)<<8 | uint32(wc.c.B)
+}
+
+func (wc weightedColor) channel(axis int) uint8 {
+	switch axis {
+	case 0:
+		return wc.c.R
+	case 1:
+		return wc.c.G
+	default:
+		return wc.c.B
+	}
+}
+
+// colorBox is a set of colors along with the channel of the largest spread.
+type colorBox struct {
+	colors []weightedColor
+	count  int
+	axis   int
+	spread int
+}
+
+func newColorBox(colors []weightedColor) colorBox {
+	box := colorBox{colors: colors}
+
+	for axis := 0; axis < 3; axis++ {
+		lo, hi := 255, 0
+
+		for _, wc := range colors {
+			v := int(wc.channel(axis))
+			if v < lo {
+				lo = v
+			}
+
+			if v > hi {
+				hi = v
+			}
+		}
+
+		if hi-lo > box.spread {
+			box.axis, box.spread = axis, hi-lo
+		}
+	}
+
+	for _, wc := range colors {
+		box.count += wc.count
+	}
+
+	return box
+}
+
+// score prefers splitting boxes with many pixels and a wide range of colors.
+func (b colorBox) score() int {
+	return b.spread * b.count
+}
+
+// split divides the box at the weighted median of its widest channel, both halves are non-empty.
+func (b colorBox) split() (colorBox, colorBox) {
+	sort.SliceStable(b.colors, func(i, j int) bool { return b.colors[i].channel(b.axis) < b.colors[j].channel(b.axis) })
+
+	half, sum, at := b.count/2, 0, 1
+
+	for idx, wc := range b.colors[:len(b.colors)-1] {
+		sum += wc.count
+		at = idx + 1
+
+		if sum >= half {
+			break
+		}
+	}
+
+	return newColorBox(b.colors[:at]), newColorBox(b.colors[at:])
+}
+
+func (b colorBox) average() color.Color {
+	var r, g, bl int
+
+	for _, wc := range b.colors {
+		r += int(wc.c.R) * wc.count
+		g += int(wc.c.G) * wc.count
+		bl += int(wc.c.B) * wc.count
+	}
+
+	return color.NRGBA{uint8(r / b.count), uint8(g / b.count), uint8(bl / b.count), 0xff}
+}
+
+// paletteIndex returns the index of the color in the palette, it is appended to a copy of the palette if it is missing.
+// Returns false if the color is missing and the palette is full.
+func paletteIndex(p color.Palette, c color.Color) (color.Palette, uint8, bool) {
+	r1, g1, b1, a1 := c.RGBA()
+
+	for idx, pc := range p {
+		r2, g2, b2, a2 := pc.RGBA()
+
+		// all fully transparent colors are equal
+		if (a1 == 0 && a2 == 0) || (r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2) {
+			return p, uint8(idx), true
+		}
+	}
+
+	if len(p) < 256 {
+		return append(append(color.Palette{}, p...), c), uint8(len(p)), true
+	}
+
+	return p, 0, false
+}
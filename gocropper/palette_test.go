@@ -0,0 +1,135 @@
+package gocropper
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDither(t *testing.T) {
+	tests := []struct {
+		name     string
+		exDither Dither
+		exErr    error
+	}{
+		{"floyd-steinberg", DitherFloydSteinberg, nil},
+		{"None", DitherNone, nil},
+		{"ordered", 0, ErrUnknownDither},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ParseDither(tt.name)
+			assert.ErrorIs(t, err, tt.exErr)
+			assert.Equal(t, tt.exDither, d)
+		})
+	}
+}
+
+func TestMedianCut_Quantize(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 4))
+
+	// 4 rows of 64 shades of red, green, blue and gray
+	for x := 0; x < 64; x++ {
+		v := uint8(x * 4)
+		img.SetNRGBA(x, 0, color.NRGBA{v, 0, 0, 0xff})
+		img.SetNRGBA(x, 1, color.NRGBA{0, v, 0, 0xff})
+		img.SetNRGBA(x, 2, color.NRGBA{0, 0, v, 0xff})
+		img.SetNRGBA(x, 3, color.NRGBA{v, v, v, 0xff})
+	}
+
+	tests := []struct {
+		name     string
+		colors   int
+		exColors int
+	}{
+		{"all colors fit", 256, 253},
+		{"reduced", 16, 16},
+		{"two colors", 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := MedianCut{}.Quantize(make(color.Palette, 0, tt.colors), img)
+			assert.Len(t, p, tt.exColors)
+
+			for _, c := range p {
+				_, _, _, a := c.RGBA()
+				assert.Equal(t, uint32(0xffff), a)
+			}
+		})
+	}
+
+	// transparent pixels reserve a transparent color
+	img.SetNRGBA(0, 0, color.NRGBA{0xff, 0xff, 0xff, 0x10})
+
+	p := MedianCut{}.Quantize(make(color.Palette, 0, 8), img)
+	assert.Len(t, p, 8)
+	assert.Equal(t, color.Transparent, p[0])
+}
+
+func TestCropper_CropPalettedPadding(t *testing.T) {
+	palette := color.Palette{color.Black, color.RGBA{0xff, 0, 0, 0xff}}
+
+	img := image.NewPaletted(image.Rect(0, 0, 10, 10), palette)
+	img.SetColorIndex(0, 0, 1)
+
+	tests := []struct {
+		name      string
+		options   []CropperOption
+		exBounds  image.Rectangle
+		exPalette color.Palette
+		exPadding color.Color
+	}{
+		{
+			name:      "transparent padding",
+			options:   []CropperOption{WithPadding(2)},
+			exBounds:  image.Rect(-2, -2, 12, 12),
+			exPalette: append(palette, color.Transparent),
+			exPadding: color.Transparent,
+		},
+		{
+			name:      "background padding",
+			options:   []CropperOption{WithPadding(2), WithBackgroundColor(color.Black, 0)},
+			exBounds:  image.Rect(-2, -2, 3, 3),
+			exPalette: palette,
+			exPadding: color.Black,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cropper, err := NewCropper(tt.options...)
+			assert.NoError(t, err)
+
+			croppable := &Croppable{Image: img, Format: "gif"}
+			cropped, ok := cropper.Crop(croppable)
+			assert.True(t, ok)
+
+			p, isPaletted := cropped.Image.(*image.Paletted)
+			if !assert.True(t, isPaletted) {
+				return
+			}
+
+			assert.Equal(t, tt.exBounds, p.Bounds())
+			assert.Equal(t, tt.exPalette, p.Palette)
+			assert.Equal(t, palette[1], p.At(0, 0))
+			assert.Equal(t, color.RGBA64Model.Convert(tt.exPadding), color.RGBA64Model.Convert(p.At(-2, -2)))
+
+			// the palette survives encoding, the color table of GIF images is padded to a power of two
+			buf := &bytes.Buffer{}
+			assert.NoError(t, cropper.encoder(cropped)(buf, cropped.Image))
+
+			decoded, err := gif.Decode(buf)
+			assert.NoError(t, err)
+
+			for idx, c := range tt.exPalette {
+				assert.Equal(t, color.RGBA64Model.Convert(c), color.RGBA64Model.Convert(decoded.(*image.Paletted).Palette[idx]))
+			}
+		})
+	}
+}
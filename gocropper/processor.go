@@ -0,0 +1,300 @@
+package gocropper
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// Processor loads, crops and saves croppables concurrently, using a bounded number of goroutines
+// so that only a limited number of images is held in memory at once.
+type Processor struct {
+	cropper   *Cropper
+	jobs      int
+	maxMemory int64
+	maxPixels int64
+}
+
+// NewProcessor creates a *Processor that crops and saves images using the given cropper,
+// returns error if any option fails.
+//
+// Default Processor with no options processes runtime.NumCPU() images at once and has no memory or pixel limits.
+func NewProcessor(cropper *Cropper, options ...ProcessorOption) (*Processor, error) {
+	if cropper == nil {
+		return nil, errors.New("cropper cannot be nil")
+	}
+
+	p := &Processor{
+		cropper: cropper,
+		jobs:    runtime.NumCPU(),
+	}
+
+	for _, opt := range options {
+		if err := opt(p); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// Result is the outcome of processing a single croppable.
+type Result struct {
+	// Index of the croppable in the processed slice.
+	Index int
+	Path  string
+	// Cropped is true if cropping made changes to the image.
+	Cropped bool
+	Err     error
+}
+
+// Process loads, crops and saves all croppables. Croppables without an image are loaded before cropping
+// and unloaded once saved. If limits are set the dimensions of an image are decoded first,
+// the image is loaded only once it fits in the memory budget.
+// report is called from a single goroutine with results in the order of croppables, it can be nil.
+func (p *Processor) Process(croppables []*Croppable, report func(Result)) {
+	budget := p.budget()
+
+	p.run(len(croppables), func(idx int) Result {
+		return p.process(idx, croppables[idx], budget)
+	}, report)
+}
+
+// ProcessGroups loads, crops and saves all croppables of every group, croppables of a group are cropped to the same rectangle, see CropGroup.
+// Croppables are loaded twice, first to find the rectangle of their group and then to crop them, so the memory limits apply like in Process.
+// Streaming does not apply, grouped images are loaded entirely.
+// report is called from a single goroutine with results in the order of croppables of all groups, Index counts croppables across groups.
+func (p *Processor) ProcessGroups(groups [][]*Croppable, report func(Result)) {
+	var (
+		croppables []*Croppable
+		groupOf    []int
+	)
+
+	for g, group := range groups {
+		for _, c := range group {
+			croppables = append(croppables, c)
+			groupOf = append(groupOf, g)
+		}
+	}
+
+	budget := p.budget()
+	unions := make([]groupUnion, len(groups))
+	errs := make([]error, len(croppables))
+	mu := &sync.Mutex{}
+
+	p.run(len(croppables), func(idx int) Result {
+		errs[idx] = p.withLoaded(croppables[idx], budget, func(c *Croppable) error {
+			rect, bounds, ok := p.cropper.contentOf(c)
+
+			mu.Lock()
+			unions[groupOf[idx]].add(rect, bounds, ok)
+			mu.Unlock()
+
+			return nil
+		})
+
+		return Result{Index: idx}
+	}, nil)
+
+	p.run(len(croppables), func(idx int) Result {
+		res := Result{Index: idx, Path: croppables[idx].Path, Err: errs[idx]}
+		if res.Err != nil {
+			return res
+		}
+
+		res.Err = p.withLoaded(croppables[idx], budget, func(c *Croppable) (err error) {
+			cropped, rect, ok := p.cropper.cropTo(c, unions[groupOf[idx]].rect())
+			res.Cropped, err = p.cropper.saveCropped(c, cropped, rect, ok)
+
+			return err
+		})
+
+		return res
+	}, report)
+}
+
+// budget returns the memory budget shared by all images processed at once, nil if memory is not limited.
+func (p *Processor) budget() *memoryBudget {
+	if p.maxMemory > 0 {
+		return newMemoryBudget(p.maxMemory)
+	}
+
+	return nil
+}
+
+// run calls fn with indexes in range of n using at most p.jobs goroutines,
+// report is called from a single goroutine with results in the order of indexes, it can be nil.
+func (p *Processor) run(n int, fn func(idx int) Result, report func(Result)) {
+	jobs := make(chan int)
+	results := make(chan Result)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(p.jobs)
+
+	for w := 0; w < p.jobs; w++ {
+		go func() {
+			defer wg.Done()
+
+			for idx := range jobs {
+				results <- fn(idx)
+			}
+		}()
+	}
+
+	go func() {
+		for idx := 0; idx < n; idx++ {
+			jobs <- idx
+		}
+
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	// results arrive in any order, hold them back until all preceding results were reported
+	pending := map[int]Result{}
+	next := 0
+
+	for res := range results {
+		pending[res.Index] = res
+
+		for r, ok := pending[next]; ok; r, ok = pending[next] {
+			delete(pending, next)
+			next++
+
+			if report != nil {
+				report(r)
+			}
+		}
+	}
+}
+
+func (p *Processor) process(idx int, c *Croppable, budget *memoryBudget) Result {
+	res := Result{Index: idx, Path: c.Path}
+
+	// streamed images are never held in memory entirely, only the pixel limit applies
+	if p.cropper.streamable(c) {
+		if _, err := p.admit(c, false); err != nil {
+			res.Err = err
+			return res
+		}
+
+		// images which can't be streamed are loaded within the memory budget
+		res.Cropped, res.Err = p.cropper.streamCropAndSave(c, func(c *Croppable, fn func(c *Croppable) error) error {
+			return p.withLoaded(c, budget, fn)
+		})
+
+		return res
+	}
+
+	res.Err = p.withLoaded(c, budget, func(c *Croppable) (err error) {
+		res.Cropped, err = p.cropper.cropAndSave(c)
+		return err
+	})
+
+	return res
+}
+
+// withLoaded calls fn with the croppable loaded, croppables without an image are loaded once they fit in the memory budget
+// and unloaded after fn returns.
+func (p *Processor) withLoaded(c *Croppable, budget *memoryBudget, fn func(c *Croppable) error) error {
+	if c.Image == nil {
+		size, err := p.admit(c, true)
+		if err != nil {
+			return err
+		}
+
+		if budget != nil {
+			budget.acquire(size)
+			defer budget.release(size)
+		}
+
+		return withLoaded(c, fn)
+	}
+
+	return fn(c)
+}
+
+// admit checks the dimensions of the croppable image against the limits and returns its estimated decoded size.
+// If checkMemory is false only the pixel limit is checked.
+func (p *Processor) admit(c *Croppable, checkMemory bool) (int64, error) {
+	maxMemory := p.maxMemory
+	if !checkMemory {
+		maxMemory = 0
+	}
+
+	if maxMemory == 0 && p.maxPixels == 0 {
+		return 0, nil
+	}
+
+	cfg, err := c.Config()
+	if err != nil {
+		return 0, err
+	}
+
+	pixels := int64(cfg.Width) * int64(cfg.Height)
+
+	var size int64
+	if maxMemory > 0 {
+		if size, err = c.loadedSize(cfg); err != nil {
+			return 0, err
+		}
+	}
+
+	if (p.maxPixels > 0 && pixels > p.maxPixels) || (maxMemory > 0 && size > maxMemory) {
+		return 0, &ImageTooLargeError{
+			Path:      c.Path,
+			Pixels:    pixels,
+			Bytes:     size,
+			MaxPixels: p.maxPixels,
+			MaxMemory: maxMemory,
+		}
+	}
+
+	return size, nil
+}
+
+type ProcessorOption func(*Processor) error
+
+// WithJobs sets the maximum number of images processed at once.
+func WithJobs(jobs int) ProcessorOption {
+	return func(p *Processor) error {
+		if jobs < 1 {
+			return errors.New("number of jobs must be positive")
+		}
+
+		p.jobs = jobs
+
+		return nil
+	}
+}
+
+// WithMaxMemory sets the budget in bytes for decoded images held in memory at once.
+// The decoded size of an image is estimated from its dimensions and color model before loading it, counting all frames
+// of animated GIF and PNG images and all entries of icons and cursors, images that do not fit in the remaining budget wait for other images to be released.
+// Images larger than the whole budget are rejected with *ImageTooLargeError.
+func WithMaxMemory(bytes int64) ProcessorOption {
+	return func(p *Processor) error {
+		if bytes < 0 {
+			return errors.New("max memory cannot be negative")
+		}
+
+		p.maxMemory = bytes
+
+		return nil
+	}
+}
+
+// WithMaxPixels rejects images with more pixels than the limit with *ImageTooLargeError, before they are decoded.
+// It protects from decompression bombs, small files which decode into huge images.
+func WithMaxPixels(pixels int64) ProcessorOption {
+	return func(p *Processor) error {
+		if pixels < 0 {
+			return errors.New("max pixels cannot be negative")
+		}
+
+		p.maxPixels = pixels
+
+		return nil
+	}
+}
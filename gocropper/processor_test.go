@@ -0,0 +1,189 @@
+package gocropper_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/H3Cki/gocrop/gocropper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessor_Process(t *testing.T) {
+	outDir := t.TempDir()
+
+	cropper, err := gocropper.NewCropper(gocropper.WithOutDir(outDir))
+	assert.NoError(t, err)
+
+	fns := []string{
+		"white-0-0-100-100.png",
+		"circle-25-25-75-75.png",
+		"missing.png",
+		"rect-25-30-75-70.png",
+		"recthollow-25-30-75-70.png",
+		"line1px-49-0-50-100.png",
+		"line1px-49-0-50-100.gif",
+	}
+
+	croppables := []*gocropper.Croppable{}
+
+	for _, fn := range fns {
+		c, err := gocropper.NewCroppable(path.Join("testdata/described", fn))
+		assert.NoError(t, err)
+
+		croppables = append(croppables, c)
+	}
+
+	for _, jobs := range []int{1, 3, 16} {
+		processor, err := gocropper.NewProcessor(cropper, gocropper.WithJobs(jobs))
+		assert.NoError(t, err)
+
+		results := []gocropper.Result{}
+
+		processor.Process(croppables, func(r gocropper.Result) {
+			results = append(results, r)
+		})
+
+		assert.Len(t, results, len(fns))
+
+		for idx, res := range results {
+			assert.Equal(t, idx, res.Index)
+			assert.Equal(t, croppables[idx].Path, res.Path)
+			assert.Nil(t, croppables[idx].Image)
+
+			if fns[idx] == "missing.png" {
+				assert.ErrorIs(t, res.Err, os.ErrNotExist)
+				continue
+			}
+
+			assert.NoError(t, res.Err)
+			assert.Equal(t, fns[idx] != "white-0-0-100-100.png", res.Cropped)
+			assert.FileExists(t, path.Join(outDir, fns[idx]))
+		}
+	}
+}
+
+func TestNewProcessor(t *testing.T) {
+	cropper, _ := gocropper.NewCropper()
+
+	_, err := gocropper.NewProcessor(nil)
+	assert.Error(t, err)
+
+	_, err = gocropper.NewProcessor(cropper, gocropper.WithJobs(0))
+	assert.Error(t, err)
+
+	_, err = gocropper.NewProcessor(cropper)
+	assert.NoError(t, err)
+}
+
+func TestProcessor_Limits(t *testing.T) {
+	cropper, err := gocropper.NewCropper(gocropper.WithOutDir(t.TempDir()))
+	assert.NoError(t, err)
+
+	fns := []string{
+		"circle-25-25-75-75.png",
+		"rect-25-30-75-70.png",
+		"recthollow-25-30-75-70.png",
+		"line1px-49-0-50-100.gif",
+	}
+
+	tests := []struct {
+		name       string
+		options    []gocropper.ProcessorOption
+		exTooLarge []bool
+	}{
+		// all test images are paletted, decoded size is 1 byte per pixel
+		{
+			name:       "budget fits one image at a time",
+			options:    []gocropper.ProcessorOption{gocropper.WithMaxMemory(100 * 100)},
+			exTooLarge: []bool{false, false, false, false},
+		},
+		{
+			name:       "budget smaller than images",
+			options:    []gocropper.ProcessorOption{gocropper.WithMaxMemory(100*100 - 1)},
+			exTooLarge: []bool{true, true, true, true},
+		},
+		{
+			name:       "pixel limit",
+			options:    []gocropper.ProcessorOption{gocropper.WithMaxPixels(100*100 - 1)},
+			exTooLarge: []bool{true, true, true, true},
+		},
+		{
+			name:       "pixel limit fits",
+			options:    []gocropper.ProcessorOption{gocropper.WithMaxPixels(100 * 100)},
+			exTooLarge: []bool{false, false, false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			croppables := []*gocropper.Croppable{}
+
+			for _, fn := range fns {
+				c, err := gocropper.NewCroppable(path.Join("testdata/described", fn))
+				assert.NoError(t, err)
+
+				croppables = append(croppables, c)
+			}
+
+			processor, err := gocropper.NewProcessor(cropper, append(tt.options, gocropper.WithJobs(4))...)
+			assert.NoError(t, err)
+
+			processor.Process(croppables, func(res gocropper.Result) {
+				if !tt.exTooLarge[res.Index] {
+					assert.NoError(t, res.Err)
+					return
+				}
+
+				assert.ErrorIs(t, res.Err, gocropper.ErrImageTooLarge)
+
+				var tooLarge *gocropper.ImageTooLargeError
+				if assert.ErrorAs(t, res.Err, &tooLarge) {
+					assert.Equal(t, int64(100*100), tooLarge.Pixels)
+				}
+			})
+		})
+	}
+}
+
+func TestProcessor_StreamLimits(t *testing.T) {
+	dir := t.TempDir()
+
+	full := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(full, full.Bounds(), image.Black, image.Point{}, draw.Src)
+
+	dot := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	dot.Set(5, 5, color.Black)
+
+	croppables := []*gocropper.Croppable{}
+
+	for fn, img := range map[string]image.Image{"full.png": full, "dot.png": dot} {
+		writePNG(t, path.Join(dir, fn), img)
+
+		c, err := gocropper.NewCroppable(path.Join(dir, fn))
+		assert.NoError(t, err)
+
+		croppables = append(croppables, c)
+	}
+
+	cropper, err := gocropper.NewCropper(gocropper.WithOutDir(t.TempDir()), gocropper.WithStreaming(true))
+	assert.NoError(t, err)
+
+	// streamed images don't need the budget, unchanged images are loaded entirely to be saved and do
+	processor, err := gocropper.NewProcessor(cropper, gocropper.WithMaxMemory(20*20*4-1))
+	assert.NoError(t, err)
+
+	processor.Process(croppables, func(res gocropper.Result) {
+		if filepath.Base(res.Path) == "full.png" {
+			assert.ErrorIs(t, res.Err, gocropper.ErrImageTooLarge)
+			return
+		}
+
+		assert.NoError(t, res.Err)
+		assert.True(t, res.Cropped)
+	})
+}
@@ -0,0 +1,217 @@
+package gocropper
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+var ErrInvalidQOI = errors.New("invalid qoi")
+
+var qoiMagic = []byte("qoif")
+
+// qoi chunk tags
+const (
+	qoiOpIndex = 0x00
+	qoiOpDiff  = 0x40
+	qoiOpLuma  = 0x80
+	qoiOpRun   = 0xc0
+	qoiOpRGB   = 0xfe
+	qoiOpRGBA  = 0xff
+	qoiMask2   = 0xc0
+)
+
+const qoiHeaderLen = 14
+
+var qoiEnd = []byte{0, 0, 0, 0, 0, 0, 0, 1}
+
+type qoiHeader struct {
+	width, height int
+	channels      uint8
+}
+
+func readQOIHeader(r io.Reader) (qoiHeader, error) {
+	b := make([]byte, qoiHeaderLen)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return qoiHeader{}, err
+	}
+
+	if string(b[:4]) != string(qoiMagic) {
+		return qoiHeader{}, fmt.Errorf("missing magic: %w", ErrInvalidQOI)
+	}
+
+	h := qoiHeader{
+		width:    int(binary.BigEndian.Uint32(b[4:])),
+		height:   int(binary.BigEndian.Uint32(b[8:])),
+		channels: b[12],
+	}
+
+	if h.channels != 3 && h.channels != 4 {
+		return qoiHeader{}, fmt.Errorf("%d channels: %w", h.channels, ErrInvalidQOI)
+	}
+
+	if h.width <= 0 || h.height <= 0 || h.width > 1<<20 || h.height > 1<<20 {
+		return qoiHeader{}, fmt.Errorf("%dx%d: %w", h.width, h.height, ErrInvalidQOI)
+	}
+
+	return h, nil
+}
+
+func decodeQOIConfig(r io.Reader) (image.Config, error) {
+	h, err := readQOIHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	return image.Config{ColorModel: color.NRGBAModel, Width: h.width, Height: h.height}, nil
+}
+
+func qoiHash(c color.NRGBA) int {
+	return (int(c.R)*3 + int(c.G)*5 + int(c.B)*7 + int(c.A)*11) % 64
+}
+
+// decodeQOI decodes a QOI image into *image.NRGBA.
+func decodeQOI(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+
+	h, err := readQOIHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, h.width, h.height))
+
+	var index [64]color.NRGBA
+
+	px := color.NRGBA{0, 0, 0, 0xff}
+	run := 0
+	buf := make([]byte, 4)
+
+	for i := 0; i < len(img.Pix); i += 4 {
+		if run > 0 {
+			run--
+		} else {
+			tag, err := br.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+
+			switch {
+			case tag == qoiOpRGB:
+				if _, err := io.ReadFull(br, buf[:3]); err != nil {
+					return nil, err
+				}
+
+				px.R, px.G, px.B = buf[0], buf[1], buf[2]
+			case tag == qoiOpRGBA:
+				if _, err := io.ReadFull(br, buf); err != nil {
+					return nil, err
+				}
+
+				px = color.NRGBA{buf[0], buf[1], buf[2], buf[3]}
+			case tag&qoiMask2 == qoiOpIndex:
+				px = index[tag]
+			case tag&qoiMask2 == qoiOpDiff:
+				px.R += (tag>>4)&0x03 - 2
+				px.G += (tag>>2)&0x03 - 2
+				px.B += tag&0x03 - 2
+			case tag&qoiMask2 == qoiOpLuma:
+				b, err := br.ReadByte()
+				if err != nil {
+					return nil, err
+				}
+
+				dg := tag&0x3f - 32
+				px.R += dg - 8 + (b>>4)&0x0f
+				px.G += dg
+				px.B += dg - 8 + b&0x0f
+			default:
+				run = int(tag & 0x3f)
+			}
+
+			index[qoiHash(px)] = px
+		}
+
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = px.R, px.G, px.B, px.A
+	}
+
+	return img, nil
+}
+
+// encodeQOI encodes the image as QOI, opaque images are encoded with 3 channels.
+func encodeQOI(w io.Writer, m image.Image) error {
+	b := m.Bounds()
+
+	header := make([]byte, qoiHeaderLen)
+	copy(header, qoiMagic)
+	binary.BigEndian.PutUint32(header[4:], uint32(b.Dx()))
+	binary.BigEndian.PutUint32(header[8:], uint32(b.Dy()))
+	header[12] = 4
+
+	if opaque(m) {
+		header[12] = 3
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.Write(header)
+
+	var index [64]color.NRGBA
+
+	prev := color.NRGBA{0, 0, 0, 0xff}
+	run := 0
+	last := b.Dx()*b.Dy() - 1
+	n := 0
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x, n = x+1, n+1 {
+			px := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+
+			if px == prev {
+				run++
+				if run == 62 || n == last {
+					bw.WriteByte(qoiOpRun | byte(run-1))
+					run = 0
+				}
+
+				continue
+			}
+
+			if run > 0 {
+				bw.WriteByte(qoiOpRun | byte(run-1))
+				run = 0
+			}
+
+			h := qoiHash(px)
+
+			switch {
+			case index[h] == px:
+				bw.WriteByte(qoiOpIndex | byte(h))
+			case px.A != prev.A:
+				bw.Write([]byte{qoiOpRGBA, px.R, px.G, px.B, px.A})
+			default:
+				dr, dg, db := int8(px.R-prev.R), int8(px.G-prev.G), int8(px.B-prev.B)
+				drg, dbg := dr-dg, db-dg
+
+				switch {
+				case dr >= -2 && dr <= 1 && dg >= -2 && dg <= 1 && db >= -2 && db <= 1:
+					bw.WriteByte(qoiOpDiff | byte(dr+2)<<4 | byte(dg+2)<<2 | byte(db+2))
+				case dg >= -32 && dg <= 31 && drg >= -8 && drg <= 7 && dbg >= -8 && dbg <= 7:
+					bw.Write([]byte{qoiOpLuma | byte(dg+32), byte(drg+8)<<4 | byte(dbg+8)})
+				default:
+					bw.Write([]byte{qoiOpRGB, px.R, px.G, px.B})
+				}
+			}
+
+			index[h] = px
+			prev = px
+		}
+	}
+
+	bw.Write(qoiEnd)
+
+	return bw.Flush()
+}
@@ -0,0 +1,133 @@
+package gocropper
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// qoiBytes returns a QOI image with the header fields, chunks and the end marker.
+func qoiBytes(width, height int, channels uint8, chunks ...byte) []byte {
+	data := append([]byte{}, qoiMagic...)
+	data = append(data, 0, 0, 0, byte(width), 0, 0, 0, byte(height), channels, 0)
+	data = append(data, chunks...)
+
+	return append(data, qoiEnd...)
+}
+
+func TestDecodeQOI(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		exPixels []color.NRGBA
+		exErr    error
+	}{
+		{
+			name: "all chunks",
+			data: qoiBytes(3, 2, 4,
+				qoiOpRGB, 10, 20, 30,
+				0x76,       // diff +1 -1 0
+				0xaa, 0x5a, // luma dg +10, dr-dg -3, db-dg +2
+				qoiOpRGBA, 1, 2, 3, 4,
+				0x09, // index of 10 20 30 255
+				0xc0, // run of 1
+			),
+			exPixels: []color.NRGBA{
+				{10, 20, 30, 255}, {11, 19, 30, 255}, {18, 29, 42, 255},
+				{1, 2, 3, 4}, {10, 20, 30, 255}, {10, 20, 30, 255},
+			},
+		},
+		{
+			name:     "run from initial pixel",
+			data:     qoiBytes(2, 1, 3, 0xc1),
+			exPixels: []color.NRGBA{{0, 0, 0, 255}, {0, 0, 0, 255}},
+		},
+		{
+			name:  "bad magic",
+			data:  append([]byte("qoix"), qoiBytes(1, 1, 4)[4:]...),
+			exErr: ErrInvalidQOI,
+		},
+		{
+			name:  "bad channels",
+			data:  qoiBytes(1, 1, 5),
+			exErr: ErrInvalidQOI,
+		},
+		{
+			name:  "truncated",
+			data:  qoiBytes(2, 2, 4, qoiOpRGB, 1, 2, 3)[:qoiHeaderLen+4],
+			exErr: io.EOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := decodeQOI(bytes.NewReader(tt.data))
+			assert.ErrorIs(t, err, tt.exErr)
+
+			if tt.exErr != nil {
+				return
+			}
+
+			w := img.Bounds().Dx()
+			for i, c := range tt.exPixels {
+				assert.Equal(t, c, img.At(i%w, i/w), "pixel %d", i)
+			}
+		})
+	}
+}
+
+func TestEncodeQOI(t *testing.T) {
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 200, 3))
+	for x := 0; x < 200; x++ {
+		// long runs, small and large differences, alpha changes and repeated colors
+		nrgba.SetNRGBA(x, 1, color.NRGBA{uint8(x / 7), uint8(x * 3), uint8(x % 5), uint8(x / 50 * 60)})
+		nrgba.SetNRGBA(x, 2, color.NRGBA{uint8(x % 4 * 80), 0, 0, 0xff})
+	}
+
+	rgb := image.NewRGBA(image.Rect(5, 5, 10, 8))
+	for i := range rgb.Pix {
+		rgb.Pix[i] = uint8(i * 13)
+		if i%4 == 3 {
+			rgb.Pix[i] = 0xff
+		}
+	}
+
+	tests := []struct {
+		name       string
+		img        image.Image
+		exChannels uint8
+	}{
+		{"rgba", nrgba, 4},
+		{"rgb", rgb, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			assert.NoError(t, encodeQOI(buf, tt.img))
+			assert.Equal(t, tt.exChannels, buf.Bytes()[12])
+			assert.True(t, bytes.HasSuffix(buf.Bytes(), qoiEnd))
+
+			cfg, err := decodeQOIConfig(bytes.NewReader(buf.Bytes()))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.img.Bounds().Size(), image.Pt(cfg.Width, cfg.Height))
+
+			decoded, err := decodeQOI(buf)
+			assert.NoError(t, err)
+
+			b := tt.img.Bounds()
+			for y := 0; y < b.Dy(); y++ {
+				for x := 0; x < b.Dx(); x++ {
+					ex := color.NRGBAModel.Convert(tt.img.At(b.Min.X+x, b.Min.Y+y))
+					if !assert.Equal(t, ex, decoded.At(x, y), "pixel %d,%d", x, y) {
+						return
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package gocropper
+
+import (
+	"image"
+	"image/color"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// parallelMinPixels is the minimum number of pixels of an image for it to be scanned by multiple workers.
+	parallelMinPixels = 1 << 20
+	// bandPixels is the approximate number of pixels in a band of lines claimed by a worker at once.
+	bandPixels = 1 << 16
+)
+
+// scanner reports whether pixels of an image are part of its content.
+// Scanners for concrete image types read pixel buffers directly instead of going through image.Image.At,
+// which allocates a color.Color for every pixel.
+type scanner interface {
+	filled(x, y int) bool
+}
+
+func newScanner(img image.Image, m pixelMatcher) scanner {
+	switch img := img.(type) {
+	case *image.NRGBA:
+		return &nrgbaScanner{img: img, m: m}
+	case *image.RGBA:
+		return &rgbaScanner{img: img, m: m}
+	case *image.NRGBA64:
+		return &nrgba64Scanner{img: img, m: m}
+	case *image.RGBA64:
+		return &rgba64Scanner{img: img, m: m}
+	case *image.Paletted:
+		return newPalettedScanner(img, m)
+	case *image.Gray:
+		return newGrayScanner(img, m)
+	case *image.YCbCr:
+		return &ycbcrScanner{img: img, m: m}
+	default:
+		return &genericScanner{img: img, m: m}
+	}
+}
+
+type genericScanner struct {
+	img image.Image
+	m   pixelMatcher
+}
+
+func (s *genericScanner) filled(x, y int) bool {
+	return s.m.filled(s.img.At(x, y).RGBA())
+}
+
+type nrgbaScanner struct {
+	img *image.NRGBA
+	m   pixelMatcher
+}
+
+func (s *nrgbaScanner) filled(x, y int) bool {
+	i := s.img.PixOffset(x, y)
+	p := s.img.Pix[i : i+4 : i+4]
+
+	a := uint32(p[3])
+	if a*0x101 <= s.m.threshold {
+		return false
+	}
+
+	if !s.m.hasBackground {
+		return true
+	}
+
+	// same as color.NRGBA.RGBA
+	r := uint32(p[0]) * 0x101 * a / 0xff
+	g := uint32(p[1]) * 0x101 * a / 0xff
+	b := uint32(p[2]) * 0x101 * a / 0xff
+
+	return s.m.filled(r, g, b, a*0x101)
+}
+
+type rgbaScanner struct {
+	img *image.RGBA
+	m   pixelMatcher
+}
+
+func (s *rgbaScanner) filled(x, y int) bool {
+	i := s.img.PixOffset(x, y)
+	p := s.img.Pix[i : i+4 : i+4]
+
+	return s.m.filled(uint32(p[0])*0x101, uint32(p[1])*0x101, uint32(p[2])*0x101, uint32(p[3])*0x101)
+}
+
+type nrgba64Scanner struct {
+	img *image.NRGBA64
+	m   pixelMatcher
+}
+
+func (s *nrgba64Scanner) filled(x, y int) bool {
+	i := s.img.PixOffset(x, y)
+	p := s.img.Pix[i : i+8 : i+8]
+
+	a := uint32(p[6])<<8 | uint32(p[7])
+	if a <= s.m.threshold {
+		return false
+	}
+
+	if !s.m.hasBackground {
+		return true
+	}
+
+	// same as color.NRGBA64.RGBA
+	r := (uint32(p[0])<<8 | uint32(p[1])) * a / 0xffff
+	g := (uint32(p[2])<<8 | uint32(p[3])) * a / 0xffff
+	b := (uint32(p[4])<<8 | uint32(p[5])) * a / 0xffff
+
+	return s.m.filled(r, g, b, a)
+}
+
+type rgba64Scanner struct {
+	img *image.RGBA64
+	m   pixelMatcher
+}
+
+func (s *rgba64Scanner) filled(x, y int) bool {
+	i := s.img.PixOffset(x, y)
+	p := s.img.Pix[i : i+8 : i+8]
+
+	return s.m.filled(
+		uint32(p[0])<<8|uint32(p[1]),
+		uint32(p[2])<<8|uint32(p[3]),
+		uint32(p[4])<<8|uint32(p[5]),
+		uint32(p[6])<<8|uint32(p[7]),
+	)
+}
+
+// palettedScanner looks up precomputed results for every palette index.
+type palettedScanner struct {
+	img     *image.Paletted
+	indices [256]bool
+}
+
+func newPalettedScanner(img *image.Paletted, m pixelMatcher) *palettedScanner {
+	s := &palettedScanner{img: img}
+
+	for i := range s.indices {
+		if i < len(img.Palette) {
+			s.indices[i] = m.filled(img.Palette[i].RGBA())
+		} else {
+			s.indices[i] = m.filled(color.Transparent.RGBA())
+		}
+	}
+
+	return s
+}
+
+func (s *palettedScanner) filled(x, y int) bool {
+	return s.indices[s.img.Pix[s.img.PixOffset(x, y)]]
+}
+
+// grayScanner looks up precomputed results for every gray level.
+type grayScanner struct {
+	img    *image.Gray
+	levels [256]bool
+}
+
+func newGrayScanner(img *image.Gray, m pixelMatcher) *grayScanner {
+	s := &grayScanner{img: img}
+
+	for i := range s.levels {
+		s.levels[i] = m.filled(color.Gray{Y: uint8(i)}.RGBA())
+	}
+
+	return s
+}
+
+func (s *grayScanner) filled(x, y int) bool {
+	return s.levels[s.img.Pix[s.img.PixOffset(x, y)]]
+}
+
+type ycbcrScanner struct {
+	img *image.YCbCr
+	m   pixelMatcher
+}
+
+func (s *ycbcrScanner) filled(x, y int) bool {
+	// YCbCr images are always opaque
+	if !s.m.hasBackground {
+		return 0xffff > s.m.threshold
+	}
+
+	yi := s.img.YOffset(x, y)
+	ci := s.img.COffset(x, y)
+
+	return s.m.filled(color.YCbCr{Y: s.img.Y[yi], Cb: s.img.Cb[ci], Cr: s.img.Cr[ci]}.RGBA())
+}
+
+// firstLine returns the lowest index in range [0, n) for which hit returns true, or -1 if there is none.
+// hit is expected to scan a line of lineLen pixels. Multiple workers claim bands of consecutive lines in order,
+// bands and lines after an already found hit are skipped.
+func firstLine(n, lineLen, workers int, hit func(int) bool) int {
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if hit(i) {
+				return i
+			}
+		}
+
+		return -1
+	}
+
+	band := 1
+	if lineLen > 0 && lineLen < bandPixels {
+		band = bandPixels / lineLen
+	}
+
+	var next, best atomic.Int64
+
+	best.Store(int64(n))
+
+	wg := &sync.WaitGroup{}
+	wg.Add(workers)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+
+			for {
+				start := next.Add(int64(band)) - int64(band)
+				if start >= best.Load() {
+					return
+				}
+
+				end := start + int64(band)
+				if end > int64(n) {
+					end = int64(n)
+				}
+
+				for i := start; i < end && i < best.Load(); i++ {
+					if hit(int(i)) {
+						storeMin(&best, i)
+						break
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if found := int(best.Load()); found < n {
+		return found
+	}
+
+	return -1
+}
+
+func storeMin(v *atomic.Int64, n int64) {
+	for {
+		cur := v.Load()
+		if n >= cur || v.CompareAndSwap(cur, n) {
+			return
+		}
+	}
+}
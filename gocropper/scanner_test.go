@@ -0,0 +1,234 @@
+package gocropper
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// hiddenType hides the concrete type of an image, forcing the generic scanner.
+type hiddenType struct {
+	image.Image
+}
+
+func noiseImage(size int) *image.NRGBA {
+	rnd := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rnd.Intn(256))
+	}
+
+	// plenty of fully transparent and fully opaque pixels
+	for i := 3; i+4 < len(img.Pix); i += 12 {
+		img.Pix[i] = 0
+		img.Pix[i+4] = 0xff
+	}
+
+	return img
+}
+
+func convertImage(src image.Image, typ string) image.Image {
+	b := src.Bounds()
+
+	var dst draw.Image
+
+	switch typ {
+	case "NRGBA":
+		dst = image.NewNRGBA(b)
+	case "RGBA":
+		dst = image.NewRGBA(b)
+	case "NRGBA64":
+		dst = image.NewNRGBA64(b)
+	case "RGBA64":
+		dst = image.NewRGBA64(b)
+	case "Paletted":
+		dst = image.NewPaletted(b, append(palette.WebSafe, color.Transparent))
+	case "Gray":
+		dst = image.NewGray(b)
+	case "YCbCr":
+		ycbcr := image.NewYCbCr(b, image.YCbCrSubsampleRatio420)
+
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, bl, _ := src.At(x, y).RGBA()
+				yy, cb, cr := color.RGBToYCbCr(uint8(r>>8), uint8(g>>8), uint8(bl>>8))
+				ycbcr.Y[ycbcr.YOffset(x, y)] = yy
+				ycbcr.Cb[ycbcr.COffset(x, y)] = cb
+				ycbcr.Cr[ycbcr.COffset(x, y)] = cr
+			}
+		}
+
+		return ycbcr
+	}
+
+	draw.Draw(dst, b, src, b.Min, draw.Src)
+
+	return dst
+}
+
+var scannerTypes = []string{"NRGBA", "RGBA", "NRGBA64", "RGBA64", "Paletted", "Gray", "YCbCr"}
+
+func TestNewScanner(t *testing.T) {
+	src := noiseImage(64)
+
+	matchers := map[string]pixelMatcher{
+		"alpha":     {},
+		"threshold": {threshold: 0x8000},
+		"background": {
+			background:    color.RGBA64{0x8000, 0x8000, 0x8000, 0xffff},
+			hasBackground: true,
+			tolerance:     100,
+			metric:        MetricRGB,
+			ref:           MetricRGB.reference(color.RGBA64{0x8000, 0x8000, 0x8000, 0xffff}),
+		},
+	}
+
+	for _, typ := range scannerTypes {
+		for name, m := range matchers {
+			t.Run(fmt.Sprintf("%s/%s", typ, name), func(t *testing.T) {
+				img := convertImage(src, typ)
+				s := newScanner(img, m)
+				_, generic := s.(*genericScanner)
+				assert.False(t, generic)
+
+				expected := newScanner(hiddenType{img}, m)
+
+				for y := 0; y < 64; y++ {
+					for x := 0; x < 64; x++ {
+						if !assert.Equal(t, expected.filled(x, y), s.filled(x, y), "pixel %d,%d", x, y) {
+							return
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkCropper_Rect(b *testing.B) {
+	const size = 2048
+
+	dot := image.Rect(size/2, size/2, size/2+1, size/2+1)
+
+	transparent := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(transparent, dot, image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	white := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(white, white.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(white, dot, image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	alphaCropper, _ := NewCropper()
+	bgCropper, _ := NewCropper(WithBackgroundColor(color.White, 10))
+
+	modes := []struct {
+		name    string
+		src     image.Image
+		cropper *Cropper
+	}{
+		{"alpha", transparent, alphaCropper},
+		{"background", white, bgCropper},
+	}
+
+	for _, mode := range modes {
+		for _, typ := range scannerTypes {
+			img := convertImage(mode.src, typ)
+			cropper := mode.cropper
+
+			b.Run(mode.name+"/"+typ+"/fast", func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					cropper.Rect(img)
+				}
+			})
+
+			b.Run(mode.name+"/"+typ+"/generic", func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					cropper.Rect(hiddenType{img})
+				}
+			})
+		}
+	}
+}
+
+func TestFirstLine(t *testing.T) {
+	tests := []struct {
+		n    int
+		hits []int
+		ex   int
+	}{
+		{0, nil, -1},
+		{10, nil, -1},
+		{10, []int{0}, 0},
+		{10, []int{9}, 9},
+		{1000, []int{999, 500, 501}, 500},
+		{100000, []int{99999, 4242}, 4242},
+	}
+
+	for _, tt := range tests {
+		for _, workers := range []int{1, 2, 7} {
+			t.Run(fmt.Sprintf("%d/%v/%d", tt.n, tt.hits, workers), func(t *testing.T) {
+				hit := func(i int) bool {
+					for _, h := range tt.hits {
+						if h == i {
+							return true
+						}
+					}
+
+					return false
+				}
+
+				assert.Equal(t, tt.ex, firstLine(tt.n, 16, workers, hit))
+			})
+		}
+	}
+}
+
+func TestCropper_RectWorkers(t *testing.T) {
+	sequential, _ := NewCropper()
+	parallel, _ := NewCropper(WithWorkers(4))
+
+	rnd := rand.New(rand.NewSource(1))
+
+	for n := 0; n < 20; n++ {
+		img := image.NewNRGBA(image.Rect(0, 0, 1200, 1000))
+
+		for dots := rnd.Intn(4); dots > 0; dots-- {
+			x, y := rnd.Intn(1200), rnd.Intn(1000)
+			img.SetNRGBA(x, y, color.NRGBA{A: 0xff})
+		}
+
+		assert.Equal(t, sequential.Rect(img), parallel.Rect(img))
+	}
+}
+
+func BenchmarkCropper_RectWorkers(b *testing.B) {
+	// mostly opaque image, only a thin transparent border needs to be scanned
+	img := image.NewNRGBA(image.Rect(0, 0, 10000, 10000))
+	draw.Draw(img, image.Rect(3, 3, 9997, 9997), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	// content in a single pixel, the whole image needs to be scanned
+	dot := image.NewNRGBA(image.Rect(0, 0, 4000, 4000))
+	dot.SetNRGBA(3000, 3000, color.NRGBA{A: 0xff})
+
+	for _, workers := range []int{1, 4} {
+		cropper, _ := NewCropper(WithWorkers(workers))
+
+		b.Run(fmt.Sprintf("opaque/%d", workers), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				cropper.Rect(img)
+			}
+		})
+
+		b.Run(fmt.Sprintf("dot/%d", workers), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				cropper.Rect(dot)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package gocropper
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+)
+
+// Slicer slices images into cells of a fixed size grid, e.g. sprite sheets with a fixed sprite size.
+// Cells without content are skipped, the content is found according to the options of the cropper, which also trims, pads and saves the cells.
+type Slicer struct {
+	cropper *Cropper
+	cell    image.Point
+	margin  int
+	spacing int
+	trim    bool
+}
+
+// NewSlicer creates a *Slicer slicing images into cells of given width and height using the given cropper, returns error if any option fails.
+//
+// Default Slicer with no options expects cells starting at the top left corner of the image without any space between them
+// and saves whole cells.
+func NewSlicer(cropper *Cropper, width, height int, options ...SlicerOption) (*Slicer, error) {
+	if cropper == nil {
+		return nil, errors.New("cropper cannot be nil")
+	}
+
+	if width <= 0 || height <= 0 {
+		return nil, errors.New("cell size must be positive")
+	}
+
+	s := &Slicer{cropper: cropper, cell: image.Pt(width, height)}
+
+	for _, opt := range options {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Cell is a cell of the grid of a sliced image.
+type Cell struct {
+	Column int
+	Row    int
+	// Rect is the rectangle of the cell in the coordinate space of the image.
+	Rect image.Rectangle
+}
+
+// Cells returns all cells of the grid which fit inside the image, row by row.
+func (s *Slicer) Cells(img image.Image) []Cell {
+	b := img.Bounds()
+	step := s.cell.Add(image.Pt(s.spacing, s.spacing))
+	// the last cell is not followed by spacing
+	columns := (b.Dx() - 2*s.margin + s.spacing) / step.X
+	rows := (b.Dy() - 2*s.margin + s.spacing) / step.Y
+
+	var cells []Cell
+
+	for row := 0; row < rows; row++ {
+		for col := 0; col < columns; col++ {
+			origin := b.Min.Add(image.Pt(s.margin+col*step.X, s.margin+row*step.Y))
+			cells = append(cells, Cell{Column: col, Row: row, Rect: image.Rectangle{origin, origin.Add(s.cell)}})
+		}
+	}
+
+	return cells
+}
+
+// Slice returns a croppable for every cell with content along with the cells, see Cells.
+// With trimming the images are cropped to the content of their cell extended by the padding of the cropper, the padding is filled
+// like the cropper fills it and never contains pixels of neighbouring cells. The background is detected on the whole image.
+// Only the image of the croppable is sliced, frames of animations are ignored.
+func (s *Slicer) Slice(c *Croppable) ([]*Croppable, []Cell) {
+	m := s.cropper.matcher(c.Image)
+
+	var (
+		sliced []*Croppable
+		cells  []Cell
+	)
+
+	for _, cell := range s.Cells(c.Image) {
+		img := c.Image.SubImage(cell.Rect).(CroppableImage)
+
+		rect, ok := s.cropper.content(img, m)
+		if !ok {
+			continue
+		}
+
+		if s.trim {
+			img, _ = s.cropper.cropRect(img, rect, m)
+		}
+
+		sliced = append(sliced, c.With(img))
+		cells = append(cells, cell)
+	}
+
+	return sliced, cells
+}
+
+// SliceManifest describes the images saved by SliceAndSave.
+type SliceManifest struct {
+	// Source is the path of the sliced image.
+	Source string      `json:"source"`
+	Width  int         `json:"width"`
+	Height int         `json:"height"`
+	Cells  []SliceCell `json:"cells"`
+}
+
+// SliceCell is a saved cell of a sliced image.
+type SliceCell struct {
+	// File is the name of the saved image, in the directory of the manifest.
+	File   string `json:"file"`
+	Column int    `json:"column"`
+	Row    int    `json:"row"`
+	// X and Y are the offset of the cell in the source image.
+	X int `json:"x"`
+	Y int `json:"y"`
+	// OffsetX and OffsetY are the offset of the saved image in the cell, negative if the padding reaches beyond the cell.
+	OffsetX int `json:"offsetX"`
+	OffsetY int `json:"offsetY"`
+	// W and H are the size of the saved image.
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// SliceAndSave slices the image and saves every cell with content like the cropper saves images, with "_row_column" appended to the name.
+// A JSON manifest of the saved images is saved next to them, named after the image with a ".json" extension.
+func (s *Slicer) SliceAndSave(c *Croppable) (*SliceManifest, error) {
+	if s.cropper.outDir != "" {
+		if err := os.MkdirAll(s.cropper.outDir, os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+
+	sliced, cells := s.Slice(c)
+	b := c.Image.Bounds()
+	manifest := &SliceManifest{Source: c.Path, Width: b.Dx(), Height: b.Dy(), Cells: []SliceCell{}}
+
+	for n, sc := range sliced {
+		cell := cells[n]
+
+		fp, err := s.cropper.saveAs(sc, fmt.Sprintf("_%d_%d", cell.Row, cell.Column))
+		if err != nil {
+			return nil, fmt.Errorf("error saving image: %w", err)
+		}
+
+		r := sc.Image.Bounds()
+		manifest.Cells = append(manifest.Cells, SliceCell{
+			File:    filepath.Base(fp),
+			Column:  cell.Column,
+			Row:     cell.Row,
+			X:       cell.Rect.Min.X - b.Min.X,
+			Y:       cell.Rect.Min.Y - b.Min.Y,
+			OffsetX: r.Min.X - cell.Rect.Min.X,
+			OffsetY: r.Min.Y - cell.Rect.Min.Y,
+			W:       r.Dx(),
+			H:       r.Dy(),
+		})
+	}
+
+	if err := s.cropper.saveManifest(c.Path, manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+type SlicerOption func(*Slicer) error
+
+// WithMargin sets the number of pixels between the edges of the image and the grid.
+func WithMargin(margin int) SlicerOption {
+	return func(s *Slicer) error {
+		if margin < 0 {
+			return errors.New("margin cannot be negative")
+		}
+
+		s.margin = margin
+
+		return nil
+	}
+}
+
+// WithSpacing sets the number of pixels between neighbouring cells.
+func WithSpacing(spacing int) SlicerOption {
+	return func(s *Slicer) error {
+		if spacing < 0 {
+			return errors.New("spacing cannot be negative")
+		}
+
+		s.spacing = spacing
+
+		return nil
+	}
+}
+
+// WithTrim enables cropping every cell to its content, see Cropper.Crop.
+func WithTrim(trim bool) SlicerOption {
+	return func(s *Slicer) error {
+		s.trim = trim
+		return nil
+	}
+}
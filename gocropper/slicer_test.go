@@ -0,0 +1,157 @@
+package gocropper_test
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/H3Cki/gocrop/gocropper"
+	"github.com/stretchr/testify/assert"
+)
+
+// gridSheet returns a transparent sheet with a grid of 4x4 cells, margin of 2 and spacing of 1, 3 columns and 2 rows.
+// Cell 0,0 has a square at (3,3)-(5,5), cell 0,2 is filled, cell 1,1 has a pixel at (10,7) and the spacing above it has a pixel at (9,6).
+func gridSheet() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 13))
+	red := color.NRGBA{255, 0, 0, 255}
+
+	for _, r := range []image.Rectangle{
+		image.Rect(3, 3, 5, 5),
+		image.Rect(12, 2, 16, 6),
+		image.Rect(10, 7, 11, 8),
+		image.Rect(9, 6, 10, 7),
+	} {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.SetNRGBA(x, y, red)
+			}
+		}
+	}
+
+	return img
+}
+
+func TestSlicer_Cells(t *testing.T) {
+	cropper, err := gocropper.NewCropper()
+	assert.NoError(t, err)
+
+	slicer, err := gocropper.NewSlicer(cropper, 4, 4, gocropper.WithMargin(2), gocropper.WithSpacing(1))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []gocropper.Cell{
+		{Column: 0, Row: 0, Rect: image.Rect(2, 2, 6, 6)},
+		{Column: 1, Row: 0, Rect: image.Rect(7, 2, 11, 6)},
+		{Column: 2, Row: 0, Rect: image.Rect(12, 2, 16, 6)},
+		{Column: 0, Row: 1, Rect: image.Rect(2, 7, 6, 11)},
+		{Column: 1, Row: 1, Rect: image.Rect(7, 7, 11, 11)},
+		{Column: 2, Row: 1, Rect: image.Rect(12, 7, 16, 11)},
+	}, slicer.Cells(gridSheet()))
+
+	// cells are not offset by the spacing, the sheet holds 5x3 cells
+	slicer, err = gocropper.NewSlicer(cropper, 4, 4)
+	assert.NoError(t, err)
+	assert.Len(t, slicer.Cells(gridSheet()), 15)
+}
+
+func TestNewSlicer(t *testing.T) {
+	cropper, err := gocropper.NewCropper()
+	assert.NoError(t, err)
+
+	_, err = gocropper.NewSlicer(nil, 4, 4)
+	assert.Error(t, err)
+
+	_, err = gocropper.NewSlicer(cropper, 0, 4)
+	assert.Error(t, err)
+
+	_, err = gocropper.NewSlicer(cropper, 4, 4, gocropper.WithMargin(-1))
+	assert.Error(t, err)
+
+	_, err = gocropper.NewSlicer(cropper, 4, 4, gocropper.WithSpacing(-1))
+	assert.Error(t, err)
+}
+
+func TestSlicer_SliceAndSave(t *testing.T) {
+	srcDir := t.TempDir()
+	src := path.Join(srcDir, "grid.png")
+
+	f, err := os.Create(src)
+	assert.NoError(t, err)
+	assert.NoError(t, png.Encode(f, gridSheet()))
+	assert.NoError(t, f.Close())
+
+	croppable, err := gocropper.Load(src)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name string
+		trim bool
+		want []gocropper.SliceCell
+	}{
+		{
+			name: "whole cells",
+			want: []gocropper.SliceCell{
+				{File: "grid_0_0.png", Column: 0, Row: 0, X: 2, Y: 2, W: 4, H: 4},
+				{File: "grid_0_2.png", Column: 2, Row: 0, X: 12, Y: 2, W: 4, H: 4},
+				{File: "grid_1_1.png", Column: 1, Row: 1, X: 7, Y: 7, W: 4, H: 4},
+			},
+		},
+		{
+			name: "trimmed cells",
+			trim: true,
+			want: []gocropper.SliceCell{
+				{File: "grid_0_0.png", Column: 0, Row: 0, X: 2, Y: 2, OffsetX: 0, OffsetY: 0, W: 4, H: 4},
+				{File: "grid_0_2.png", Column: 2, Row: 0, X: 12, Y: 2, OffsetX: -1, OffsetY: -1, W: 6, H: 6},
+				{File: "grid_1_1.png", Column: 1, Row: 1, X: 7, Y: 7, OffsetX: 2, OffsetY: -1, W: 3, H: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outDir := t.TempDir()
+
+			cropper, err := gocropper.NewCropper(gocropper.WithOutDir(outDir), gocropper.WithPadding(1))
+			assert.NoError(t, err)
+
+			slicer, err := gocropper.NewSlicer(cropper, 4, 4, gocropper.WithMargin(2), gocropper.WithSpacing(1), gocropper.WithTrim(tt.trim))
+			assert.NoError(t, err)
+
+			manifest, err := slicer.SliceAndSave(croppable)
+			assert.NoError(t, err)
+
+			want := &gocropper.SliceManifest{Source: src, Width: 20, Height: 13, Cells: tt.want}
+			assert.Equal(t, want, manifest)
+
+			data, err := os.ReadFile(path.Join(outDir, "grid.json"))
+			assert.NoError(t, err)
+
+			saved := &gocropper.SliceManifest{}
+			assert.NoError(t, json.Unmarshal(data, saved))
+			assert.Equal(t, want, saved)
+
+			for _, cell := range tt.want {
+				c, err := gocropper.Load(path.Join(outDir, cell.File))
+				assert.NoError(t, err)
+				assert.Equal(t, image.Rect(0, 0, cell.W, cell.H), c.Image.Bounds())
+			}
+
+			if !tt.trim {
+				return
+			}
+
+			// the padding of a trimmed cell doesn't contain the pixel in the spacing next to it
+			c, err := gocropper.Load(path.Join(outDir, "grid_1_1.png"))
+			assert.NoError(t, err)
+
+			_, _, _, alpha := c.Image.At(0, 0).RGBA()
+			assert.Zero(t, alpha)
+
+			_, _, _, alpha = c.Image.At(1, 1).RGBA()
+			assert.Equal(t, uint32(0xffff), alpha)
+		})
+	}
+}
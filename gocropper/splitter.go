@@ -0,0 +1,343 @@
+package gocropper
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Splitter splits images into separate images of the connected regions of their content, e.g. sprites of a sprite sheet.
+// Pixels are part of the content according to the options of the cropper, which also pads and saves the split images.
+type Splitter struct {
+	cropper       *Cropper
+	connectivity  int
+	mergeDistance int
+	minArea       int
+}
+
+// NewSplitter creates a *Splitter splitting images using the given cropper, returns error if any option fails.
+//
+// Default Splitter with no options uses 8-connectivity, does not merge regions and keeps regions of any area.
+func NewSplitter(cropper *Cropper, options ...SplitterOption) (*Splitter, error) {
+	if cropper == nil {
+		return nil, errors.New("cropper cannot be nil")
+	}
+
+	s := &Splitter{cropper: cropper, connectivity: 8}
+
+	for _, opt := range options {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Region is a connected region of image content.
+type Region struct {
+	// Rect is the bounding rectangle of the region in the coordinate space of the image, does not include padding.
+	Rect image.Rectangle
+	// Area is the number of content pixels of the region.
+	Area int
+}
+
+// Regions returns the regions of the image content sorted top to bottom, left to right.
+func (s *Splitter) Regions(img image.Image) []Region {
+	regions, _, _ := s.label(img, s.cropper.matcher(img))
+	return regions
+}
+
+// label returns the regions of the image content along with the label of every pixel, 0 for pixels which are not content,
+// and the index of the region of every label.
+func (s *Splitter) label(img image.Image, m pixelMatcher) ([]Region, []int32, []int) {
+	b := img.Bounds()
+	sc := newScanner(img, m)
+	labels := make([]int32, b.Dx()*b.Dy())
+	// components are indexed by label-1
+	var components []Region
+
+	offsets := []image.Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	if s.connectivity == 8 {
+		offsets = append(offsets, image.Point{1, 1}, image.Point{-1, 1}, image.Point{1, -1}, image.Point{-1, -1})
+	}
+
+	idx := func(p image.Point) int { return (p.Y-b.Min.Y)*b.Dx() + p.X - b.Min.X }
+
+	var stack []image.Point
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			p := image.Pt(x, y)
+			if labels[idx(p)] != 0 || !sc.filled(x, y) {
+				continue
+			}
+
+			label := int32(len(components) + 1)
+			region := Region{Rect: image.Rectangle{p, p.Add(image.Pt(1, 1))}}
+			labels[idx(p)] = label
+			stack = append(stack[:0], p)
+
+			for len(stack) > 0 {
+				p := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+
+				region.Area++
+				region.Rect = region.Rect.Union(image.Rectangle{p, p.Add(image.Pt(1, 1))})
+
+				for _, o := range offsets {
+					n := p.Add(o)
+					if !n.In(b) || labels[idx(n)] != 0 || !sc.filled(n.X, n.Y) {
+						continue
+					}
+
+					labels[idx(n)] = label
+					stack = append(stack, n)
+				}
+			}
+
+			components = append(components, region)
+		}
+	}
+
+	regions, regionOf := s.merge(components)
+
+	return regions, labels, regionOf
+}
+
+// merge merges components closer than the merge distance and drops regions smaller than the minimum area.
+// Returns the regions sorted top to bottom, left to right, and the index of the region of every label, -1 for dropped regions.
+func (s *Splitter) merge(components []Region) ([]Region, []int) {
+	// parent of every component, components merged into another one point at it
+	parent := make([]int, len(components))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	merged := append([]Region{}, components...)
+
+	for changed := s.mergeDistance > 0; changed; {
+		changed = false
+
+		for i := range merged {
+			if parent[i] != i {
+				continue
+			}
+
+			for j := i + 1; j < len(merged); j++ {
+				if parent[j] != j || gap(merged[i].Rect, merged[j].Rect) > s.mergeDistance {
+					continue
+				}
+
+				merged[i].Rect = merged[i].Rect.Union(merged[j].Rect)
+				merged[i].Area += merged[j].Area
+				parent[j] = i
+				changed = true
+			}
+		}
+	}
+
+	root := func(i int) int {
+		for parent[i] != i {
+			i = parent[i]
+		}
+
+		return i
+	}
+
+	var roots []int
+	for i := range merged {
+		if parent[i] == i && merged[i].Area >= s.minArea {
+			roots = append(roots, i)
+		}
+	}
+
+	sort.Slice(roots, func(a, b int) bool {
+		ra, rb := merged[roots[a]].Rect.Min, merged[roots[b]].Rect.Min
+		if ra.Y != rb.Y {
+			return ra.Y < rb.Y
+		}
+
+		return ra.X < rb.X
+	})
+
+	regions := make([]Region, len(roots))
+	indexOf := map[int]int{}
+
+	for n, r := range roots {
+		regions[n] = merged[r]
+		indexOf[r] = n
+	}
+
+	// labels start at 1
+	regionOf := make([]int, len(components)+1)
+	regionOf[0] = -1
+
+	for i := range components {
+		n, ok := indexOf[root(i)]
+		if !ok {
+			n = -1
+		}
+
+		regionOf[i+1] = n
+	}
+
+	return regions, regionOf
+}
+
+// gap returns the number of pixels between two rectangles, the larger of the horizontal and vertical distance.
+func gap(a, b image.Rectangle) int {
+	dx := maxInt(b.Min.X-a.Max.X, a.Min.X-b.Max.X)
+	dy := maxInt(b.Min.Y-a.Max.Y, a.Min.Y-b.Max.Y)
+
+	return maxInt(maxInt(dx, dy), 0)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+// Split returns a croppable for every region of the image content along with the regions, see Regions.
+// Split images are cropped to the rectangle of their region extended by the padding of the cropper, pixels of other regions
+// inside the rectangle are replaced with the padding color. Only the image of the croppable is split, frames of animations are ignored.
+func (s *Splitter) Split(c *Croppable) ([]*Croppable, []Region) {
+	m := s.cropper.matcher(c.Image)
+	regions, labels, regionOf := s.label(c.Image, m)
+
+	var fill color.Color = color.Transparent
+	if m.hasBackground {
+		fill = m.background
+	}
+
+	b := c.Image.Bounds()
+	split := make([]*Croppable, len(regions))
+
+	for n, r := range regions {
+		padded := r.Rect.Inset(-s.cropper.padding)
+		canvas := newCanvas(c.Image, padded, fill)
+		visible := padded.Intersect(b)
+
+		for y := visible.Min.Y; y < visible.Max.Y; y++ {
+			for x := visible.Min.X; x < visible.Max.X; x++ {
+				label := labels[(y-b.Min.Y)*b.Dx()+x-b.Min.X]
+				if label != 0 && regionOf[label] != n {
+					continue
+				}
+
+				canvas.Set(x, y, c.Image.At(x, y))
+			}
+		}
+
+		split[n] = c.With(canvas.(CroppableImage))
+	}
+
+	return split, regions
+}
+
+// SplitManifest describes the images saved by SplitAndSave.
+type SplitManifest struct {
+	// Source is the path of the split image.
+	Source  string        `json:"source"`
+	Width   int           `json:"width"`
+	Height  int           `json:"height"`
+	Sprites []SplitSprite `json:"sprites"`
+}
+
+// SplitSprite is a saved region of a split image.
+type SplitSprite struct {
+	// File is the name of the saved image, in the directory of the manifest.
+	File string `json:"file"`
+	// X, Y, W and H are the rectangle of the saved image in the source image, including padding.
+	X    int `json:"x"`
+	Y    int `json:"y"`
+	W    int `json:"w"`
+	H    int `json:"h"`
+	Area int `json:"area"`
+}
+
+// SplitAndSave splits the image and saves every region like the cropper saves images, with "_n" appended to the name,
+// n is the index of the region. A JSON manifest of the saved images is saved next to them, named after the image with a ".json" extension.
+func (s *Splitter) SplitAndSave(c *Croppable) (*SplitManifest, error) {
+	if s.cropper.outDir != "" {
+		if err := os.MkdirAll(s.cropper.outDir, os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+
+	split, regions := s.Split(c)
+	b := c.Image.Bounds()
+	manifest := &SplitManifest{Source: c.Path, Width: b.Dx(), Height: b.Dy(), Sprites: []SplitSprite{}}
+
+	for n, sc := range split {
+		fp, err := s.cropper.saveAs(sc, fmt.Sprintf("_%d", n))
+		if err != nil {
+			return nil, fmt.Errorf("error saving image: %w", err)
+		}
+
+		r := sc.Image.Bounds()
+		manifest.Sprites = append(manifest.Sprites, SplitSprite{
+			File: filepath.Base(fp),
+			X:    r.Min.X - b.Min.X,
+			Y:    r.Min.Y - b.Min.Y,
+			W:    r.Dx(),
+			H:    r.Dy(),
+			Area: regions[n].Area,
+		})
+	}
+
+	if err := s.cropper.saveManifest(c.Path, manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+type SplitterOption func(*Splitter) error
+
+// WithConnectivity sets whether pixels are connected to their 4 edge neighbours or to all 8 neighbours, including diagonal ones.
+func WithConnectivity(connectivity int) SplitterOption {
+	return func(s *Splitter) error {
+		if connectivity != 4 && connectivity != 8 {
+			return errors.New("connectivity must be 4 or 8")
+		}
+
+		s.connectivity = connectivity
+
+		return nil
+	}
+}
+
+// WithMergeDistance merges regions whose rectangles are separated by at most distance pixels, horizontally and vertically,
+// e.g. to keep a sprite with a detached shadow in one image. 0 disables merging.
+func WithMergeDistance(distance int) SplitterOption {
+	return func(s *Splitter) error {
+		if distance < 0 {
+			return errors.New("merge distance cannot be negative")
+		}
+
+		s.mergeDistance = distance
+
+		return nil
+	}
+}
+
+// WithMinArea drops regions with fewer content pixels than area, e.g. specks of noise. Merged regions count the pixels of all their parts.
+func WithMinArea(area int) SplitterOption {
+	return func(s *Splitter) error {
+		if area < 0 {
+			return errors.New("min area cannot be negative")
+		}
+
+		s.minArea = area
+
+		return nil
+	}
+}
@@ -0,0 +1,179 @@
+package gocropper_test
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/H3Cki/gocrop/gocropper"
+	"github.com/stretchr/testify/assert"
+)
+
+// spriteSheet returns a transparent sheet with 3x3 squares at (1,1), (12,1) and (17,1), two diagonal pixels at (7,1) and (8,2),
+// an L shape spanning (6,7)-(12,11) with a pixel inside its rectangle at (9,9) and a speck at (1,10).
+func spriteSheet() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 12))
+	red := color.NRGBA{255, 0, 0, 255}
+
+	for _, r := range []image.Rectangle{
+		image.Rect(1, 1, 4, 4),
+		image.Rect(12, 1, 15, 4),
+		image.Rect(17, 1, 20, 4),
+		image.Rect(7, 1, 8, 2),
+		image.Rect(8, 2, 9, 3),
+		image.Rect(6, 7, 12, 8),
+		image.Rect(6, 8, 7, 11),
+		image.Rect(9, 9, 10, 10),
+		image.Rect(1, 10, 2, 11),
+	} {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.SetNRGBA(x, y, red)
+			}
+		}
+	}
+
+	return img
+}
+
+func TestSplitter_Regions(t *testing.T) {
+	var (
+		a  = gocropper.Region{Rect: image.Rect(1, 1, 4, 4), Area: 9}
+		b  = gocropper.Region{Rect: image.Rect(7, 1, 9, 3), Area: 2}
+		b1 = gocropper.Region{Rect: image.Rect(7, 1, 8, 2), Area: 1}
+		b2 = gocropper.Region{Rect: image.Rect(8, 2, 9, 3), Area: 1}
+		c  = gocropper.Region{Rect: image.Rect(12, 1, 15, 4), Area: 9}
+		d  = gocropper.Region{Rect: image.Rect(17, 1, 20, 4), Area: 9}
+		cd = gocropper.Region{Rect: image.Rect(12, 1, 20, 4), Area: 18}
+		f  = gocropper.Region{Rect: image.Rect(6, 7, 12, 11), Area: 9}
+		g  = gocropper.Region{Rect: image.Rect(9, 9, 10, 10), Area: 1}
+		fg = gocropper.Region{Rect: image.Rect(6, 7, 12, 11), Area: 10}
+		e  = gocropper.Region{Rect: image.Rect(1, 10, 2, 11), Area: 1}
+	)
+
+	tests := []struct {
+		name string
+		opts []gocropper.SplitterOption
+		want []gocropper.Region
+	}{
+		{
+			name: "8-connectivity",
+			want: []gocropper.Region{a, b, c, d, f, g, e},
+		},
+		{
+			name: "4-connectivity",
+			opts: []gocropper.SplitterOption{gocropper.WithConnectivity(4)},
+			want: []gocropper.Region{a, b1, c, d, b2, f, g, e},
+		},
+		{
+			name: "merge distance",
+			opts: []gocropper.SplitterOption{gocropper.WithMergeDistance(2)},
+			want: []gocropper.Region{a, b, cd, fg, e},
+		},
+		{
+			name: "min area",
+			opts: []gocropper.SplitterOption{gocropper.WithMinArea(2)},
+			want: []gocropper.Region{a, b, c, d, f},
+		},
+		{
+			name: "min area of merged regions",
+			opts: []gocropper.SplitterOption{gocropper.WithConnectivity(4), gocropper.WithMergeDistance(1), gocropper.WithMinArea(2)},
+			want: []gocropper.Region{a, b, c, d, fg},
+		},
+	}
+
+	cropper, err := gocropper.NewCropper()
+	assert.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			splitter, err := gocropper.NewSplitter(cropper, tt.opts...)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tt.want, splitter.Regions(spriteSheet()))
+		})
+	}
+}
+
+func TestNewSplitter(t *testing.T) {
+	cropper, err := gocropper.NewCropper()
+	assert.NoError(t, err)
+
+	_, err = gocropper.NewSplitter(nil)
+	assert.Error(t, err)
+
+	_, err = gocropper.NewSplitter(cropper, gocropper.WithConnectivity(6))
+	assert.Error(t, err)
+
+	_, err = gocropper.NewSplitter(cropper, gocropper.WithMergeDistance(-1))
+	assert.Error(t, err)
+
+	_, err = gocropper.NewSplitter(cropper, gocropper.WithMinArea(-1))
+	assert.Error(t, err)
+}
+
+func TestSplitter_SplitAndSave(t *testing.T) {
+	srcDir, outDir := t.TempDir(), t.TempDir()
+	src := path.Join(srcDir, "sheet.png")
+
+	f, err := os.Create(src)
+	assert.NoError(t, err)
+	assert.NoError(t, png.Encode(f, spriteSheet()))
+	assert.NoError(t, f.Close())
+
+	croppable, err := gocropper.Load(src)
+	assert.NoError(t, err)
+
+	cropper, err := gocropper.NewCropper(gocropper.WithOutDir(outDir), gocropper.WithOutSuffix("_s"), gocropper.WithPadding(1))
+	assert.NoError(t, err)
+
+	splitter, err := gocropper.NewSplitter(cropper, gocropper.WithMinArea(2))
+	assert.NoError(t, err)
+
+	manifest, err := splitter.SplitAndSave(croppable)
+	assert.NoError(t, err)
+
+	want := &gocropper.SplitManifest{
+		Source: src,
+		Width:  20,
+		Height: 12,
+		Sprites: []gocropper.SplitSprite{
+			{File: "sheet_0_s.png", X: 0, Y: 0, W: 5, H: 5, Area: 9},
+			{File: "sheet_1_s.png", X: 6, Y: 0, W: 4, H: 4, Area: 2},
+			{File: "sheet_2_s.png", X: 11, Y: 0, W: 5, H: 5, Area: 9},
+			{File: "sheet_3_s.png", X: 16, Y: 0, W: 5, H: 5, Area: 9},
+			{File: "sheet_4_s.png", X: 5, Y: 6, W: 8, H: 6, Area: 9},
+		},
+	}
+
+	assert.Equal(t, want, manifest)
+
+	data, err := os.ReadFile(path.Join(outDir, "sheet_s.json"))
+	assert.NoError(t, err)
+
+	saved := &gocropper.SplitManifest{}
+	assert.NoError(t, json.Unmarshal(data, saved))
+	assert.Equal(t, want, saved)
+
+	// the last sprite extends past the right edge of the sheet and is padded with transparency
+	last, err := gocropper.Load(path.Join(outDir, "sheet_3_s.png"))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 5, 5), last.Image.Bounds())
+
+	_, _, _, alpha := last.Image.At(4, 2).RGBA()
+	assert.Zero(t, alpha)
+
+	// the pixel inside the rectangle of the L shape belongs to a dropped region and is masked out
+	l, err := gocropper.Load(path.Join(outDir, "sheet_4_s.png"))
+	assert.NoError(t, err)
+
+	_, _, _, alpha = l.Image.At(9-5, 9-6).RGBA()
+	assert.Zero(t, alpha)
+
+	_, _, _, alpha = l.Image.At(6-5, 10-6).RGBA()
+	assert.Equal(t, uint32(0xffff), alpha)
+}
@@ -0,0 +1,804 @@
+package gocropper
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+)
+
+var ErrInvalidPNG = errors.New("invalid png")
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// png color types
+const (
+	pngGray      = 0
+	pngTrueColor = 2
+	pngPaletted  = 3
+	pngGrayAlpha = 4
+	pngRGBA      = 6
+)
+
+const (
+	// pngMaxChunkLen is the maximum length of a chunk allowed by the PNG specification.
+	pngMaxChunkLen = 1<<31 - 1
+	// pngMaxHeaderChunkLen is the maximum length of the IHDR, PLTE and tRNS chunks, the only ones buffered by pngRowReader.
+	pngMaxHeaderChunkLen = 256 * 3
+)
+
+// StreamRect returns the cropping rectangle of a PNG image like Rect does, but instead of decoding
+// the whole image it decodes scanlines one at a time, using memory proportional to the width of the image.
+// If auto background is enabled the image is read twice, first to detect the background from its borders.
+//
+// Interlaced images can't be processed row by row, they are decoded entirely and passed to Rect.
+// Animated images are decoded entirely and passed to APNGRect.
+func (i *Cropper) StreamRect(path string) (image.Rectangle, error) {
+	rect, _, err := i.streamRect(path)
+	if errors.Is(err, errAnimated) {
+		c, err := Load(path)
+		if err != nil {
+			return image.Rectangle{}, err
+		}
+
+		return i.apngRect(c.APNG), nil
+	}
+
+	if errors.Is(err, errInterlaced) {
+		img, err := loadPNG(path)
+		if err != nil {
+			return image.Rectangle{}, err
+		}
+
+		return i.Rect(img), nil
+	}
+
+	return rect, err
+}
+
+// StreamCropAndSave crops and saves a PNG image in two passes without holding the decoded source image in memory.
+// The first pass finds the cropping rectangle with StreamRect, the second re-reads the file and keeps only
+// the rows inside the rectangle, stopping as soon as the last of them is decoded.
+//
+// Interlaced and animated images are loaded entirely and cropped with CropAndSave.
+func (i *Cropper) StreamCropAndSave(path string) error {
+	_, err := i.streamCropAndSave(pngCoder.croppable(path), withLoaded)
+	return err
+}
+
+// streamable reports whether the croppable should be cropped with StreamCropAndSave instead of being loaded.
+func (i *Cropper) streamable(c *Croppable) bool {
+	return i.streaming && c.Image == nil && c.is(pngCoder)
+}
+
+func (i *Cropper) streamRect(path string) (image.Rectangle, pixelMatcher, error) {
+	var m pixelMatcher
+
+	if i.autoBackground {
+		border, err := readPNGBorder(path)
+		if err != nil {
+			return image.Rectangle{}, m, err
+		}
+
+		m = i.matcher(border)
+	} else {
+		m = i.matcher(nil)
+	}
+
+	var (
+		rect   image.Rectangle
+		bounds image.Rectangle
+	)
+
+	found := false
+
+	err := readPNGRows(path, func(pr *pngRowReader, y int, row []byte) bool {
+		bounds = image.Rect(0, 0, pr.width, pr.height)
+
+		first := -1
+
+		for x := 0; x < pr.width; x++ {
+			if m.filled(pr.rgba(row, x)) {
+				first = x
+				break
+			}
+		}
+
+		if first == -1 {
+			return false
+		}
+
+		if !found {
+			found = true
+			rect = image.Rect(first, y, first+1, y+1)
+		}
+
+		if first < rect.Min.X {
+			rect.Min.X = first
+		}
+
+		// only pixels extending the rectangle to the right need to be checked
+		for x := pr.width - 1; x >= rect.Max.X; x-- {
+			if m.filled(pr.rgba(row, x)) {
+				rect.Max.X = x + 1
+				break
+			}
+		}
+
+		rect.Max.Y = y + 1
+
+		return false
+	})
+
+	if err != nil {
+		return image.Rectangle{}, m, err
+	}
+
+	if !found {
+		return bounds, m, nil
+	}
+
+	return rect, m, nil
+}
+
+// streamCropAndSave crops and saves the croppable like StreamCropAndSave does. Images which have to be loaded entirely,
+// interlaced, animated and unchanged ones, are loaded with load, see withLoaded.
+func (i *Cropper) streamCropAndSave(c *Croppable, load func(c *Croppable, fn func(c *Croppable) error) error) (ok bool, err error) {
+	rect, m, err := i.streamRect(c.Path)
+	if errors.Is(err, errInterlaced) || errors.Is(err, errAnimated) {
+		err = load(c, func(c *Croppable) (err error) {
+			ok, err = i.cropAndSave(c)
+			return err
+		})
+
+		return ok, err
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	cropped, err := i.streamCrop(c.Path, rect, m)
+	if err != nil {
+		return false, err
+	}
+
+	if cropped == nil {
+		if i.skipUnchanged {
+			return false, nil
+		}
+
+		// unchanged image is saved like CropAndSave does
+		err = load(c, func(c *Croppable) (err error) {
+			ok, err = i.saveCropped(c, c.With(c.Image), rect, false)
+			return err
+		})
+
+		return ok, err
+	}
+
+	return i.saveCropped(c, c.With(cropped), rect, true)
+}
+
+// streamCrop reads only the rows of the image within rect extended by padding.
+// Returns nil image if cropping would make no changes.
+func (i *Cropper) streamCrop(path string, rect image.Rectangle, m pixelMatcher) (CroppableImage, error) {
+	padded := rect.Inset(-i.padding)
+
+	var (
+		out draw.Image
+		src image.Rectangle
+	)
+
+	err := readPNGRows(path, func(pr *pngRowReader, y int, row []byte) bool {
+		if out == nil {
+			bounds := image.Rect(0, 0, pr.width, pr.height)
+			if padded.Eq(bounds) {
+				return true
+			}
+
+			// if rect cuts deep enough the padding is copied from the image,
+			// otherwise only rect is copied onto an empty image
+			src = rect
+			if padded.In(bounds) {
+				src = padded
+			}
+
+			out = pr.newImage(padded)
+
+			if src != padded {
+				var fill color.Color = color.Transparent
+				if m.hasBackground {
+					fill = m.background
+				}
+
+				out = newCanvas(out, padded, fill)
+			}
+		}
+
+		if y < src.Min.Y {
+			return false
+		}
+
+		for x := src.Min.X; x < src.Max.X; x++ {
+			pr.set(out, x, y, row, x)
+		}
+
+		return y+1 >= src.Max.Y
+	})
+
+	if err != nil || out == nil {
+		return nil, err
+	}
+
+	return out.(CroppableImage), nil
+}
+
+var (
+	errInterlaced = errors.New("interlaced png")
+	errAnimated   = errors.New("animated png")
+)
+
+// readPNGRows calls fn for every row of the PNG image at given path until fn returns true.
+// Returns errInterlaced for interlaced images and errAnimated for animated images.
+func readPNGRows(path string, fn func(pr *pngRowReader, y int, row []byte) bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	pr, err := newPNGRowReader(file)
+	if err != nil {
+		return err
+	}
+
+	defer pr.close()
+
+	if pr.interlaced {
+		return errInterlaced
+	}
+
+	if pr.animated {
+		return errAnimated
+	}
+
+	for y := 0; y < pr.height; y++ {
+		row, err := pr.next()
+		if err != nil {
+			return err
+		}
+
+		if fn(pr, y, row) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// pngBorder holds only the outermost rows and columns of an image, enough for DetectBackground.
+type pngBorder struct {
+	rect        image.Rectangle
+	top, bottom []color.Color
+	left, right []color.Color
+	colorModel  color.Model
+}
+
+func (b *pngBorder) ColorModel() color.Model { return b.colorModel }
+func (b *pngBorder) Bounds() image.Rectangle { return b.rect }
+
+func (b *pngBorder) At(x, y int) color.Color {
+	switch {
+	case y == 0:
+		return b.top[x]
+	case y == b.rect.Max.Y-1:
+		return b.bottom[x]
+	case x == 0:
+		return b.left[y]
+	case x == b.rect.Max.X-1:
+		return b.right[y]
+	default:
+		return color.Transparent
+	}
+}
+
+func readPNGBorder(path string) (image.Image, error) {
+	border := &pngBorder{colorModel: color.NRGBA64Model}
+
+	err := readPNGRows(path, func(pr *pngRowReader, y int, row []byte) bool {
+		if y == 0 {
+			border.rect = image.Rect(0, 0, pr.width, pr.height)
+			border.top = make([]color.Color, pr.width)
+			border.left = make([]color.Color, pr.height)
+			border.right = make([]color.Color, pr.height)
+
+			for x := range border.top {
+				border.top[x] = pr.at(row, x)
+			}
+		}
+
+		if y == pr.height-1 {
+			border.bottom = make([]color.Color, pr.width)
+
+			for x := range border.bottom {
+				border.bottom[x] = pr.at(row, x)
+			}
+		}
+
+		border.left[y] = pr.at(row, 0)
+		border.right[y] = pr.at(row, pr.width-1)
+
+		return false
+	})
+
+	return border, err
+}
+
+func loadPNG(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return png.Decode(file)
+}
+
+// pngRowReader decodes a non-interlaced PNG image one scanline at a time.
+type pngRowReader struct {
+	width, height int
+	depth         int
+	colorType     int
+	interlaced    bool
+	animated      bool
+	palette       color.Palette
+	// premultiplied palette colors
+	paletteRGBA [256][4]uint32
+	// transparent color of gray and true color images, in raw sample values
+	trns    []uint16
+	zr      io.ReadCloser
+	cr, pr  []byte
+	bpp     int
+	rowSize int
+}
+
+func newPNGRowReader(r io.Reader) (*pngRowReader, error) {
+	br := bufio.NewReader(r)
+
+	sig := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(br, sig); err != nil || !bytes.Equal(sig, pngSignature) {
+		return nil, ErrInvalidPNG
+	}
+
+	pr := &pngRowReader{}
+	header := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			return nil, fmt.Errorf("%s: %w", err.Error(), ErrInvalidPNG)
+		}
+
+		length := binary.BigEndian.Uint32(header[:4])
+		typ := string(header[4:])
+
+		if length > pngMaxChunkLen {
+			return nil, ErrInvalidPNG
+		}
+
+		if typ == "IDAT" {
+			if pr.width == 0 {
+				return nil, ErrInvalidPNG
+			}
+
+			return pr, pr.start(&idatReader{r: br, remaining: length})
+		}
+
+		switch typ {
+		case "IEND":
+			return nil, ErrInvalidPNG
+		case "IHDR", "PLTE", "tRNS":
+		default:
+			if typ == "acTL" {
+				pr.animated = true
+			}
+
+			// skip the data and the CRC of chunks not needed for decoding rows
+			if _, err := io.CopyN(io.Discard, br, int64(length)+4); err != nil {
+				return nil, fmt.Errorf("%s: %w", err.Error(), ErrInvalidPNG)
+			}
+
+			continue
+		}
+
+		if length > pngMaxHeaderChunkLen {
+			return nil, ErrInvalidPNG
+		}
+
+		data := make([]byte, length+4)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, fmt.Errorf("%s: %w", err.Error(), ErrInvalidPNG)
+		}
+
+		data = data[:length]
+
+		var err error
+
+		switch typ {
+		case "IHDR":
+			err = pr.parseIHDR(data)
+		case "PLTE":
+			err = pr.parsePLTE(data)
+		case "tRNS":
+			err = pr.parseTRNS(data)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (pr *pngRowReader) parseIHDR(data []byte) error {
+	if len(data) != 13 {
+		return ErrInvalidPNG
+	}
+
+	pr.width = int(binary.BigEndian.Uint32(data[0:4]))
+	pr.height = int(binary.BigEndian.Uint32(data[4:8]))
+	pr.depth = int(data[8])
+	pr.colorType = int(data[9])
+	pr.interlaced = data[12] == 1
+
+	if pr.width <= 0 || pr.height <= 0 || data[10] != 0 || data[11] != 0 || data[12] > 1 {
+		return ErrInvalidPNG
+	}
+
+	var channels int
+
+	switch pr.colorType {
+	case pngGray, pngPaletted:
+		channels = 1
+	case pngGrayAlpha:
+		channels = 2
+	case pngTrueColor:
+		channels = 3
+	case pngRGBA:
+		channels = 4
+	default:
+		return ErrInvalidPNG
+	}
+
+	switch pr.depth {
+	case 1, 2, 4, 8, 16:
+	default:
+		return ErrInvalidPNG
+	}
+
+	if (pr.colorType == pngPaletted && pr.depth == 16) || (channels > 1 && pr.colorType != pngPaletted && pr.depth < 8) {
+		return ErrInvalidPNG
+	}
+
+	bits := channels * pr.depth
+	pr.rowSize = (pr.width*bits + 7) / 8
+
+	pr.bpp = bits / 8
+	if pr.bpp < 1 {
+		pr.bpp = 1
+	}
+
+	return nil
+}
+
+func (pr *pngRowReader) parsePLTE(data []byte) error {
+	if len(data)%3 != 0 || len(data) > 256*3 {
+		return ErrInvalidPNG
+	}
+
+	pr.palette = make(color.Palette, len(data)/3)
+	for i := range pr.palette {
+		pr.palette[i] = color.NRGBA{data[3*i], data[3*i+1], data[3*i+2], 0xff}
+	}
+
+	pr.updatePalette()
+
+	return nil
+}
+
+func (pr *pngRowReader) parseTRNS(data []byte) error {
+	switch pr.colorType {
+	case pngGray:
+		if len(data) != 2 {
+			return ErrInvalidPNG
+		}
+
+		pr.trns = []uint16{binary.BigEndian.Uint16(data)}
+	case pngTrueColor:
+		if len(data) != 6 {
+			return ErrInvalidPNG
+		}
+
+		pr.trns = []uint16{binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:]), binary.BigEndian.Uint16(data[4:])}
+	case pngPaletted:
+		if len(data) > len(pr.palette) {
+			return ErrInvalidPNG
+		}
+
+		for i, a := range data {
+			c := pr.palette[i].(color.NRGBA)
+			c.A = a
+			pr.palette[i] = c
+		}
+
+		pr.updatePalette()
+	}
+
+	return nil
+}
+
+func (pr *pngRowReader) updatePalette() {
+	for i := range pr.paletteRGBA {
+		// out of range indices are treated as transparent
+		var r, g, b, a uint32
+		if i < len(pr.palette) {
+			r, g, b, a = pr.palette[i].RGBA()
+		}
+
+		pr.paletteRGBA[i] = [4]uint32{r, g, b, a}
+	}
+}
+
+func (pr *pngRowReader) start(idat io.Reader) error {
+	if pr.colorType == pngPaletted && len(pr.palette) == 0 {
+		return ErrInvalidPNG
+	}
+
+	zr, err := zlib.NewReader(idat)
+	if err != nil {
+		return fmt.Errorf("%s: %w", err.Error(), ErrInvalidPNG)
+	}
+
+	pr.zr = zr
+	pr.cr = make([]byte, pr.rowSize+1)
+	pr.pr = make([]byte, pr.rowSize+1)
+
+	return nil
+}
+
+func (pr *pngRowReader) close() {
+	if pr.zr != nil {
+		pr.zr.Close()
+	}
+}
+
+// next returns the next unfiltered row, valid until the following call.
+func (pr *pngRowReader) next() ([]byte, error) {
+	if _, err := io.ReadFull(pr.zr, pr.cr); err != nil {
+		return nil, fmt.Errorf("%s: %w", err.Error(), ErrInvalidPNG)
+	}
+
+	cdat, pdat := pr.cr[1:], pr.pr[1:]
+
+	switch pr.cr[0] {
+	case 0:
+	case 1:
+		for i := pr.bpp; i < len(cdat); i++ {
+			cdat[i] += cdat[i-pr.bpp]
+		}
+	case 2:
+		for i := range cdat {
+			cdat[i] += pdat[i]
+		}
+	case 3:
+		for i := 0; i < pr.bpp; i++ {
+			cdat[i] += pdat[i] / 2
+		}
+
+		for i := pr.bpp; i < len(cdat); i++ {
+			cdat[i] += uint8((int(cdat[i-pr.bpp]) + int(pdat[i])) / 2)
+		}
+	case 4:
+		for i := range cdat {
+			var a, c uint8
+			if i >= pr.bpp {
+				a, c = cdat[i-pr.bpp], pdat[i-pr.bpp]
+			}
+
+			cdat[i] += paeth(a, pdat[i], c)
+		}
+	default:
+		return nil, ErrInvalidPNG
+	}
+
+	pr.cr, pr.pr = pr.pr, pr.cr
+
+	return cdat, nil
+}
+
+func paeth(a, b, c uint8) uint8 {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := absInt(p-int(a)), absInt(p-int(b)), absInt(p-int(c))
+
+	if pa <= pb && pa <= pc {
+		return a
+	} else if pb <= pc {
+		return b
+	}
+
+	return c
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
+
+// sample returns the raw value of the nth sample of the row.
+func (pr *pngRowReader) sample(row []byte, n int) uint16 {
+	switch pr.depth {
+	case 8:
+		return uint16(row[n])
+	case 16:
+		return uint16(row[2*n])<<8 | uint16(row[2*n+1])
+	default:
+		bit := n * pr.depth
+		shift := 8 - pr.depth - bit%8
+
+		return uint16(row[bit/8]>>shift) & (1<<pr.depth - 1)
+	}
+}
+
+// scale converts a raw sample value to 16 bits.
+func (pr *pngRowReader) scale(v uint16) uint32 {
+	return uint32(v) * 0xffff / (1<<pr.depth - 1)
+}
+
+// rgba returns the alpha-premultiplied color of the pixel at x, like color.Color.RGBA.
+func (pr *pngRowReader) rgba(row []byte, x int) (r, g, b, a uint32) {
+	switch pr.colorType {
+	case pngPaletted:
+		c := pr.paletteRGBA[pr.sample(row, x)]
+		return c[0], c[1], c[2], c[3]
+	case pngGray:
+		v := pr.sample(row, x)
+		if len(pr.trns) == 1 && v == pr.trns[0] {
+			return 0, 0, 0, 0
+		}
+
+		y := pr.scale(v)
+
+		return y, y, y, 0xffff
+	case pngTrueColor:
+		rv, gv, bv := pr.sample(row, 3*x), pr.sample(row, 3*x+1), pr.sample(row, 3*x+2)
+		if len(pr.trns) == 3 && rv == pr.trns[0] && gv == pr.trns[1] && bv == pr.trns[2] {
+			return 0, 0, 0, 0
+		}
+
+		return pr.scale(rv), pr.scale(gv), pr.scale(bv), 0xffff
+	case pngGrayAlpha:
+		a = pr.scale(pr.sample(row, 2*x+1))
+		y := pr.scale(pr.sample(row, 2*x)) * a / 0xffff
+
+		return y, y, y, a
+	default:
+		a = pr.scale(pr.sample(row, 4*x+3))
+		r = pr.scale(pr.sample(row, 4*x)) * a / 0xffff
+		g = pr.scale(pr.sample(row, 4*x+1)) * a / 0xffff
+		b = pr.scale(pr.sample(row, 4*x+2)) * a / 0xffff
+
+		return r, g, b, a
+	}
+}
+
+// at returns the color of the pixel at x.
+func (pr *pngRowReader) at(row []byte, x int) color.Color {
+	switch pr.colorType {
+	case pngPaletted:
+		idx := int(pr.sample(row, x))
+		if idx >= len(pr.palette) {
+			return color.Transparent
+		}
+
+		return pr.palette[idx]
+	case pngGrayAlpha:
+		y := uint16(pr.scale(pr.sample(row, 2*x)))
+		a := uint16(pr.scale(pr.sample(row, 2*x+1)))
+
+		return color.NRGBA64{y, y, y, a}
+	case pngRGBA:
+		return color.NRGBA64{
+			uint16(pr.scale(pr.sample(row, 4*x))),
+			uint16(pr.scale(pr.sample(row, 4*x+1))),
+			uint16(pr.scale(pr.sample(row, 4*x+2))),
+			uint16(pr.scale(pr.sample(row, 4*x+3))),
+		}
+	default:
+		r, g, b, a := pr.rgba(row, x)
+		return color.RGBA64{uint16(r), uint16(g), uint16(b), uint16(a)}
+	}
+}
+
+// newImage returns an empty image of the type image/png decodes the PNG image into.
+func (pr *pngRowReader) newImage(r image.Rectangle) draw.Image {
+	opaque := len(pr.trns) == 0 && (pr.colorType == pngGray || pr.colorType == pngTrueColor)
+
+	switch {
+	case pr.colorType == pngPaletted:
+		return image.NewPaletted(r, pr.palette)
+	case pr.colorType == pngGray && opaque && pr.depth == 16:
+		return image.NewGray16(r)
+	case pr.colorType == pngGray && opaque:
+		return image.NewGray(r)
+	case opaque && pr.depth == 16:
+		return image.NewRGBA64(r)
+	case opaque:
+		return image.NewRGBA(r)
+	case pr.depth == 16:
+		return image.NewNRGBA64(r)
+	default:
+		return image.NewNRGBA(r)
+	}
+}
+
+// set sets the pixel at x, y of the image to the pixel at srcX of the row.
+func (pr *pngRowReader) set(img draw.Image, x, y int, row []byte, srcX int) {
+	if p, ok := img.(*image.Paletted); ok {
+		p.SetColorIndex(x, y, uint8(pr.sample(row, srcX)))
+		return
+	}
+
+	img.Set(x, y, pr.at(row, srcX))
+}
+
+// idatReader reads the data of consecutive IDAT chunks.
+type idatReader struct {
+	r         *bufio.Reader
+	remaining uint32
+	done      bool
+}
+
+func (ir *idatReader) Read(p []byte) (int, error) {
+	for ir.remaining == 0 {
+		if ir.done {
+			return 0, io.EOF
+		}
+
+		// skip the CRC of the previous chunk and read the header of the next one
+		header := make([]byte, 12)
+		if _, err := io.ReadFull(ir.r, header); err != nil {
+			return 0, err
+		}
+
+		if string(header[8:]) != "IDAT" {
+			ir.done = true
+			return 0, io.EOF
+		}
+
+		ir.remaining = binary.BigEndian.Uint32(header[4:8])
+		if ir.remaining > pngMaxChunkLen {
+			return 0, ErrInvalidPNG
+		}
+	}
+
+	if uint32(len(p)) > ir.remaining {
+		p = p[:ir.remaining]
+	}
+
+	n, err := ir.r.Read(p)
+	ir.remaining -= uint32(n)
+
+	return n, err
+}
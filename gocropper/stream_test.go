@@ -0,0 +1,207 @@
+package gocropper_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/png"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/H3Cki/gocrop/gocropper"
+	"github.com/stretchr/testify/assert"
+)
+
+// streamTestImages returns images of every PNG color type and bit depth produced by image/png.
+func streamTestImages(rnd *rand.Rand) map[string]draw.Image {
+	b := image.Rect(0, 0, 120, 90)
+
+	images := map[string]draw.Image{
+		"gray":           image.NewGray(b),
+		"gray16":         image.NewGray16(b),
+		"nrgba":          image.NewNRGBA(b),
+		"nrgba64":        image.NewNRGBA64(b),
+		"rgba_opaque":    image.NewRGBA(b),
+		"paletted_1bit":  image.NewPaletted(b, color.Palette{color.Transparent, color.Black}),
+		"paletted_4bit":  image.NewPaletted(b, append(color.Palette{color.Transparent}, palette.Plan9[:15]...)),
+		"paletted_8bit":  image.NewPaletted(b, append(color.Palette{color.Transparent}, palette.Plan9[:255]...)),
+		"paletted_white": image.NewPaletted(b, color.Palette{color.White, color.Black, color.RGBA{0, 0, 0xff, 0xff}}),
+	}
+
+	for name, img := range images {
+		switch name {
+		case "gray", "gray16", "rgba_opaque", "paletted_white":
+			draw.Draw(img, b, image.NewUniform(color.White), image.Point{}, draw.Src)
+		}
+
+		x, y := rnd.Intn(100), rnd.Intn(70)
+		content := image.Rect(x, y, x+1+rnd.Intn(20), y+1+rnd.Intn(20))
+
+		for cy := content.Min.Y; cy < content.Max.Y; cy++ {
+			for cx := content.Min.X; cx < content.Max.X; cx++ {
+				if rnd.Intn(3) == 0 {
+					img.Set(cx, cy, color.NRGBA{uint8(rnd.Intn(200)), uint8(rnd.Intn(200)), uint8(rnd.Intn(256)), uint8(128 + rnd.Intn(128))})
+				}
+			}
+		}
+
+		// corners of the content are always set, so it has known bounds
+		img.Set(content.Min.X, content.Min.Y, color.Black)
+		img.Set(content.Max.X-1, content.Max.Y-1, color.Black)
+	}
+
+	return images
+}
+
+func writePNG(t *testing.T, fp string, img image.Image) {
+	f, err := os.Create(fp)
+	assert.NoError(t, err)
+
+	defer f.Close()
+
+	assert.NoError(t, png.Encode(f, img))
+}
+
+func TestCropper_StreamRect(t *testing.T) {
+	dir := t.TempDir()
+	rnd := rand.New(rand.NewSource(1))
+
+	alpha, _ := gocropper.NewCropper()
+	white, _ := gocropper.NewCropper(gocropper.WithBackgroundColor(color.White, 10))
+	auto, _ := gocropper.NewCropper(gocropper.WithAutoBackground(10))
+	croppers := map[string]*gocropper.Cropper{"alpha": alpha, "white": white, "auto": auto}
+
+	for n := 0; n < 5; n++ {
+		for name, img := range streamTestImages(rnd) {
+			fp := path.Join(dir, fmt.Sprintf("%s_%d.png", name, n))
+			writePNG(t, fp, img)
+
+			croppable, err := gocropper.Load(fp)
+			assert.NoError(t, err)
+
+			for cName, cropper := range croppers {
+				t.Run(fmt.Sprintf("%s/%d/%s", name, n, cName), func(t *testing.T) {
+					rect, err := cropper.StreamRect(fp)
+					assert.NoError(t, err)
+					assert.Equal(t, cropper.Rect(croppable.Image), rect)
+				})
+			}
+		}
+	}
+}
+
+func TestCropper_StreamRect_Chunks(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	img.SetNRGBA(4, 3, color.NRGBA{0, 0, 0, 255})
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, png.Encode(buf, img))
+
+	// chunk returns a chunk with the given length field followed by data, the CRC is not checked
+	chunk := func(length uint32, typ string, data []byte) []byte {
+		c := binary.BigEndian.AppendUint32(nil, length)
+		c = append(c, typ...)
+		c = append(c, data...)
+
+		return append(c, 0, 0, 0, 0)
+	}
+
+	tests := []struct {
+		name  string
+		chunk []byte
+		exErr error
+	}{
+		{name: "large ancillary chunk", chunk: chunk(1<<20, "zTXt", make([]byte, 1<<20))},
+		{name: "length above 2^31-1", chunk: chunk(0xfffffffc, "tEXt", nil), exErr: gocropper.ErrInvalidPNG},
+		{name: "oversized palette", chunk: chunk(1<<20, "PLTE", make([]byte, 1<<20)), exErr: gocropper.ErrInvalidPNG},
+		{name: "chunk longer than the file", chunk: chunk(1<<30, "zTXt", nil), exErr: gocropper.ErrInvalidPNG},
+	}
+
+	cropper, _ := gocropper.NewCropper()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the chunk is inserted after the signature and the IHDR chunk
+			data := append(append(append([]byte{}, buf.Bytes()[:33]...), tt.chunk...), buf.Bytes()[33:]...)
+
+			fp := path.Join(t.TempDir(), "chunk.png")
+			assert.NoError(t, os.WriteFile(fp, data, 0o644))
+
+			rect, err := cropper.StreamRect(fp)
+			assert.ErrorIs(t, err, tt.exErr)
+
+			if tt.exErr == nil {
+				assert.Equal(t, image.Rect(4, 3, 5, 4), rect)
+			}
+		})
+	}
+}
+
+func TestCropper_StreamCropAndSave(t *testing.T) {
+	dir := t.TempDir()
+	outDir := t.TempDir()
+	rnd := rand.New(rand.NewSource(2))
+
+	for name, img := range streamTestImages(rnd) {
+		fp := path.Join(dir, name+".png")
+		writePNG(t, fp, img)
+
+		for _, padding := range []int{0, 1, 40} {
+			for _, bg := range []color.Color{nil, color.White} {
+				t.Run(fmt.Sprintf("%s/%d/%v", name, padding, bg), func(t *testing.T) {
+					opts := []gocropper.CropperOption{gocropper.WithPadding(padding), gocropper.WithOutDir(outDir)}
+					if bg != nil {
+						opts = append(opts, gocropper.WithBackgroundColor(bg, 0))
+					}
+
+					cropper, err := gocropper.NewCropper(opts...)
+					assert.NoError(t, err)
+
+					assert.NoError(t, cropper.StreamCropAndSave(fp))
+
+					streamed, err := gocropper.Load(path.Join(outDir, name+".png"))
+					assert.NoError(t, err)
+
+					croppable, err := gocropper.Load(fp)
+					assert.NoError(t, err)
+
+					expected, _ := cropper.Crop(croppable)
+
+					assertSamePixels(t, expected.Image, streamed.Image)
+				})
+			}
+		}
+	}
+}
+
+// assertSamePixels compares pixels of two images of the same size, ignoring their coordinate spaces.
+func assertSamePixels(t *testing.T, expected, actual image.Image) {
+	t.Helper()
+
+	if !assert.Equal(t, expected.Bounds().Size(), actual.Bounds().Size()) {
+		return
+	}
+
+	eb, ab := expected.Bounds(), actual.Bounds()
+
+	for y := 0; y < eb.Dy(); y++ {
+		for x := 0; x < eb.Dx(); x++ {
+			ec := color.NRGBA64Model.Convert(expected.At(eb.Min.X+x, eb.Min.Y+y))
+			ac := color.NRGBA64Model.Convert(actual.At(ab.Min.X+x, ab.Min.Y+y))
+
+			if ec.(color.NRGBA64).A == 0 && ac.(color.NRGBA64).A == 0 {
+				continue
+			}
+
+			if !assert.Equal(t, ec, ac, "pixel %d,%d", x, y) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package gocropper
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+var ErrInvalidTGA = errors.New("invalid tga")
+
+// tga image types
+const (
+	tgaTrueColor    = 2
+	tgaGray         = 3
+	tgaTrueColorRLE = 10
+	tgaGrayRLE      = 11
+)
+
+// tga image descriptor bits
+const (
+	tgaAlphaBits   = 0x0f
+	tgaRightToLeft = 1 << 4
+	tgaTopToBottom = 1 << 5
+)
+
+const tgaHeaderLen = 18
+
+type tgaHeader struct {
+	idLength     uint8
+	colorMapType uint8
+	imageType    uint8
+	colorMapLen  uint16
+	colorMapBits uint8
+	width        int
+	height       int
+	depth        uint8
+	descriptor   uint8
+}
+
+func readTGAHeader(r io.Reader) (tgaHeader, error) {
+	b := make([]byte, tgaHeaderLen)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return tgaHeader{}, err
+	}
+
+	h := tgaHeader{
+		idLength:     b[0],
+		colorMapType: b[1],
+		imageType:    b[2],
+		colorMapLen:  binary.LittleEndian.Uint16(b[5:]),
+		colorMapBits: b[7],
+		width:        int(binary.LittleEndian.Uint16(b[12:])),
+		height:       int(binary.LittleEndian.Uint16(b[14:])),
+		depth:        b[16],
+		descriptor:   b[17],
+	}
+
+	switch {
+	case h.imageType == tgaTrueColor || h.imageType == tgaTrueColorRLE:
+		if h.depth != 24 && h.depth != 32 {
+			return tgaHeader{}, fmt.Errorf("%d-bit true color: %w", h.depth, ErrInvalidTGA)
+		}
+	case h.imageType == tgaGray || h.imageType == tgaGrayRLE:
+		if h.depth != 8 {
+			return tgaHeader{}, fmt.Errorf("%d-bit grayscale: %w", h.depth, ErrInvalidTGA)
+		}
+	default:
+		return tgaHeader{}, fmt.Errorf("image type %d: %w", h.imageType, ErrInvalidTGA)
+	}
+
+	return h, nil
+}
+
+func (h tgaHeader) colorModel() color.Model {
+	switch h.depth {
+	case 8:
+		return color.GrayModel
+	case 24:
+		return color.RGBAModel
+	default:
+		return color.NRGBAModel
+	}
+}
+
+// decodeTGAConfig decodes the dimensions and color model of a TGA image.
+func decodeTGAConfig(r io.Reader) (image.Config, error) {
+	h, err := readTGAHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	return image.Config{ColorModel: h.colorModel(), Width: h.width, Height: h.height}, nil
+}
+
+// decodeTGA decodes uncompressed and RLE compressed 24 and 32-bit true color and 8-bit grayscale TGA images.
+// 24-bit images are decoded into *image.RGBA and 32-bit images into *image.NRGBA, so that encodeTGA keeps their depth.
+// 32-bit images keep their alpha unless the descriptor has no alpha bits, then the fourth byte is ignored and they are opaque.
+// Grayscale images are decoded into *image.Gray.
+func decodeTGA(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+
+	h, err := readTGAHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	// skip the image id and the color map, true color images don't use it
+	skip := int64(h.idLength)
+	if h.colorMapType == 1 {
+		skip += int64(h.colorMapLen) * int64((h.colorMapBits+7)/8)
+	}
+
+	if _, err := io.CopyN(io.Discard, br, skip); err != nil {
+		return nil, err
+	}
+
+	bpp := int(h.depth / 8)
+	data := make([]byte, h.width*h.height*bpp)
+
+	if h.imageType == tgaTrueColorRLE || h.imageType == tgaGrayRLE {
+		err = readTGARLE(br, data, bpp)
+	} else {
+		_, err = io.ReadFull(br, data)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var img interface {
+		image.Image
+		Set(x, y int, c color.Color)
+	}
+
+	rect := image.Rect(0, 0, h.width, h.height)
+
+	switch bpp {
+	case 1:
+		img = image.NewGray(rect)
+	case 3:
+		img = image.NewRGBA(rect)
+	default:
+		img = image.NewNRGBA(rect)
+	}
+
+	alpha := h.descriptor&tgaAlphaBits != 0
+
+	for y := 0; y < h.height; y++ {
+		// rows are stored bottom to top unless the descriptor says otherwise
+		dy := h.height - 1 - y
+		if h.descriptor&tgaTopToBottom != 0 {
+			dy = y
+		}
+
+		for x := 0; x < h.width; x++ {
+			dx := x
+			if h.descriptor&tgaRightToLeft != 0 {
+				dx = h.width - 1 - x
+			}
+
+			p := data[(y*h.width+x)*bpp:]
+
+			switch m := img.(type) {
+			case *image.Gray:
+				m.Pix[m.PixOffset(dx, dy)] = p[0]
+			case *image.RGBA:
+				i := m.PixOffset(dx, dy)
+				m.Pix[i], m.Pix[i+1], m.Pix[i+2], m.Pix[i+3] = p[2], p[1], p[0], 0xff
+			case *image.NRGBA:
+				a := uint8(0xff)
+				if alpha {
+					a = p[3]
+				}
+
+				i := m.PixOffset(dx, dy)
+				m.Pix[i], m.Pix[i+1], m.Pix[i+2], m.Pix[i+3] = p[2], p[1], p[0], a
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// readTGARLE reads run-length encoded pixels of bpp bytes until data is filled.
+func readTGARLE(r *bufio.Reader, data []byte, bpp int) error {
+	for n := 0; n < len(data); {
+		packet, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		count := int(packet&0x7f) + 1
+		if n+count*bpp > len(data) {
+			return fmt.Errorf("run exceeds image data: %w", ErrInvalidTGA)
+		}
+
+		if packet&0x80 == 0 {
+			if _, err := io.ReadFull(r, data[n:n+count*bpp]); err != nil {
+				return err
+			}
+
+			n += count * bpp
+
+			continue
+		}
+
+		if _, err := io.ReadFull(r, data[n:n+bpp]); err != nil {
+			return err
+		}
+
+		for i := 1; i < count; i++ {
+			copy(data[n+i*bpp:], data[n:n+bpp])
+		}
+
+		n += count * bpp
+	}
+
+	return nil
+}
+
+// encodeTGA encodes the image as an RLE compressed TGA image with top-left origin.
+// *image.Gray images are encoded as 8-bit grayscale and *image.NRGBA images, which 32-bit images are decoded into, as 32-bit
+// true color with alpha, even if they are opaque. Other opaque images are encoded as 24-bit and the rest as 32-bit true color.
+func encodeTGA(w io.Writer, m image.Image) error {
+	b := m.Bounds()
+	if b.Dx() > 0xffff || b.Dy() > 0xffff {
+		return fmt.Errorf("image is too large: %w", ErrInvalidTGA)
+	}
+
+	gray, isGray := m.(*image.Gray)
+	_, isNRGBA := m.(*image.NRGBA)
+
+	header := make([]byte, tgaHeaderLen)
+	binary.LittleEndian.PutUint16(header[12:], uint16(b.Dx()))
+	binary.LittleEndian.PutUint16(header[14:], uint16(b.Dy()))
+	header[17] = tgaTopToBottom
+
+	switch {
+	case isGray:
+		header[2], header[16] = tgaGrayRLE, 8
+	case !isNRGBA && opaque(m):
+		header[2], header[16] = tgaTrueColorRLE, 24
+	default:
+		header[2], header[16] = tgaTrueColorRLE, 32
+		header[17] |= 8
+	}
+
+	bpp := int(header[16] / 8)
+
+	bw := bufio.NewWriter(w)
+	bw.Write(header)
+
+	row := make([]byte, b.Dx()*bpp)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			p := row[(x-b.Min.X)*bpp:]
+
+			if isGray {
+				p[0] = gray.GrayAt(x, y).Y
+				continue
+			}
+
+			c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+			p[0], p[1], p[2] = c.B, c.G, c.R
+
+			if bpp == 4 {
+				p[3] = c.A
+			}
+		}
+
+		writeTGARLE(bw, row, bpp)
+	}
+
+	return bw.Flush()
+}
+
+// writeTGARLE writes a row of pixels of bpp bytes as run-length encoded packets, packets don't cross rows.
+func writeTGARLE(w *bufio.Writer, row []byte, bpp int) {
+	n := len(row) / bpp
+	px := func(i int) []byte { return row[i*bpp : (i+1)*bpp] }
+
+	for i := 0; i < n; {
+		run := 1
+		for i+run < n && run < 128 && bytes.Equal(px(i), px(i+run)) {
+			run++
+		}
+
+		if run > 1 {
+			w.WriteByte(0x80 | byte(run-1))
+			w.Write(px(i))
+			i += run
+
+			continue
+		}
+
+		// raw packet until the next run of at least 2 equal pixels
+		raw := 1
+		for i+raw < n && raw < 128 && (i+raw+1 >= n || !bytes.Equal(px(i+raw), px(i+raw+1))) {
+			raw++
+		}
+
+		w.WriteByte(byte(raw - 1))
+		w.Write(row[i*bpp : (i+raw)*bpp])
+		i += raw
+	}
+}
+
+// opaque returns true if all pixels of the image are opaque.
+func opaque(m image.Image) bool {
+	if o, ok := m.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+
+	b := m.Bounds()
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := m.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+
+	return true
+}
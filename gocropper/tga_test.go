@@ -0,0 +1,165 @@
+package gocropper
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tgaBytes returns a TGA image with the header fields and data.
+func tgaBytes(imageType, depth, descriptor uint8, width, height int, data ...byte) []byte {
+	header := []byte{0, 0, imageType, 0, 0, 0, 0, 0, 0, 0, 0, 0, byte(width), 0, byte(height), 0, depth, descriptor}
+	return append(header, data...)
+}
+
+func TestDecodeTGA(t *testing.T) {
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+	green := color.NRGBA{0, 0xff, 0, 0xff}
+	blue := color.NRGBA{0, 0, 0xff, 0x80}
+	clear := color.NRGBA{0xff, 0xff, 0xff, 0}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		exPixels [][]color.Color
+		exErr    error
+	}{
+		{
+			name: "24-bit bottom-left",
+			data: tgaBytes(tgaTrueColor, 24, 0, 2, 2,
+				0, 0xff, 0, 0, 0, 0xff, // bottom row: green, red
+				0, 0, 0xff, 0, 0xff, 0, // top row: red, green
+			),
+			exPixels: [][]color.Color{{red, green}, {green, red}},
+		},
+		{
+			name: "32-bit top-left",
+			data: tgaBytes(tgaTrueColor, 32, tgaTopToBottom|8, 2, 1,
+				0xff, 0, 0, 0x80, 0xff, 0xff, 0xff, 0,
+			),
+			exPixels: [][]color.Color{{blue, clear}},
+		},
+		{
+			name: "32-bit without alpha bits",
+			data: tgaBytes(tgaTrueColor, 32, tgaTopToBottom, 2, 1,
+				0, 0, 0xff, 0, 0, 0xff, 0, 0,
+			),
+			exPixels: [][]color.Color{{red, green}},
+		},
+		{
+			name: "32-bit right-to-left",
+			data: tgaBytes(tgaTrueColor, 32, tgaTopToBottom|tgaRightToLeft|8, 2, 1,
+				0xff, 0, 0, 0x80, 0xff, 0xff, 0xff, 0,
+			),
+			exPixels: [][]color.Color{{clear, blue}},
+		},
+		{
+			name: "32-bit rle across rows",
+			data: tgaBytes(tgaTrueColorRLE, 32, tgaTopToBottom|8, 2, 2,
+				0x82, 0xff, 0, 0, 0x80, // run of 3 blue
+				0x00, 0xff, 0xff, 0xff, 0, // raw clear
+			),
+			exPixels: [][]color.Color{{blue, blue}, {blue, clear}},
+		},
+		{
+			name: "8-bit gray rle",
+			data: tgaBytes(tgaGrayRLE, 8, 0, 3, 1, 0x01, 0x10, 0x20, 0x80, 0x30),
+			exPixels: [][]color.Color{{
+				color.Gray{0x10}, color.Gray{0x20}, color.Gray{0x30},
+			}},
+		},
+		{
+			name:  "rle overflow",
+			data:  tgaBytes(tgaTrueColorRLE, 24, 0, 1, 1, 0x81, 0, 0, 0),
+			exErr: ErrInvalidTGA,
+		},
+		{
+			name:  "color mapped",
+			data:  tgaBytes(1, 8, 0, 1, 1, 0),
+			exErr: ErrInvalidTGA,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := decodeTGA(bytes.NewReader(tt.data))
+			assert.ErrorIs(t, err, tt.exErr)
+
+			if tt.exErr != nil {
+				return
+			}
+
+			for y, row := range tt.exPixels {
+				for x, c := range row {
+					assert.Equal(t, color.NRGBAModel.Convert(c), color.NRGBAModel.Convert(img.At(x, y)), "pixel %d,%d", x, y)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeTGA(t *testing.T) {
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 300, 3))
+	for x := 0; x < 300; x++ {
+		// long runs, short runs and single pixels
+		nrgba.SetNRGBA(x, 1, color.NRGBA{uint8(x / 7), uint8(x % 3), 0x40, uint8(x)})
+		nrgba.SetNRGBA(x, 2, color.NRGBA{uint8(x), 0, 0, 0xff})
+	}
+
+	rgb := image.NewRGBA(image.Rect(5, 5, 10, 8))
+	for i := range rgb.Pix {
+		rgb.Pix[i] = 0xff
+	}
+
+	gray := image.NewGray(image.Rect(0, 0, 4, 4))
+	gray.SetGray(1, 2, color.Gray{0x42})
+
+	opaqueNRGBA := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	for i := range opaqueNRGBA.Pix {
+		opaqueNRGBA.Pix[i] = 0xff
+	}
+
+	rgbAlpha := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	rgbAlpha.Set(1, 1, color.White)
+
+	tests := []struct {
+		name    string
+		img     image.Image
+		exDepth uint8
+	}{
+		{"32-bit", nrgba, 32},
+		{"opaque 32-bit", opaqueNRGBA, 32},
+		{"24-bit with transparency", rgbAlpha, 32},
+		{"24-bit", rgb, 24},
+		{"gray", gray, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			assert.NoError(t, encodeTGA(buf, tt.img))
+			assert.Equal(t, tt.exDepth, buf.Bytes()[16])
+
+			cfg, err := decodeTGAConfig(bytes.NewReader(buf.Bytes()))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.img.Bounds().Dx(), cfg.Width)
+			assert.Equal(t, tt.img.Bounds().Dy(), cfg.Height)
+
+			decoded, err := decodeTGA(buf)
+			assert.NoError(t, err)
+
+			b := tt.img.Bounds()
+			for y := 0; y < b.Dy(); y++ {
+				for x := 0; x < b.Dx(); x++ {
+					ex := color.NRGBAModel.Convert(tt.img.At(b.Min.X+x, b.Min.Y+y))
+					if !assert.Equal(t, ex, color.NRGBAModel.Convert(decoded.At(x, y)), "pixel %d,%d", x, y) {
+						return
+					}
+				}
+			}
+		})
+	}
+}
@@ -3,15 +3,20 @@ package main
 import (
 	"errors"
 	"fmt"
+	"image/jpeg"
 	"log"
 	"os"
-	"sync"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/H3Cki/gocrop/gocropper"
 	"github.com/urfave/cli/v2"
 )
 
-var imageFlags = []cli.Flag{
+// trimFlags set how the content of images is found, they are shared by all commands.
+var trimFlags = []cli.Flag{
 	&cli.Int64Flag{
 		Name:  "threshold",
 		Value: 0,
@@ -21,6 +26,37 @@ var imageFlags = []cli.Flag{
 		Value: 0,
 		Usage: "Sets the number of transparent pixels that will surround the min cropped rectangle",
 	},
+	&cli.StringFlag{
+		Name:  "background",
+		Usage: "Sets the background color that will be cropped like transparent pixels, accepts hex colors (#ffffff), white, black, transparent or auto to detect the background of each image",
+		Value: "",
+		Action: func(ctx *cli.Context, s string) error {
+			if s == "auto" {
+				return nil
+			}
+
+			_, err := gocropper.ParseColor(s)
+			return err
+		},
+	},
+	&cli.Float64Flag{
+		Name:  "tolerance",
+		Value: 0,
+		Usage: "Sets the maximum distance from the background color at which a pixel is still considered background, the unit depends on the metric",
+	},
+	&cli.StringFlag{
+		Name:  "metric",
+		Value: "rgb",
+		Usage: "Sets the color distance metric used with background, one of: rgb (8-bit euclidean), max (8-bit per-channel maximum), cie76 or ciede2000 (Delta E)",
+		Action: func(ctx *cli.Context, s string) error {
+			_, err := gocropper.ParseMetric(s)
+			return err
+		},
+	},
+}
+
+// outputFlags set how cropped images are named and encoded, they are shared by all commands saving images.
+var outputFlags = []cli.Flag{
 	&cli.StringFlag{
 		Name:  "out_dir",
 		Usage: "Sets the output directory for cropped images.",
@@ -57,11 +93,75 @@ var imageFlags = []cli.Flag{
 			return nil
 		},
 	},
+	&cli.IntFlag{
+		Name:  "jpeg-quality",
+		Usage: "Sets the quality of saved JPEG images, an integer in range of 1-100",
+		Value: jpeg.DefaultQuality,
+	},
+	&cli.IntFlag{
+		Name:  "gif-colors",
+		Usage: "Sets the maximum number of colors of GIF images which have to be converted to a palette, an integer in range of 2-256. Paletted images keep their palette",
+		Value: 256,
+	},
+	&cli.StringFlag{
+		Name:  "dither",
+		Usage: "Sets the dithering used when converting GIF images to a palette, one of: floyd-steinberg, none",
+		Value: "floyd-steinberg",
+	},
+	&cli.StringFlag{
+		Name:  "fallback-format",
+		Usage: "Sets the format of saved images whose format can't be encoded, e.g. webp, its extension is appended to the names of such images",
+		Value: "png",
+	},
+}
+
+var imageFlags = flags(trimFlags, outputFlags, []cli.Flag{
+	&cli.IntFlag{
+		Name:  "jobs",
+		Value: runtime.NumCPU(),
+		Usage: "Sets the maximum number of images processed at once",
+	},
+	&cli.StringFlag{
+		Name:  "max-memory",
+		Usage: "Sets the memory budget for decoded images processed at once, e.g. 512MB or 2GiB. Images larger than the budget are skipped",
+		Action: func(ctx *cli.Context, s string) error {
+			_, err := parseByteSize(s)
+			return err
+		},
+	},
+	&cli.Int64Flag{
+		Name:  "max-pixels",
+		Usage: "Sets the maximum number of pixels of an image, larger images are skipped without being decoded",
+	},
+	&cli.BoolFlag{
+		Name:  "shared-icon-rect",
+		Usage: "Crops all images of ICO and CUR files to the same proportions instead of cropping each image to its own content",
+	},
+	&cli.BoolFlag{
+		Name:  "stream",
+		Usage: "Crops PNG images row by row in two passes without decoding the whole image into memory",
+	},
 	&cli.BoolFlag{
 		Name:  "enumerate",
 		Usage: "Enumerates all images by including n at the end of cropped file name, n gets incremented by 1 each time an image is saved",
 		Value: false,
 	},
+})
+
+var metadataFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "metadata",
+		Usage: "Saves the source bounds, crop rectangle, padding and final size of every cropped image next to it, one of: none, json, yaml",
+		Value: "none",
+		Action: func(ctx *cli.Context, s string) error {
+			_, err := gocropper.ParseMetadataFormat(s)
+			return err
+		},
+	},
+	&cli.StringFlag{
+		Name:  "manifest",
+		Usage: "Saves the metadata of all cropped images in a single file at the given path, as YAML if it ends with .yaml or .yml, as JSON otherwise",
+	},
 }
 
 var directoryFlags = []cli.Flag{
@@ -79,6 +179,105 @@ var directoryFlags = []cli.Flag{
 			return nil
 		},
 	},
+	&cli.StringFlag{
+		Name:  "group-regex",
+		Usage: "Crops images in the same directory whose names match the regex with the same first capture group (or whole match) to the same rectangle, e.g. ^(.*)_\\d+\\.png$",
+		Action: func(ctx *cli.Context, s string) error {
+			_, err := regexp.Compile(s)
+			return err
+		},
+	},
+	&cli.BoolFlag{
+		Name:  "group-dir",
+		Usage: "Crops all images in the same directory to the same rectangle",
+	},
+}
+
+var splitFlags = []cli.Flag{
+	&cli.IntFlag{
+		Name:  "connectivity",
+		Value: 8,
+		Usage: "Sets whether pixels are connected to their 4 edge neighbours or to all 8 neighbours, one of: 4, 8",
+	},
+	&cli.IntFlag{
+		Name:  "merge-distance",
+		Usage: "Merges regions separated by at most this number of pixels, 0 disables merging",
+	},
+	&cli.IntFlag{
+		Name:  "min-area",
+		Usage: "Skips regions with fewer pixels than this number, e.g. specks of noise",
+	},
+}
+
+var sliceFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:     "cell",
+		Usage:    "Sets the size of the cells of the grid as WIDTHxHEIGHT, e.g. 64x64",
+		Required: true,
+		Action: func(ctx *cli.Context, s string) error {
+			_, _, err := parseSize(s)
+			return err
+		},
+	},
+	&cli.IntFlag{
+		Name:  "margin",
+		Usage: "Sets the number of pixels between the edges of the image and the grid",
+	},
+	&cli.IntFlag{
+		Name:  "spacing",
+		Usage: "Sets the number of pixels between neighbouring cells",
+	},
+	&cli.BoolFlag{
+		Name:  "trim",
+		Usage: "Crops every cell to its content, using threshold, background and padding options",
+	},
+}
+
+var atlasFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "out",
+		Usage: "Sets the path of the atlas without extension, pages are saved as PNG images along with their data, e.g. atlas.png and atlas.json",
+		Value: "atlas",
+	},
+	&cli.StringFlag{
+		Name:  "format",
+		Usage: "Sets the format of the atlas data, one of: json-hash, json-array (TexturePacker) or csv",
+		Value: "json-hash",
+		Action: func(ctx *cli.Context, s string) error {
+			_, err := gocropper.ParseAtlasFormat(s)
+			return err
+		},
+	},
+	&cli.StringFlag{
+		Name:  "algorithm",
+		Usage: "Sets the packing algorithm, one of: maxrects or skyline",
+		Value: "maxrects",
+		Action: func(ctx *cli.Context, s string) error {
+			_, err := gocropper.ParsePackAlgorithm(s)
+			return err
+		},
+	},
+	&cli.BoolFlag{
+		Name:  "rotate",
+		Usage: "Allows rotating sprites by 90 degrees clockwise when they fit better",
+	},
+	&cli.BoolFlag{
+		Name:  "pot",
+		Usage: "Makes the width and height of atlas pages powers of two",
+	},
+	&cli.StringFlag{
+		Name:  "max-size",
+		Usage: "Sets the maximum size of atlas pages as WIDTHxHEIGHT, sprites which don't fit are placed in the next page",
+		Value: "2048x2048",
+		Action: func(ctx *cli.Context, s string) error {
+			_, _, err := parseSize(s)
+			return err
+		},
+	},
+	&cli.IntFlag{
+		Name:  "sprite-padding",
+		Usage: "Sets the number of transparent pixels between sprites in atlas pages",
+	},
 }
 
 func main() {
@@ -100,32 +299,29 @@ func main() {
 						return err
 					}
 
-					paths := cCtx.Args().Slice()
-
-					wg := &sync.WaitGroup{}
-					wg.Add(len(paths))
+					processor, err := processorFromCtx(cCtx, cropper)
+					if err != nil {
+						return err
+					}
 
-					for _, path := range paths {
-						go func(p string) {
-							croppable, err := gocropper.Load(p)
-							if err != nil {
-								fmt.Println("error loading image: ", err.Error())
-								return
-							}
+					croppables := []*gocropper.Croppable{}
 
-							defer wg.Done()
+					for _, path := range cCtx.Args().Slice() {
+						croppable, err := gocropper.NewCroppable(path)
+						if err != nil {
+							fmt.Printf("error loading image %s: %s\n", path, err.Error())
+							continue
+						}
 
-							if err := cropper.CropAndSave(croppable); err != nil {
-								fmt.Println("error loading cropsaving image: ", err.Error())
-							}
-						}(path)
+						printMismatch(croppable)
+						croppables = append(croppables, croppable)
 					}
 
-					wg.Wait()
+					processor.Process(croppables, printResult)
 
-					return nil
+					return saveManifest(cCtx, cropper)
 				},
-				Flags: imageFlags,
+				Flags: flags(imageFlags, metadataFlags),
 			},
 			{
 				Name:    "directory",
@@ -136,6 +332,10 @@ func main() {
 						return errors.New("no directories specified")
 					}
 
+					if cCtx.IsSet("group-regex") && cCtx.Bool("group-dir") {
+						return errors.New("group-regex and group-dir cannot be used together")
+					}
+
 					opts := []gocropper.FinderOptions{
 						gocropper.WithRecursive(cCtx.Bool("recursive")),
 					}
@@ -154,34 +354,156 @@ func main() {
 						return err
 					}
 
+					processor, err := processorFromCtx(cCtx, cropper)
+					if err != nil {
+						return err
+					}
+
+					// images are found even if some directories can't be read
 					crops, err := loader.Find(cCtx.Args().Slice())
+					if err != nil {
+						fmt.Println(err.Error())
+					}
+
+					for _, c := range crops {
+						printMismatch(c)
+					}
+
+					switch {
+					case cCtx.IsSet("group-regex"):
+						re := regexp.MustCompile(cCtx.String("group-regex"))
+						processor.ProcessGroups(gocropper.GroupByRegex(crops, re), printResult)
+					case cCtx.Bool("group-dir"):
+						processor.ProcessGroups(gocropper.GroupByDirectory(crops), printResult)
+					default:
+						processor.Process(crops, printResult)
+					}
+
+					return saveManifest(cCtx, cropper)
+				},
+				Flags: flags(imageFlags, metadataFlags, directoryFlags),
+			},
+			{
+				Name:    "split",
+				Aliases: []string{"s"},
+				Usage:   "split images into separate images of their connected regions, e.g. sprite sheets",
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() == 0 {
+						return errors.New("no images specified")
+					}
+
+					cropper, err := cropperFromCtx(cCtx)
 					if err != nil {
 						return err
 					}
 
-					wg := &sync.WaitGroup{}
-					wg.Add(len(crops))
+					splitter, err := gocropper.NewSplitter(cropper,
+						gocropper.WithConnectivity(cCtx.Int("connectivity")),
+						gocropper.WithMergeDistance(cCtx.Int("merge-distance")),
+						gocropper.WithMinArea(cCtx.Int("min-area")),
+					)
+					if err != nil {
+						return err
+					}
 
-					for _, croppable := range crops {
-						go func(c *gocropper.Croppable) {
-							defer wg.Done()
+					for _, path := range cCtx.Args().Slice() {
+						croppable, err := gocropper.Load(path)
+						if err != nil {
+							fmt.Printf("error loading image %s: %s\n", path, err.Error())
+							continue
+						}
 
-							if err := c.Load(); err != nil {
-								fmt.Println(err)
-								return
-							}
+						printMismatch(croppable)
 
-							if err := cropper.CropAndSave(c); err != nil {
-								fmt.Println(err)
-							}
-						}(croppable)
+						if _, err := splitter.SplitAndSave(croppable); err != nil {
+							fmt.Printf("error splitting %s: %s\n", path, err.Error())
+						}
 					}
 
-					wg.Wait()
+					return nil
+				},
+				Flags: flags(trimFlags, outputFlags, splitFlags),
+			},
+			{
+				Name:  "slice",
+				Usage: "slice images into cells of a fixed size grid, e.g. sprite sheets",
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() == 0 {
+						return errors.New("no images specified")
+					}
+
+					cropper, err := cropperFromCtx(cCtx)
+					if err != nil {
+						return err
+					}
+
+					width, height, err := parseSize(cCtx.String("cell"))
+					if err != nil {
+						return err
+					}
+
+					slicer, err := gocropper.NewSlicer(cropper, width, height,
+						gocropper.WithMargin(cCtx.Int("margin")),
+						gocropper.WithSpacing(cCtx.Int("spacing")),
+						gocropper.WithTrim(cCtx.Bool("trim")),
+					)
+					if err != nil {
+						return err
+					}
+
+					for _, path := range cCtx.Args().Slice() {
+						croppable, err := gocropper.Load(path)
+						if err != nil {
+							fmt.Printf("error loading image %s: %s\n", path, err.Error())
+							continue
+						}
+
+						printMismatch(croppable)
+
+						if _, err := slicer.SliceAndSave(croppable); err != nil {
+							fmt.Printf("error slicing %s: %s\n", path, err.Error())
+						}
+					}
 
 					return nil
 				},
-				Flags: append(imageFlags, directoryFlags...),
+				Flags: flags(trimFlags, outputFlags, sliceFlags),
+			},
+			{
+				Name:  "atlas",
+				Usage: "trim images and pack them into texture atlas pages",
+				Action: func(cCtx *cli.Context) error {
+					if cCtx.Args().Len() == 0 {
+						return errors.New("no images specified")
+					}
+
+					packer, err := packerFromCtx(cCtx)
+					if err != nil {
+						return err
+					}
+
+					croppables := []*gocropper.Croppable{}
+
+					for _, path := range cCtx.Args().Slice() {
+						croppable, err := gocropper.Load(path)
+						if err != nil {
+							fmt.Printf("error loading image %s: %s\n", path, err.Error())
+							continue
+						}
+
+						printMismatch(croppable)
+
+						croppables = append(croppables, croppable)
+					}
+
+					pages, err := packer.Pack(croppables)
+					if err != nil {
+						return err
+					}
+
+					return packer.Save(pages, cCtx.String("out"))
+				},
+				Flags: flags(trimFlags, atlasFlags),
 			},
 		},
 	}
@@ -192,11 +514,210 @@ func main() {
 }
 
 func cropperFromCtx(ctx *cli.Context) (*gocropper.Cropper, error) {
-	return gocropper.NewCropper(
-		gocropper.WithThreshold(uint32(ctx.Int64("threshold"))),
-		gocropper.WithPadding(ctx.Int("padding")),
+	opts, err := trimOptionsFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append(opts,
 		gocropper.WithOutDir(ctx.String("out_dir")),
 		gocropper.WithOutPrefix(ctx.String("prefix")),
 		gocropper.WithOutSuffix(ctx.String("suffix")),
+		gocropper.WithStreaming(ctx.Bool("stream")),
+		gocropper.WithJPEGQuality(ctx.Int("jpeg-quality")),
+	)
+
+	dither, err := gocropper.ParseDither(ctx.String("dither"))
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append(opts, gocropper.WithDither(dither), gocropper.WithGIFColors(ctx.Int("gif-colors")))
+	opts = append(opts, gocropper.WithSharedIconRect(ctx.Bool("shared-icon-rect")))
+
+	// metadata flags are defined for the image and directory commands only
+	if ctx.IsSet("metadata") {
+		sidecar, err := gocropper.ParseMetadataFormat(ctx.String("metadata"))
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, gocropper.WithSidecar(sidecar))
+	}
+
+	opts = append(opts, gocropper.WithCollectMetadata(ctx.IsSet("manifest")))
+
+	if fallback := ctx.String("fallback-format"); fallback != "" {
+		opts = append(opts, gocropper.WithFallbackFormat(fallback))
+	}
+
+	return gocropper.NewCropper(opts...)
+}
+
+// trimOptionsFromCtx returns the options of trimFlags.
+func trimOptionsFromCtx(ctx *cli.Context) ([]gocropper.CropperOption, error) {
+	opts := []gocropper.CropperOption{
+		gocropper.WithThreshold(uint32(ctx.Int64("threshold"))),
+		gocropper.WithPadding(ctx.Int("padding")),
+	}
+
+	metric, err := gocropper.ParseMetric(ctx.String("metric"))
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append(opts, gocropper.WithMetric(metric))
+
+	if ctx.String("background") == "auto" {
+		opts = append(opts, gocropper.WithAutoBackground(ctx.Float64("tolerance")))
+	} else if ctx.IsSet("background") {
+		bg, err := gocropper.ParseColor(ctx.String("background"))
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, gocropper.WithBackgroundColor(bg, ctx.Float64("tolerance")))
+	}
+
+	return opts, nil
+}
+
+func processorFromCtx(ctx *cli.Context, cropper *gocropper.Cropper) (*gocropper.Processor, error) {
+	maxMemory, err := parseByteSize(ctx.String("max-memory"))
+	if err != nil {
+		return nil, err
+	}
+
+	return gocropper.NewProcessor(cropper,
+		gocropper.WithJobs(ctx.Int("jobs")),
+		gocropper.WithMaxMemory(maxMemory),
+		gocropper.WithMaxPixels(ctx.Int64("max-pixels")),
+	)
+}
+
+func packerFromCtx(ctx *cli.Context) (*gocropper.Packer, error) {
+	opts, err := trimOptionsFromCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cropper, err := gocropper.NewCropper(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := gocropper.ParseAtlasFormat(ctx.String("format"))
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm, err := gocropper.ParsePackAlgorithm(ctx.String("algorithm"))
+	if err != nil {
+		return nil, err
+	}
+
+	width, height, err := parseSize(ctx.String("max-size"))
+	if err != nil {
+		return nil, err
+	}
+
+	return gocropper.NewPacker(cropper,
+		gocropper.WithAtlasFormat(format),
+		gocropper.WithPackAlgorithm(algorithm),
+		gocropper.WithRotation(ctx.Bool("rotate")),
+		gocropper.WithPowerOfTwo(ctx.Bool("pot")),
+		gocropper.WithMaxSize(width, height),
+		gocropper.WithSpritePadding(ctx.Int("sprite-padding")),
 	)
 }
+
+// saveManifest saves the metadata collected by the cropper if a manifest path is set.
+func saveManifest(ctx *cli.Context, cropper *gocropper.Cropper) error {
+	if !ctx.IsSet("manifest") {
+		return nil
+	}
+
+	return cropper.SaveMetadata(ctx.String("manifest"))
+}
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"TiB", 1 << 40},
+	{"KB", 1e3},
+	{"MB", 1e6},
+	{"GB", 1e9},
+	{"TB", 1e12},
+	{"B", 1},
+}
+
+// parseByteSize parses a number of bytes with an optional unit suffix, empty string is parsed as 0.
+func parseByteSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	unit := int64(1)
+	num := strings.TrimSpace(s)
+
+	for _, u := range byteUnits {
+		if strings.HasSuffix(strings.ToUpper(num), strings.ToUpper(u.suffix)) {
+			unit = u.size
+			num = strings.TrimSpace(num[:len(num)-len(u.suffix)])
+
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+
+	return int64(n * float64(unit)), nil
+}
+
+// parseSize parses a size formatted as WIDTHxHEIGHT.
+func parseSize(s string) (width, height int, err error) {
+	w, h, ok := strings.Cut(strings.ToLower(s), "x")
+	if ok {
+		width, err = strconv.Atoi(strings.TrimSpace(w))
+	}
+
+	if ok && err == nil {
+		height, err = strconv.Atoi(strings.TrimSpace(h))
+	}
+
+	if !ok || err != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid size: %s", s)
+	}
+
+	return width, height, nil
+}
+
+// flags returns a new list holding the flags of all lists.
+func flags(lists ...[]cli.Flag) []cli.Flag {
+	out := []cli.Flag{}
+	for _, l := range lists {
+		out = append(out, l...)
+	}
+
+	return out
+}
+
+// printMismatch reports an image whose content does not match its extension, it is cropped in the format of its content.
+func printMismatch(c *gocropper.Croppable) {
+	if c.Mismatch != nil {
+		fmt.Println(c.Mismatch.Error())
+	}
+}
+
+func printResult(res gocropper.Result) {
+	if res.Err != nil {
+		fmt.Printf("error cropping %s: %s\n", res.Path, res.Err.Error())
+	}
+}